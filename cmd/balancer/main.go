@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
@@ -10,12 +11,21 @@ import (
 	"net/url"
 	"os"
 	"os/signal"
+	"strconv"
+	"syscall"
 	"time"
 
+	"github.com/AleksandrMatsko/cloudru-balancer/internal/acl"
 	"github.com/AleksandrMatsko/cloudru-balancer/internal/balancer"
+	"github.com/AleksandrMatsko/cloudru-balancer/internal/compress"
+	"github.com/AleksandrMatsko/cloudru-balancer/internal/concurrency"
 	"github.com/AleksandrMatsko/cloudru-balancer/internal/config"
-	"github.com/AleksandrMatsko/cloudru-balancer/internal/health"
-	"github.com/AleksandrMatsko/cloudru-balancer/internal/strategies"
+	"github.com/AleksandrMatsko/cloudru-balancer/internal/errorpage"
+	"github.com/AleksandrMatsko/cloudru-balancer/internal/metrics"
+	"github.com/AleksandrMatsko/cloudru-balancer/internal/ratelimit"
+	"github.com/AleksandrMatsko/cloudru-balancer/internal/router"
+	"github.com/AleksandrMatsko/cloudru-balancer/internal/shedding"
+	"github.com/redis/go-redis/v9"
 
 	_ "go.uber.org/automaxprocs"
 )
@@ -44,27 +54,151 @@ func main() {
 		config.Print(appConfig)
 	}
 
-	strategy, err := createStrategy(appConfig)
+	appMetrics := metrics.New()
+
+	errorPages, err := createErrorPages(appConfig.ErrorPages)
 	if err != nil {
-		logger.Error("Select balancing strategy",
+		logger.Error("Load error pages",
 			slog.String("error", err.Error()),
 		)
 		os.Exit(1)
 	}
 
+	trustedProxies, err := acl.ParsePrefixes(appConfig.TrustedProxies)
+	if err != nil {
+		logger.Error("Parse trusted proxies",
+			slog.String("error", err.Error()),
+		)
+		os.Exit(1)
+	}
+
+	clientIP := acl.NewClientIP(trustedProxies)
+
 	ctx, cancel := context.WithCancel(context.Background())
-	runHealthCheckers(ctx, logger, appConfig, strategy)
 
-	balancer := balancer.NewBalancer(
-		logger,
-		strategy,
-		appConfig.Backends,
-		createURL,
-	)
+	poolConfigs := map[string]config.Pool{config.DefaultPool: appConfig.Pool}
+	for name, poolConfig := range appConfig.Pools {
+		poolConfigs[name] = poolConfig
+	}
+
+	balancers := make(map[string]*balancer.Balancer, len(poolConfigs))
+	adaptiveLimiters := make(map[string]*concurrency.Adaptive)
+	queues := make([]*concurrency.Limiter, 0, len(poolConfigs))
+	for name, poolConfig := range poolConfigs {
+		poolBalancer, limiter, err := createPool(
+			ctx,
+			logger.With(slog.String("pool", name)),
+			appMetrics,
+			name,
+			poolConfig,
+			errorPages,
+		)
+		if err != nil {
+			logger.Error("Create pool",
+				slog.String("pool", name),
+				slog.String("error", err.Error()),
+			)
+			os.Exit(1)
+		}
+
+		balancers[name] = poolBalancer
+		switch limiter := limiter.(type) {
+		case *concurrency.Adaptive:
+			adaptiveLimiters[name] = limiter
+		case *concurrency.Limiter:
+			queues = append(queues, limiter)
+		}
+	}
+
+	rateLimitStore, err := createRateLimitStore(ctx, logger, appMetrics, appConfig.RateLimitStore)
+	if err != nil {
+		logger.Error("Create rate limit store",
+			slog.String("error", err.Error()),
+		)
+		os.Exit(1)
+	}
+
+	pools := make(map[string]http.Handler, len(poolConfigs))
+	for name, poolConfig := range poolConfigs {
+		handler, err := wrapPool(
+			logger.With(slog.String("pool", name)),
+			appMetrics,
+			name,
+			poolConfig,
+			balancers,
+			errorPages,
+			appConfig.Limits,
+			clientIP,
+			rateLimitStore,
+		)
+		if err != nil {
+			logger.Error("Create pool middlewares",
+				slog.String("pool", name),
+				slog.String("error", err.Error()),
+			)
+			os.Exit(1)
+		}
+
+		pools[name] = pool{Handler: handler, balancer: balancers[name]}
+	}
+
+	poolRouter, err := router.NewRouter(pools, config.DefaultPool, func(pool string) {
+		appMetrics.SplitRequests.WithLabelValues(pool).Inc()
+	})
+	if err != nil {
+		logger.Error("Create router",
+			slog.String("error", err.Error()),
+		)
+		os.Exit(1)
+	}
+
+	compressor, err := createCompressor(poolRouter, appConfig.Compression)
+	if err != nil {
+		logger.Error("Create compressor",
+			slog.String("error", err.Error()),
+		)
+		os.Exit(1)
+	}
+
+	shedder, err := createShedder(logger, compressor, appMetrics, appConfig.Shedding, clientIP, queues, errorPages)
+	if err != nil {
+		logger.Error("Create load shedder",
+			slog.String("error", err.Error()),
+		)
+		os.Exit(1)
+	}
+
+	handler, err := acl.New(logger, shedder, clientIP, acl.Policy{Default: acl.ActionAllow}, errorPages, func() {
+		appMetrics.AccessDenied.WithLabelValues("").Inc()
+	})
+	if err != nil {
+		logger.Error("Create access control",
+			slog.String("error", err.Error()),
+		)
+		os.Exit(1)
+	}
+
+	if err := applyRouting(poolRouter, handler, appMetrics, appConfig.Routing); err != nil {
+		logger.Error("Apply routing",
+			slog.String("error", err.Error()),
+		)
+		os.Exit(1)
+	}
+
+	go reloadOnSignal(ctx, logger, poolRouter, handler, appMetrics)
+
+	if appConfig.Admin.Port != 0 {
+		go runAdminServer(ctx, logger, appConfig.Admin, appMetrics, adaptiveLimiters)
+	}
 
 	server := http.Server{
-		Addr:    fmt.Sprintf("0.0.0.0:%d", appConfig.Port),
-		Handler: balancer,
+		Addr:              fmt.Sprintf("0.0.0.0:%d", appConfig.Port),
+		Handler:           handler,
+		ReadHeaderTimeout: time.Duration(appConfig.ServerTimeouts.ReadHeaderSeconds) * time.Second,
+		ReadTimeout:       time.Duration(appConfig.ServerTimeouts.ReadSeconds) * time.Second,
+		WriteTimeout:      time.Duration(appConfig.ServerTimeouts.WriteSeconds) * time.Second,
+		IdleTimeout:       time.Duration(appConfig.ServerTimeouts.IdleSeconds) * time.Second,
+		MaxHeaderBytes:    maxHeaderBytes(poolConfigs, appConfig.Limits),
 	}
 
 	shutdownWaitChan := make(chan os.Signal)
@@ -96,41 +230,369 @@ func main() {
 	<-shutdownWaitChan
 }
 
-type observingStrategy interface {
-	health.Observer
-	balancer.Strategy
+func applyRouting(poolRouter *router.Router, routingACL *acl.ACL, appMetrics *metrics.Metrics, conf config.Routing) error {
+	rules, err := createRules(conf.Rules)
+	if err != nil {
+		return err
+	}
+
+	policy, err := createPolicy(conf.Access)
+	if err != nil {
+		return err
+	}
+
+	if err := policy.Validate(); err != nil {
+		return err
+	}
+
+	splits := make([]router.Split, 0, len(conf.Splits))
+	for _, split := range conf.Splits {
+		splits = append(splits, router.Split{
+			Pool:   split.Pool,
+			Weight: split.Weight,
+		})
+	}
+
+	err = poolRouter.SetRouting(router.Routing{
+		Rules:  rules,
+		Splits: splits,
+		HashOn: router.HashOn{
+			Header: conf.HashOn.Header,
+			Cookie: conf.HashOn.Cookie,
+		},
+		DebugBackend: router.DebugBackend{
+			Header:          conf.DebugBackend.Header,
+			AllowedBackends: conf.DebugBackend.AllowedBackends,
+			SecretHeader:    conf.DebugBackend.SecretHeader,
+			Secret:          conf.DebugBackend.Secret,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := routingACL.SetPolicy(policy); err != nil {
+		return err
+	}
+
+	appMetrics.SplitWeight.Reset()
+	for _, split := range splits {
+		appMetrics.SplitWeight.WithLabelValues(split.Pool).Add(float64(split.Weight))
+	}
+
+	return nil
 }
 
-func createStrategy(conf config.Balancer) (observingStrategy, error) {
-	switch conf.Strategy {
-	case "RoundRobin":
-		return strategies.NewRoundRobin(
-			conf.Backends,
-		), nil
-	case "Random":
-		return strategies.NewRandom(
-			conf.Backends,
+func createRules(conf []config.Rule) ([]router.Rule, error) {
+	rules := make([]router.Rule, 0, len(conf))
+	for _, ruleConf := range conf {
+		rule := router.Rule{
+			Pool:    ruleConf.Pool,
+			Matches: make([]router.Match, 0, len(ruleConf.Match)),
+		}
+
+		for _, matchConf := range ruleConf.Match {
+			match := router.Match{Value: matchConf.Value}
+			sources := 0
+
+			if matchConf.Header != "" {
+				match.Source, match.Name = router.SourceHeader, matchConf.Header
+				sources++
+			}
+			if matchConf.Query != "" {
+				match.Source, match.Name = router.SourceQuery, matchConf.Query
+				sources++
+			}
+			if matchConf.Cookie != "" {
+				match.Source, match.Name = router.SourceCookie, matchConf.Cookie
+				sources++
+			}
+
+			if sources != 1 {
+				return nil, fmt.Errorf("exactly one of header, query or cookie must be set in match of rule for pool %s", ruleConf.Pool)
+			}
+
+			rule.Matches = append(rule.Matches, match)
+		}
+
+		rules = append(rules, rule)
+	}
+
+	return rules, nil
+}
+
+func createPolicy(conf config.Access) (acl.Policy, error) {
+	policy := acl.Policy{
+		Rules:   make([]acl.Rule, 0, len(conf.Rules)),
+		Default: acl.Action(conf.Default),
+	}
+
+	for _, ruleConf := range conf.Rules {
+		networks, err := acl.ParsePrefixes(ruleConf.CIDRs)
+		if err != nil {
+			return acl.Policy{}, fmt.Errorf("parse cidrs of access rule: %w", err)
+		}
+
+		policy.Rules = append(policy.Rules, acl.Rule{
+			Action:   acl.Action(ruleConf.Action),
+			Networks: networks,
+		})
+	}
+
+	return policy, nil
+}
+
+// reloadOnSignal rereads config file on SIGHUP and applies routing from it.
+// Other changes of config require restart.
+func reloadOnSignal(
+	ctx context.Context,
+	logger *slog.Logger,
+	poolRouter *router.Router,
+	routingACL *acl.ACL,
+	appMetrics *metrics.Metrics,
+) {
+	sigWaitChan := make(chan os.Signal, 1)
+	signal.Notify(sigWaitChan, syscall.SIGHUP)
+	defer signal.Stop(sigWaitChan)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-sigWaitChan:
+		}
+
+		appConfig := config.DefaultForBalancer()
+		if err := config.Read(*configFileNameFlag, &appConfig); err != nil {
+			logger.Warn("Reload config",
+				slog.String("config_file_name", *configFileNameFlag),
+				slog.String("error", err.Error()),
+			)
+			continue
+		}
+
+		if err := applyRouting(poolRouter, routingACL, appMetrics, appConfig.Routing); err != nil {
+			logger.Warn("Reload routing",
+				slog.String("error", err.Error()),
+			)
+			continue
+		}
+
+		logger.Info("Routing reloaded")
+	}
+}
+
+// maxHeaderBytes returns limit of request line and headers for the server,
+// so requests exceeding limits of pools are rejected by pools with proper error response.
+func maxHeaderBytes(poolConfigs map[string]config.Pool, defaultLimits config.Limits) int {
+	// requestLineOverhead is the size of method, protocol and separators in request line.
+	const requestLineOverhead = 1 << 10
+
+	result := http.DefaultMaxHeaderBytes
+	for _, poolConfig := range poolConfigs {
+		limits := poolConfig.Limits.WithDefaults(defaultLimits)
+		if limits.MaxHeaderBytes == 0 {
+			continue
+		}
+
+		result = max(result, limits.MaxHeaderBytes+limits.MaxURLLength+requestLineOverhead)
+	}
+
+	return result
+}
+
+func createCompressor(next http.Handler, conf config.Compression) (http.Handler, error) {
+	if !conf.Enabled {
+		return next, nil
+	}
+
+	for _, encoding := range conf.Encodings {
+		if !compress.IsSupported(encoding) {
+			return nil, fmt.Errorf("unknown compression encoding: %s", encoding)
+		}
+	}
+
+	return compress.New(next, compress.Settings{
+		Encodings:    conf.Encodings,
+		MinSize:      conf.MinSizeBytes,
+		ContentTypes: conf.ContentTypes,
+	}), nil
+}
+
+// createShedder wraps next handler with load shedder if any of its thresholds is set.
+// Requests waiting in queues of concurrency limits are counted as queued.
+func createShedder(
+	logger *slog.Logger,
+	next http.Handler,
+	appMetrics *metrics.Metrics,
+	conf config.Shedding,
+	clientIP *acl.ClientIP,
+	queues []*concurrency.Limiter,
+	errorPages *errorpage.Renderer,
+) (http.Handler, error) {
+	if conf.MaxInFlight <= 0 && conf.MaxQueued <= 0 {
+		return next, nil
+	}
+
+	if conf.LowestPriorityThreshold <= 0 || conf.LowestPriorityThreshold > 1 {
+		return nil, fmt.Errorf("lowest priority threshold must be in range (0, 1], got %v", conf.LowestPriorityThreshold)
+	}
+
+	if conf.DefaultPriority < 0 {
+		return nil, fmt.Errorf("default priority must not be negative, got %d", conf.DefaultPriority)
+	}
+
+	rules := make([]shedding.Rule, 0, len(conf.Rules))
+	for _, ruleConf := range conf.Rules {
+		if ruleConf.Priority < 0 {
+			return nil, fmt.Errorf("priority of shedding rule must not be negative, got %d", ruleConf.Priority)
+		}
+
+		networks, err := acl.ParsePrefixes(ruleConf.CIDRs)
+		if err != nil {
+			return nil, fmt.Errorf("parse cidrs of shedding rule: %w", err)
+		}
+
+		rules = append(rules, shedding.Rule{
+			Priority:   ruleConf.Priority,
+			PathPrefix: ruleConf.PathPrefix,
+			Header:     ruleConf.Header,
+			Value:      ruleConf.Value,
+			Networks:   networks,
+		})
+	}
+
+	queued := func() int {
+		total := 0
+		for _, queue := range queues {
+			total += queue.Queued()
+		}
+
+		return total
+	}
+
+	return shedding.New(
+		logger,
+		next,
+		shedding.NewClassifier(rules, conf.DefaultPriority, clientIP),
+		shedding.Settings{
+			MaxInFlight:     conf.MaxInFlight,
+			MaxQueued:       conf.MaxQueued,
+			LowestThreshold: conf.LowestPriorityThreshold,
+		},
+		queued,
+		errorPages,
+		func(priority int, reason string) {
+			appMetrics.ShedRequests.WithLabelValues(strconv.Itoa(priority), reason).Inc()
+		},
+	), nil
+}
+
+// createRateLimitStore creates store of token buckets. Redis store falls back to local one when Redis fails.
+func createRateLimitStore(
+	ctx context.Context,
+	logger *slog.Logger,
+	appMetrics *metrics.Metrics,
+	conf config.RateLimitStore,
+) (ratelimit.Store, error) {
+	// cleanupInterval is the interval of removing unused local buckets.
+	const cleanupInterval = time.Minute
+
+	local := ratelimit.NewLocal()
+	go local.Run(ctx, cleanupInterval)
+
+	switch conf.Type {
+	case "local":
+		return local, nil
+	case "redis":
+		if conf.Redis.Address == "" {
+			return nil, errors.New("address of redis rate limit store is not set")
+		}
+
+		timeout := time.Duration(conf.Redis.TimeoutMilliseconds) * time.Millisecond
+		client := redis.NewClient(&redis.Options{
+			Addr:         conf.Redis.Address,
+			Username:     conf.Redis.Username,
+			Password:     conf.Redis.Password,
+			DB:           conf.Redis.DB,
+			DialTimeout:  timeout,
+			ReadTimeout:  timeout,
+			WriteTimeout: timeout,
+			// Failures are handled by fallback, retries would only delay requests.
+			MaxRetries:    -1,
+			DialerRetries: 1,
+		})
+
+		return ratelimit.NewFallback(
+			logger.With(slog.String("rate_limit_store", conf.Type)),
+			ratelimit.NewRedis(client, conf.Redis.KeyPrefix),
+			local,
+			time.Duration(conf.FallbackRetrySeconds)*time.Second,
+			func() {
+				appMetrics.RateLimitFallbacks.Inc()
+			},
 		), nil
 	default:
-		return nil, fmt.Errorf("unknown strategy: %s", conf.Strategy)
+		return nil, fmt.Errorf("unknown type of rate limit store: %s", conf.Type)
 	}
 }
 
-func runHealthCheckers(ctx context.Context, logger *slog.Logger, conf config.Balancer, observer health.Observer) {
-	client := &http.Client{}
-
-	for _, backend := range conf.Backends {
-		checker := health.NewChecker(
-			logger,
-			client,
-			backend,
-			createURLString,
-			time.Duration(conf.Heathcheck.CheckTimeoutSeconds)*time.Second,
-			time.Duration(conf.Heathcheck.RequestTimeoutSeconds)*time.Second,
-			observer,
-		)
+func createErrorPages(conf config.ErrorPages) (*errorpage.Renderer, error) {
+	files := make(map[int]errorpage.TemplateFiles, len(conf.Templates))
+	for statusCode, templates := range conf.Templates {
+		files[statusCode] = errorpage.TemplateFiles{
+			JSON: templates.JSON,
+			HTML: templates.HTML,
+			Text: templates.Text,
+		}
+	}
 
-		go checker.Run(ctx)
+	return errorpage.NewRenderer(files, conf.InterceptBackendErrors)
+}
+
+func runAdminServer(
+	ctx context.Context,
+	logger *slog.Logger,
+	conf config.Admin,
+	appMetrics *metrics.Metrics,
+	adaptiveLimiters map[string]*concurrency.Adaptive,
+) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", appMetrics.Handler())
+	mux.HandleFunc("GET /concurrency", func(w http.ResponseWriter, _ *http.Request) {
+		limits := make(map[string]map[string]concurrency.Limit, len(adaptiveLimiters))
+		for pool, limiter := range adaptiveLimiters {
+			limits[pool] = limiter.Limits()
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(limits); err != nil {
+			logger.Warn("Write concurrency limits",
+				slog.String("error", err.Error()))
+		}
+	})
+
+	server := http.Server{
+		Addr:              fmt.Sprintf("0.0.0.0:%d", conf.Port),
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		if err := server.Shutdown(context.TODO()); err != nil {
+			logger.Warn("Shutdown admin server",
+				slog.String("error", err.Error()))
+		}
+	}()
+
+	logger.Info("Admin server listen",
+		slog.String("address", server.Addr),
+	)
+
+	if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		logger.Warn("Admin server ListenAndServe",
+			slog.String("error", err.Error()))
 	}
 }
 
@@ -0,0 +1,690 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/AleksandrMatsko/cloudru-balancer/internal/acl"
+	"github.com/AleksandrMatsko/cloudru-balancer/internal/affinity"
+	"github.com/AleksandrMatsko/cloudru-balancer/internal/auth"
+	"github.com/AleksandrMatsko/cloudru-balancer/internal/balancer"
+	"github.com/AleksandrMatsko/cloudru-balancer/internal/breaker"
+	"github.com/AleksandrMatsko/cloudru-balancer/internal/cache"
+	"github.com/AleksandrMatsko/cloudru-balancer/internal/coalesce"
+	"github.com/AleksandrMatsko/cloudru-balancer/internal/concurrency"
+	"github.com/AleksandrMatsko/cloudru-balancer/internal/config"
+	"github.com/AleksandrMatsko/cloudru-balancer/internal/discovery"
+	"github.com/AleksandrMatsko/cloudru-balancer/internal/errorpage"
+	"github.com/AleksandrMatsko/cloudru-balancer/internal/health"
+	"github.com/AleksandrMatsko/cloudru-balancer/internal/limits"
+	"github.com/AleksandrMatsko/cloudru-balancer/internal/metrics"
+	"github.com/AleksandrMatsko/cloudru-balancer/internal/mirror"
+	"github.com/AleksandrMatsko/cloudru-balancer/internal/ratelimit"
+	"github.com/AleksandrMatsko/cloudru-balancer/internal/strategies"
+)
+
+// pool is the handler of the pool with its middlewares, which can tell if backend belongs to the pool.
+type pool struct {
+	http.Handler
+	balancer *balancer.Balancer
+}
+
+// HasBackend reports if the pool sends requests to given backend.
+func (p pool) HasBackend(backend string) bool {
+	return p.balancer.HasBackend(backend)
+}
+
+// wrapPool wraps balancer of the pool with middlewares configured for the pool.
+func wrapPool(
+	logger *slog.Logger,
+	appMetrics *metrics.Metrics,
+	name string,
+	conf config.Pool,
+	balancers map[string]*balancer.Balancer,
+	errorPages *errorpage.Renderer,
+	defaultLimits config.Limits,
+	clientIP *acl.ClientIP,
+	rateLimitStore ratelimit.Store,
+) (http.Handler, error) {
+	var handler http.Handler = balancers[name]
+
+	// Requests pinned to backend with debug header must receive response of that backend.
+	// Responses to authenticated clients are personal and must not be shared, credentials
+	// may already be removed from request, so they are not a part of cache and coalescing keys.
+	bypass := func(r *http.Request) bool {
+		_, pinned := balancer.PinnedBackend(r.Context())
+		_, authenticated := auth.IdentityFromContext(r.Context())
+
+		return pinned || authenticated
+	}
+
+	if conf.Coalesce.Enabled {
+		handler = coalesce.New(
+			handler,
+			coalesce.Settings{
+				KeyHeaders:   conf.Coalesce.KeyHeaders,
+				MaxBodyBytes: conf.Coalesce.MaxBodyBytes,
+				Bypass:       bypass,
+			},
+			func(result string) {
+				appMetrics.CoalesceRequests.WithLabelValues(name, result).Inc()
+			},
+		)
+	}
+
+	if conf.Cache.Enabled {
+		handler = cache.New(
+			handler,
+			cache.Settings{
+				MaxBytes:      conf.Cache.MaxBytes,
+				MaxEntryBytes: conf.Cache.MaxEntryBytes,
+				Bypass:        bypass,
+			},
+			func(result string) {
+				appMetrics.CacheRequests.WithLabelValues(name, strings.ToLower(result)).Inc()
+			},
+			func(bytes int64) {
+				appMetrics.CacheSize.WithLabelValues(name).Set(float64(bytes))
+			},
+		)
+	}
+
+	if conf.Mirror.Pool != "" {
+		shadow, ok := balancers[conf.Mirror.Pool]
+		if !ok || conf.Mirror.Pool == name {
+			return nil, fmt.Errorf("invalid mirror pool: %s", conf.Mirror.Pool)
+		}
+
+		handler = mirror.New(
+			logger,
+			handler,
+			shadow,
+			mirror.Settings{
+				SampleRate:   conf.Mirror.SampleRate,
+				MaxBodyBytes: conf.Mirror.MaxBodyBytes,
+				Timeout:      time.Duration(conf.Mirror.TimeoutMilliseconds) * time.Millisecond,
+				MaxInFlight:  int(conf.Mirror.MaxInFlight),
+			},
+			func(primaryStatus, shadowStatus int) {
+				appMetrics.MirrorResponses.WithLabelValues(
+					name,
+					conf.Mirror.Pool,
+					strconv.Itoa(primaryStatus),
+					strconv.Itoa(shadowStatus),
+				).Inc()
+			},
+			func(reason string) {
+				appMetrics.MirrorSkipped.WithLabelValues(name, conf.Mirror.Pool, reason).Inc()
+			},
+		)
+	}
+
+	poolLimits := conf.Limits.WithDefaults(defaultLimits)
+	if poolLimits != (config.Limits{}) {
+		handler = limits.New(
+			logger,
+			handler,
+			limits.Settings{
+				MaxBodyBytes:   poolLimits.MaxBodyBytes,
+				MaxHeaderBytes: poolLimits.MaxHeaderBytes,
+				MaxURLLength:   poolLimits.MaxURLLength,
+			},
+			errorPages,
+			func(limit string) {
+				appMetrics.LimitViolations.WithLabelValues(name, limit).Inc()
+			},
+		)
+	}
+
+	if conf.RateLimit.Capacity > 0 {
+		settings, err := createRateLimitSettings(name, conf.RateLimit)
+		if err != nil {
+			return nil, err
+		}
+
+		handler = ratelimit.New(logger, handler, rateLimitStore, settings, clientIP, errorPages, func(result string) {
+			appMetrics.RateLimitRequests.WithLabelValues(name, result).Inc()
+		})
+	}
+
+	authenticators, err := createAuthenticators(conf.Auth)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(authenticators) != 0 {
+		handler = auth.New(
+			logger,
+			handler,
+			authenticators,
+			auth.Settings{
+				SubjectHeader: conf.Auth.SubjectHeader,
+				MethodHeader:  conf.Auth.MethodHeader,
+			},
+			errorPages,
+			func(method, result string) {
+				appMetrics.AuthRequests.WithLabelValues(name, method, result).Inc()
+			},
+		)
+	}
+
+	if len(conf.Access.Rules) != 0 || conf.Access.Default != string(acl.ActionAllow) {
+		policy, err := createPolicy(conf.Access)
+		if err != nil {
+			return nil, err
+		}
+
+		handler, err = acl.New(logger, handler, clientIP, policy, errorPages, func() {
+			appMetrics.AccessDenied.WithLabelValues(name).Inc()
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return handler, nil
+}
+
+// createRateLimitSettings creates settings of rate limit of the pool. Buckets of pools are separate.
+func createRateLimitSettings(pool string, conf config.RateLimit) (ratelimit.Settings, error) {
+	if conf.RatePerSecond <= 0 {
+		return ratelimit.Settings{}, errors.New("rate per second of rate limit must be positive")
+	}
+
+	settings := ratelimit.Settings{
+		Default: ratelimit.Bucket{
+			Capacity:      conf.Capacity,
+			RatePerSecond: conf.RatePerSecond,
+		},
+		Clients:   make(map[string]ratelimit.Bucket, len(conf.Clients)),
+		KeyPrefix: pool + ":",
+	}
+
+	for _, client := range conf.Clients {
+		if client.Capacity <= 0 || client.RatePerSecond <= 0 {
+			return ratelimit.Settings{}, fmt.Errorf("capacity and rate per second of rate limit of client %s must be positive", client.Client)
+		}
+
+		settings.Clients[client.Client] = ratelimit.Bucket{
+			Capacity:      client.Capacity,
+			RatePerSecond: client.RatePerSecond,
+		}
+	}
+
+	return settings, nil
+}
+
+// createAuthenticators creates authenticators for methods enabled in config.
+func createAuthenticators(conf config.Auth) ([]auth.Authenticator, error) {
+	var authenticators []auth.Authenticator
+
+	if len(conf.APIKeys.Keys) != 0 {
+		if conf.APIKeys.Header == "" && conf.APIKeys.Query == "" {
+			return nil, errors.New("header or query must be set for api keys")
+		}
+
+		keys := make(map[string]string, len(conf.APIKeys.Keys))
+		for _, key := range conf.APIKeys.Keys {
+			if key.Key == "" || key.Subject == "" {
+				return nil, errors.New("key and subject must be set for api key")
+			}
+
+			keys[key.Key] = key.Subject
+		}
+
+		authenticators = append(authenticators, auth.NewAPIKeys(conf.APIKeys.Header, conf.APIKeys.Query, keys))
+	}
+
+	if conf.Basic.HtpasswdFile != "" {
+		basic, err := auth.LoadHtpasswd(conf.Basic.HtpasswdFile, conf.Basic.Realm)
+		if err != nil {
+			return nil, fmt.Errorf("load htpasswd file: %w", err)
+		}
+
+		authenticators = append(authenticators, basic)
+	}
+
+	if conf.JWT.JWKSFile != "" {
+		jwt, err := auth.LoadJWT(conf.JWT.JWKSFile, auth.JWTSettings{
+			Issuer:       conf.JWT.Issuer,
+			Audience:     conf.JWT.Audience,
+			Leeway:       time.Duration(conf.JWT.LeewaySeconds) * time.Second,
+			SubjectClaim: conf.JWT.SubjectClaim,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("load JWKS file: %w", err)
+		}
+
+		authenticators = append(authenticators, jwt)
+	}
+
+	return authenticators, nil
+}
+
+// createPool creates balancer for the pool of backends together with its health checks and discovery.
+// Concurrency limiter of the pool is returned if it is enabled.
+func createPool(
+	ctx context.Context,
+	logger *slog.Logger,
+	appMetrics *metrics.Metrics,
+	name string,
+	conf config.Pool,
+	errorPages *errorpage.Renderer,
+) (*balancer.Balancer, balancer.ConcurrencyLimiter, error) {
+	var (
+		strategyOptions []strategies.Option
+		observers       balancer.ResponseObservers
+		breakers        *breaker.Group
+		limiter         balancer.ConcurrencyLimiter
+		limiterSet      discovery.BackendSet
+	)
+
+	if conf.CircuitBreaker.Enabled {
+		var err error
+		breakers, err = createBreakers(logger, conf)
+		if err != nil {
+			return nil, nil, fmt.Errorf("configure circuit breaker: %w", err)
+		}
+
+		strategyOptions = append(strategyOptions, strategies.WithFilter(breakers))
+		observers = append(observers, breakers)
+	}
+
+	if conf.SlowStart.WindowSeconds > 0 {
+		slowStart, err := createSlowStart(conf.SlowStart)
+		if err != nil {
+			return nil, nil, fmt.Errorf("configure slow start: %w", err)
+		}
+
+		strategyOptions = append(strategyOptions, strategies.WithSlowStart(slowStart))
+	}
+
+	if conf.Concurrency.MaxPerBackend > 0 && conf.AdaptiveConcurrency.Algorithm != "" {
+		return nil, nil, errors.New("concurrency and adaptive concurrency limits can not be used together")
+	}
+
+	if conf.Concurrency.MaxPerBackend > 0 {
+		static, err := createConcurrencyLimiter(appMetrics, name, conf.Concurrency)
+		if err != nil {
+			return nil, nil, fmt.Errorf("configure concurrency limit: %w", err)
+		}
+
+		limiter, limiterSet = static, static
+		strategyOptions = append(strategyOptions, strategies.WithLoad(static))
+	}
+
+	if conf.AdaptiveConcurrency.Algorithm != "" {
+		adaptive, err := createAdaptiveLimiter(appMetrics, name, conf.AdaptiveConcurrency)
+		if err != nil {
+			return nil, nil, fmt.Errorf("configure adaptive concurrency limit: %w", err)
+		}
+
+		limiter, limiterSet = adaptive, adaptive
+		observers = append(observers, adaptive)
+	}
+
+	if limiterSet != nil {
+		for _, backend := range conf.BackendAddresses() {
+			limiterSet.AddBackend(backend)
+		}
+	}
+
+	strategy, err := createStrategy(logger, appMetrics, name, conf, strategyOptions...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("select balancing strategy: %w", err)
+	}
+
+	if strategyObserver, ok := strategy.(balancer.ResponseObserver); ok {
+		observers = append(observers, strategyObserver)
+	}
+
+	provider, err := createDiscoveryProvider(conf.Discovery)
+	if err != nil {
+		return nil, nil, fmt.Errorf("create discovery provider: %w", err)
+	}
+
+	affinityCookie, err := createAffinityCookie(conf.Affinity)
+	if err != nil {
+		return nil, nil, fmt.Errorf("create affinity cookie: %w", err)
+	}
+
+	healthCheckers := createHealthCheckers(ctx, logger, conf, strategy)
+
+	poolBalancer := balancer.NewBalancer(
+		logger,
+		strategy,
+		conf.BackendAddresses(),
+		createURL,
+		createTransport(conf.Timeouts),
+		balancer.WithRequestTimeout(time.Duration(conf.Timeouts.RequestSeconds)*time.Second),
+		balancer.WithErrorPages(errorPages),
+		balancer.WithAffinity(affinityCookie),
+		balancer.WithObserver(observers),
+		balancer.WithLimiter(limiter),
+	)
+
+	if provider != nil {
+		sets := []discovery.BackendSet{poolBalancer}
+		if breakers != nil {
+			sets = append(sets, breakers)
+		}
+		if limiterSet != nil {
+			sets = append(sets, limiterSet)
+		}
+		sets = append(sets, strategy, healthCheckers)
+
+		watcher := discovery.NewWatcher(
+			logger.With(slog.String("discovery", conf.Discovery.Type)),
+			provider,
+			time.Duration(conf.Discovery.RefreshSeconds)*time.Second,
+			discovery.NewMembers(conf.BackendAddresses(), sets...),
+			conf.BackendAddresses(),
+		)
+
+		go watcher.Run(ctx)
+	}
+
+	return poolBalancer, limiter, nil
+}
+
+func createTransport(conf config.Timeouts) *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = (&net.Dialer{
+		Timeout:   time.Duration(conf.DialMilliseconds) * time.Millisecond,
+		KeepAlive: 30 * time.Second,
+	}).DialContext
+	transport.TLSHandshakeTimeout = time.Duration(conf.TLSHandshakeMilliseconds) * time.Millisecond
+	transport.ResponseHeaderTimeout = time.Duration(conf.ResponseHeaderMilliseconds) * time.Millisecond
+	transport.IdleConnTimeout = time.Duration(conf.IdleConnSeconds) * time.Second
+
+	return transport
+}
+
+func createAffinityCookie(conf config.Affinity) (*affinity.Cookie, error) {
+	if !conf.Enabled {
+		return nil, nil
+	}
+
+	if conf.SigningKey == "" {
+		return nil, errors.New("signing key for affinity cookie is not set")
+	}
+
+	return affinity.NewCookie(
+		conf.CookieName,
+		time.Duration(conf.TTLSeconds)*time.Second,
+		[]byte(conf.SigningKey),
+	), nil
+}
+
+func createConcurrencyLimiter(appMetrics *metrics.Metrics, pool string, conf config.Concurrency) (*concurrency.Limiter, error) {
+	if conf.QueueSize > 0 && conf.QueueTimeoutMilliseconds == 0 {
+		return nil, errors.New("queue timeout must be set if queue is enabled")
+	}
+
+	return concurrency.New(
+		concurrency.Settings{
+			MaxConcurrency: conf.MaxPerBackend,
+			QueueSize:      conf.QueueSize,
+			QueueTimeout:   time.Duration(conf.QueueTimeoutMilliseconds) * time.Millisecond,
+		},
+		func(backend string, depth int) {
+			appMetrics.ConcurrencyQueueDepth.WithLabelValues(pool, backend).Set(float64(depth))
+		},
+		func(backend string, wait time.Duration) {
+			appMetrics.ConcurrencyQueueWait.WithLabelValues(pool, backend).Observe(wait.Seconds())
+		},
+		func(backend, reason string) {
+			appMetrics.ConcurrencyRejected.WithLabelValues(pool, backend, reason).Inc()
+		},
+	), nil
+}
+
+func createAdaptiveLimiter(appMetrics *metrics.Metrics, pool string, conf config.AdaptiveConcurrency) (*concurrency.Adaptive, error) {
+	if conf.MinLimit < 1 || conf.MinLimit > conf.InitialLimit || conf.InitialLimit > conf.MaxLimit {
+		return nil, fmt.Errorf("limits must satisfy 1 <= min_limit <= initial_limit <= max_limit, got: %d, %d, %d",
+			conf.MinLimit, conf.InitialLimit, conf.MaxLimit)
+	}
+
+	var newAlgorithm func() concurrency.Algorithm
+
+	switch conf.Algorithm {
+	case "aimd":
+		if conf.AIMD.BackoffRatio <= 0 || conf.AIMD.BackoffRatio >= 1 {
+			return nil, fmt.Errorf("backoff ratio must be in range (0, 1), got: %v", conf.AIMD.BackoffRatio)
+		}
+
+		newAlgorithm = func() concurrency.Algorithm {
+			return &concurrency.AIMD{
+				BackoffRatio:     conf.AIMD.BackoffRatio,
+				LatencyThreshold: time.Duration(conf.AIMD.LatencyThresholdMilliseconds) * time.Millisecond,
+			}
+		}
+	case "gradient":
+		if conf.Gradient.Smoothing <= 0 || conf.Gradient.Smoothing > 1 {
+			return nil, fmt.Errorf("smoothing must be in range (0, 1], got: %v", conf.Gradient.Smoothing)
+		}
+
+		if conf.Gradient.Tolerance < 1 || conf.Gradient.Window < 1 {
+			return nil, errors.New("tolerance and window must be at least 1")
+		}
+
+		newAlgorithm = func() concurrency.Algorithm {
+			return &concurrency.Gradient{
+				Smoothing: conf.Gradient.Smoothing,
+				Tolerance: conf.Gradient.Tolerance,
+				Window:    conf.Gradient.Window,
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unknown algorithm: %s", conf.Algorithm)
+	}
+
+	return concurrency.NewAdaptive(
+		concurrency.AdaptiveSettings{
+			InitialLimit: conf.InitialLimit,
+			MinLimit:     conf.MinLimit,
+			MaxLimit:     conf.MaxLimit,
+			NewAlgorithm: newAlgorithm,
+		},
+		func(backend string, limit int) {
+			appMetrics.ConcurrencyLimit.WithLabelValues(pool, backend).Set(float64(limit))
+		},
+		func(backend, reason string) {
+			appMetrics.ConcurrencyRejected.WithLabelValues(pool, backend, reason).Inc()
+		},
+	), nil
+}
+
+type observingStrategy interface {
+	health.Observer
+	balancer.Strategy
+	discovery.BackendSet
+}
+
+func createStrategy(
+	logger *slog.Logger,
+	appMetrics *metrics.Metrics,
+	pool string,
+	conf config.Pool,
+	opts ...strategies.Option,
+) (observingStrategy, error) {
+	var newStrategy func(backends []string) strategies.Strategy
+
+	switch conf.Strategy {
+	case "RoundRobin":
+		newStrategy = func(backends []string) strategies.Strategy {
+			return strategies.NewRoundRobin(backends, opts...)
+		}
+	case "Random":
+		newStrategy = func(backends []string) strategies.Strategy {
+			return strategies.NewRandom(backends, opts...)
+		}
+	case "P2C":
+		newStrategy = func(backends []string) strategies.Strategy {
+			return strategies.NewP2C(backends, opts...)
+		}
+	default:
+		return nil, fmt.Errorf("unknown strategy: %s", conf.Strategy)
+	}
+
+	// Panic mode spreads requests across all backends, so priorities are not used in it.
+	newPlainStrategy := newStrategy
+
+	priorities := conf.BackendPriorities()
+	for _, priority := range priorities {
+		if priority != 0 {
+			newStrategy = func(backends []string) strategies.Strategy {
+				return strategies.NewPriority(backends, priorities, conf.PriorityGroups.MinHealthy, newPlainStrategy)
+			}
+			break
+		}
+	}
+
+	if conf.PanicThreshold < 0 || conf.PanicThreshold > 1 {
+		return nil, fmt.Errorf("panic threshold must be in range [0, 1], got: %v", conf.PanicThreshold)
+	}
+
+	if conf.PanicThreshold > 0 {
+		return strategies.NewPanic(
+			conf.BackendAddresses(),
+			conf.PanicThreshold,
+			newStrategy(conf.BackendAddresses()),
+			newPlainStrategy(conf.BackendAddresses()),
+			func(inPanic bool, healthy, total int) {
+				if inPanic {
+					appMetrics.PanicMode.WithLabelValues(pool).Set(1)
+					logger.Warn("Panic mode on, backend health is ignored",
+						slog.Int("healthy_backends", healthy),
+						slog.Int("total_backends", total),
+					)
+					return
+				}
+
+				appMetrics.PanicMode.WithLabelValues(pool).Set(0)
+				logger.Info("Panic mode off",
+					slog.Int("healthy_backends", healthy),
+					slog.Int("total_backends", total),
+				)
+			},
+		), nil
+	}
+
+	return newStrategy(conf.BackendAddresses()), nil
+}
+
+func createSlowStart(conf config.SlowStart) (strategies.SlowStart, error) {
+	if conf.MinWeight <= 0 || conf.MinWeight > 1 {
+		return strategies.SlowStart{}, fmt.Errorf("slow start min weight must be in range (0, 1], got: %v", conf.MinWeight)
+	}
+
+	var exponential bool
+
+	switch conf.Curve {
+	case "linear":
+	case "exponential":
+		exponential = true
+	default:
+		return strategies.SlowStart{}, fmt.Errorf("unknown slow start curve: %s", conf.Curve)
+	}
+
+	return strategies.SlowStart{
+		Window:      time.Duration(conf.WindowSeconds) * time.Second,
+		MinWeight:   conf.MinWeight,
+		Exponential: exponential,
+	}, nil
+}
+
+func createBreakers(logger *slog.Logger, conf config.Pool) (*breaker.Group, error) {
+	breakerConf := conf.CircuitBreaker
+
+	if breakerConf.FailureRateThreshold <= 0 || breakerConf.FailureRateThreshold > 1 {
+		return nil, fmt.Errorf("failure rate threshold must be in range (0, 1], got: %v", breakerConf.FailureRateThreshold)
+	}
+
+	if breakerConf.SlowCallRateThreshold < 0 || breakerConf.SlowCallRateThreshold > 1 {
+		return nil, fmt.Errorf("slow call rate threshold must be in range [0, 1], got: %v", breakerConf.SlowCallRateThreshold)
+	}
+
+	if breakerConf.HalfOpenMaxRequests == 0 {
+		return nil, errors.New("half open max requests must be positive")
+	}
+
+	return breaker.NewGroup(
+		logger,
+		breaker.Settings{
+			Window:                time.Duration(breakerConf.WindowSeconds) * time.Second,
+			MinRequests:           breakerConf.MinRequests,
+			FailureRateThreshold:  breakerConf.FailureRateThreshold,
+			SlowCallDuration:      time.Duration(breakerConf.SlowCallDurationMilliseconds) * time.Millisecond,
+			SlowCallRateThreshold: breakerConf.SlowCallRateThreshold,
+			OpenTimeout:           time.Duration(breakerConf.OpenTimeoutSeconds) * time.Second,
+			HalfOpenMaxRequests:   breakerConf.HalfOpenMaxRequests,
+		},
+		conf.BackendAddresses(),
+	), nil
+}
+
+func createHealthCheckers(
+	ctx context.Context,
+	logger *slog.Logger,
+	conf config.Pool,
+	observer health.Observer,
+) *health.Group {
+	client := &http.Client{}
+
+	return health.NewGroup(
+		ctx,
+		func(backend string) *health.Checker {
+			return health.NewChecker(
+				logger,
+				client,
+				backend,
+				createURLString,
+				time.Duration(conf.Heathcheck.CheckTimeoutSeconds)*time.Second,
+				time.Duration(conf.Heathcheck.RequestTimeoutSeconds)*time.Second,
+				observer,
+			)
+		},
+		conf.BackendAddresses(),
+	)
+}
+
+func createDiscoveryProvider(conf config.Discovery) (discovery.Provider, error) {
+	switch conf.Type {
+	case "":
+		return nil, nil
+	case "dns":
+		provider, err := discovery.NewDNS(
+			net.DefaultResolver,
+			conf.DNS.Name,
+			conf.DNS.Port,
+			conf.DNS.RecordType,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		return provider, nil
+	case "file":
+		if conf.File.Path == "" {
+			return nil, errors.New("path for file discovery is not set")
+		}
+
+		return discovery.NewFile(conf.File.Path), nil
+	case "http":
+		if conf.HTTP.URL == "" {
+			return nil, errors.New("url for http discovery is not set")
+		}
+
+		return discovery.NewHTTP(&http.Client{}, conf.HTTP.URL), nil
+	default:
+		return nil, fmt.Errorf("unknown discovery type: %s", conf.Type)
+	}
+}
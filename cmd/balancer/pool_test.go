@@ -0,0 +1,163 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/AleksandrMatsko/cloudru-balancer/internal/acl"
+	"github.com/AleksandrMatsko/cloudru-balancer/internal/balancer"
+	"github.com/AleksandrMatsko/cloudru-balancer/internal/config"
+	"github.com/AleksandrMatsko/cloudru-balancer/internal/metrics"
+	"github.com/AleksandrMatsko/cloudru-balancer/internal/ratelimit"
+	"github.com/AleksandrMatsko/cloudru-balancer/internal/strategies"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWrapPool_APIKeys(t *testing.T) {
+	t.Parallel()
+
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+
+		// Slow response, so concurrent requests would be coalesced if their keys were equal.
+		time.Sleep(100 * time.Millisecond)
+
+		w.Header().Set("Cache-Control", "max-age=60")
+		_, _ = w.Write([]byte(r.Header.Get("X-Subject")))
+	}))
+	defer server.Close()
+
+	serverURL, err := url.Parse(server.URL)
+	assert.Nil(t, err)
+
+	backends := []string{serverURL.Host}
+	strategy := strategies.NewRoundRobin(backends)
+	strategy.UpdateBackendHealth(serverURL.Host, true)
+
+	b := balancer.NewBalancer(
+		slog.Default(),
+		strategy,
+		backends,
+		func(string) *url.URL { return serverURL },
+		server.Client().Transport,
+		balancer.WithRequestTimeout(time.Second),
+	)
+
+	conf := config.DefaultForPool()
+	conf.Cache.Enabled = true
+	conf.Coalesce.Enabled = true
+	conf.Auth.APIKeys = config.APIKeysAuth{
+		Header: "X-Api-Key",
+		Keys: []config.APIKey{
+			{Key: "alice-key", Subject: "alice"},
+			{Key: "bob-key", Subject: "bob"},
+		},
+	}
+	conf.Auth.SubjectHeader = "X-Subject"
+
+	handler, err := wrapPool(
+		slog.Default(),
+		metrics.New(),
+		config.DefaultPool,
+		conf,
+		map[string]*balancer.Balancer{config.DefaultPool: b},
+		nil,
+		config.Limits{},
+		acl.NewClientIP(nil),
+		ratelimit.NewLocal(),
+	)
+	assert.Nil(t, err)
+
+	request := func(key string) string {
+		req := httptest.NewRequest(http.MethodGet, "http://test.url/data", nil)
+		req.Header.Set("X-Api-Key", key)
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		return rec.Body.String()
+	}
+
+	var wg sync.WaitGroup
+	bodies := make([]string, 2)
+	for i, key := range []string{"alice-key", "bob-key"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			bodies[i] = request(key)
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, []string{"alice", "bob"}, bodies)
+
+	// Responses are not cached for other clients either.
+	assert.Equal(t, "bob", request("bob-key"))
+	assert.Equal(t, "alice", request("alice-key"))
+	assert.Equal(t, int32(4), calls.Load())
+}
+
+func TestCreateStrategy_PanicThreshold(t *testing.T) {
+	t.Parallel()
+
+	conf := config.DefaultForPool()
+	conf.PanicThreshold = 1.5
+
+	_, err := createStrategy(slog.Default(), metrics.New(), config.DefaultPool, conf)
+	assert.NotNil(t, err)
+}
+
+func TestCreateBreakers(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name          string
+		modify        func(conf *config.CircuitBreaker)
+		expectedError bool
+	}{
+		{
+			name:   "default config",
+			modify: func(*config.CircuitBreaker) {},
+		},
+		{
+			name:          "zero failure rate threshold",
+			modify:        func(conf *config.CircuitBreaker) { conf.FailureRateThreshold = 0 },
+			expectedError: true,
+		},
+		{
+			name:          "failure rate threshold above 1",
+			modify:        func(conf *config.CircuitBreaker) { conf.FailureRateThreshold = 1.5 },
+			expectedError: true,
+		},
+		{
+			name:          "slow call rate threshold above 1",
+			modify:        func(conf *config.CircuitBreaker) { conf.SlowCallRateThreshold = 2 },
+			expectedError: true,
+		},
+		{
+			name:          "zero half open requests",
+			modify:        func(conf *config.CircuitBreaker) { conf.HalfOpenMaxRequests = 0 },
+			expectedError: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			conf := config.DefaultForPool()
+			test.modify(&conf.CircuitBreaker)
+
+			_, err := createBreakers(slog.Default(), conf)
+			assert.Equal(t, test.expectedError, err != nil)
+		})
+	}
+}
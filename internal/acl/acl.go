@@ -0,0 +1,143 @@
+// acl allows or denies requests by IP address of the client.
+package acl
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/netip"
+	"sync/atomic"
+
+	"github.com/AleksandrMatsko/cloudru-balancer/internal/errorpage"
+)
+
+// ErrCodeForbidden means that client is not allowed to send requests.
+const ErrCodeForbidden = "forbidden"
+
+// Action of the rule.
+type Action string
+
+const (
+	// ActionAllow sends request to the next handler.
+	ActionAllow Action = "allow"
+	// ActionDeny rejects request with 403 status code.
+	ActionDeny Action = "deny"
+)
+
+// Rule applies action to clients from networks.
+type Rule struct {
+	// Action to apply.
+	Action Action
+	// Networks of clients.
+	Networks []netip.Prefix
+}
+
+// Policy is the list of rules evaluated in order. The first rule matching client decides.
+// If no rule matches, Default action is applied.
+type Policy struct {
+	// Rules in order of evaluation.
+	Rules []Rule
+	// Default action.
+	Default Action
+}
+
+// Validate checks actions of the policy.
+func (p Policy) Validate() error {
+	for _, action := range append([]Action{p.Default}, actions(p.Rules)...) {
+		if action != ActionAllow && action != ActionDeny {
+			return fmt.Errorf("unknown access action: %s", action)
+		}
+	}
+
+	return nil
+}
+
+func actions(rules []Rule) []Action {
+	result := make([]Action, 0, len(rules))
+	for _, rule := range rules {
+		result = append(result, rule.Action)
+	}
+
+	return result
+}
+
+func (p Policy) decide(addr netip.Addr) Action {
+	if !addr.IsValid() {
+		return p.Default
+	}
+
+	for _, rule := range p.Rules {
+		if ContainsAddr(rule.Networks, addr) {
+			return rule.Action
+		}
+	}
+
+	return p.Default
+}
+
+// ACL checks client IP address before sending request to the next handler. Policy may be replaced at runtime.
+type ACL struct {
+	logger     *slog.Logger
+	next       http.Handler
+	clientIP   *ClientIP
+	policy     atomic.Pointer[Policy]
+	errorPages *errorpage.Renderer
+	onDeny     func()
+}
+
+// New creates ACL in front of next handler. onDeny is called for every denied request and may be nil.
+func New(
+	logger *slog.Logger,
+	next http.Handler,
+	clientIP *ClientIP,
+	policy Policy,
+	errorPages *errorpage.Renderer,
+	onDeny func(),
+) (*ACL, error) {
+	a := &ACL{
+		logger:     logger,
+		next:       next,
+		clientIP:   clientIP,
+		errorPages: errorPages,
+		onDeny:     onDeny,
+	}
+
+	if err := a.SetPolicy(policy); err != nil {
+		return nil, err
+	}
+
+	return a, nil
+}
+
+// SetPolicy replaces policy. If policy is invalid, previous one remains.
+func (a *ACL) SetPolicy(policy Policy) error {
+	if err := policy.Validate(); err != nil {
+		return err
+	}
+
+	a.policy.Store(&policy)
+
+	return nil
+}
+
+// ServeHTTP rejects request with 403 status code if client is denied.
+func (a *ACL) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	addr := a.clientIP.Resolve(r)
+
+	if a.policy.Load().decide(addr) == ActionDeny {
+		a.logger.Warn("Access denied",
+			slog.String("client_ip", addr.String()),
+			slog.String("method", r.Method),
+			slog.String("url", r.RequestURI),
+		)
+
+		if a.onDeny != nil {
+			a.onDeny()
+		}
+
+		a.errorPages.Write(w, r, http.StatusForbidden, ErrCodeForbidden, fmt.Errorf("access denied for %s", addr))
+		return
+	}
+
+	a.next.ServeHTTP(w, r)
+}
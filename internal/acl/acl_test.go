@@ -0,0 +1,115 @@
+package acl
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"github.com/AleksandrMatsko/cloudru-balancer/internal/errorpage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestACL(t *testing.T) {
+	t.Parallel()
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	office := Policy{
+		Rules: []Rule{
+			{Action: ActionDeny, Networks: []netip.Prefix{netip.MustParsePrefix("10.0.13.0/24")}},
+			{Action: ActionAllow, Networks: []netip.Prefix{netip.MustParsePrefix("10.0.0.0/16")}},
+		},
+		Default: ActionDeny,
+	}
+
+	tests := []struct {
+		name           string
+		policy         Policy
+		remoteAddr     string
+		expectedStatus int
+	}{
+		{
+			name:           "allowed by rule",
+			policy:         office,
+			remoteAddr:     "10.0.1.1:1234",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "denied by earlier rule",
+			policy:         office,
+			remoteAddr:     "10.0.13.1:1234",
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:           "denied by default",
+			policy:         office,
+			remoteAddr:     "203.0.113.1:1234",
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:           "allowed by default",
+			policy:         Policy{Default: ActionAllow},
+			remoteAddr:     "203.0.113.1:1234",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "unknown client",
+			policy:         office,
+			remoteAddr:     "@",
+			expectedStatus: http.StatusForbidden,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			denied := 0
+			a, err := New(slog.Default(), ok, NewClientIP(nil), test.policy, nil, func() {
+				denied++
+			})
+			assert.Nil(t, err)
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = test.remoteAddr
+
+			rec := httptest.NewRecorder()
+			a.ServeHTTP(rec, req)
+
+			assert.Equal(t, test.expectedStatus, rec.Code)
+
+			if test.expectedStatus == http.StatusForbidden {
+				var dto errorpage.ErrorResponse
+				assert.Nil(t, json.NewDecoder(rec.Body).Decode(&dto))
+				assert.Equal(t, ErrCodeForbidden, dto.ErrCode)
+				assert.Equal(t, 1, denied)
+			} else {
+				assert.Equal(t, 0, denied)
+			}
+		})
+	}
+}
+
+func TestACL_SetPolicy(t *testing.T) {
+	t.Parallel()
+
+	a, err := New(slog.Default(), http.NotFoundHandler(), NewClientIP(nil), Policy{Default: ActionAllow}, nil, nil)
+	assert.Nil(t, err)
+
+	assert.NotNil(t, a.SetPolicy(Policy{Default: "block"}))
+	assert.NotNil(t, a.SetPolicy(Policy{Default: ActionAllow, Rules: []Rule{{Action: ""}}}))
+	assert.Equal(t, ActionAllow, a.policy.Load().Default)
+
+	assert.Nil(t, a.SetPolicy(Policy{Default: ActionDeny}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+	a.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+}
@@ -0,0 +1,112 @@
+package acl
+
+import (
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// ClientIP resolves IP address of the client. X-Forwarded-For header is trusted
+// only when request comes from trusted proxies.
+type ClientIP struct {
+	trustedProxies []netip.Prefix
+}
+
+// NewClientIP creates ClientIP trusting given proxies.
+func NewClientIP(trustedProxies []netip.Prefix) *ClientIP {
+	return &ClientIP{trustedProxies: trustedProxies}
+}
+
+// Resolve returns IP address of the client. X-Forwarded-For is walked from the right,
+// the first address which is not a trusted proxy is the client.
+// If remote address can not be parsed, invalid address is returned.
+func (c *ClientIP) Resolve(r *http.Request) netip.Addr {
+	remote := parseAddr(r.RemoteAddr)
+	if !remote.IsValid() || !c.trusted(remote) {
+		return remote
+	}
+
+	forwarded := forwardedFor(r.Header)
+	client := remote
+
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		addr, err := netip.ParseAddr(forwarded[i])
+		if err != nil {
+			// Header is malformed, so addresses to the left of this one can not be trusted.
+			return client
+		}
+
+		client = addr.Unmap()
+		if !c.trusted(client) {
+			return client
+		}
+	}
+
+	return client
+}
+
+func (c *ClientIP) trusted(addr netip.Addr) bool {
+	return ContainsAddr(c.trustedProxies, addr)
+}
+
+// ContainsAddr reports if any of prefixes contains the address.
+func ContainsAddr(prefixes []netip.Prefix, addr netip.Addr) bool {
+	for _, prefix := range prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func parseAddr(remoteAddr string) netip.Addr {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return netip.Addr{}
+	}
+
+	return addr.Unmap()
+}
+
+func forwardedFor(header http.Header) []string {
+	var addrs []string
+	for _, value := range header.Values("X-Forwarded-For") {
+		for addr := range strings.SplitSeq(value, ",") {
+			addrs = append(addrs, strings.TrimSpace(addr))
+		}
+	}
+
+	return addrs
+}
+
+// ParsePrefixes parses list of CIDRs. Single IP address is parsed as prefix containing only it.
+func ParsePrefixes(cidrs []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		if !strings.Contains(cidr, "/") {
+			addr, err := netip.ParseAddr(cidr)
+			if err != nil {
+				return nil, err
+			}
+
+			prefixes = append(prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+
+		prefix, err := netip.ParsePrefix(cidr)
+		if err != nil {
+			return nil, err
+		}
+
+		prefixes = append(prefixes, prefix.Masked())
+	}
+
+	return prefixes, nil
+}
@@ -0,0 +1,116 @@
+package acl
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientIP_Resolve(t *testing.T) {
+	t.Parallel()
+
+	clientIP := NewClientIP([]netip.Prefix{
+		netip.MustParsePrefix("10.0.0.0/8"),
+		netip.MustParsePrefix("fd00::/8"),
+	})
+
+	tests := []struct {
+		name           string
+		remoteAddr     string
+		forwardedFor   []string
+		expectedClient string
+	}{
+		{
+			name:           "without proxy",
+			remoteAddr:     "203.0.113.1:1234",
+			expectedClient: "203.0.113.1",
+		},
+		{
+			name:           "untrusted proxy",
+			remoteAddr:     "203.0.113.1:1234",
+			forwardedFor:   []string{"198.51.100.1"},
+			expectedClient: "203.0.113.1",
+		},
+		{
+			name:           "trusted proxy",
+			remoteAddr:     "10.0.0.1:1234",
+			forwardedFor:   []string{"198.51.100.1"},
+			expectedClient: "198.51.100.1",
+		},
+		{
+			name:           "spoofed header behind trusted proxy",
+			remoteAddr:     "10.0.0.1:1234",
+			forwardedFor:   []string{"192.0.2.1, 198.51.100.1"},
+			expectedClient: "198.51.100.1",
+		},
+		{
+			name:           "chain of trusted proxies",
+			remoteAddr:     "10.0.0.1:1234",
+			forwardedFor:   []string{"192.0.2.1, 198.51.100.1", "10.0.0.2"},
+			expectedClient: "198.51.100.1",
+		},
+		{
+			name:           "all trusted",
+			remoteAddr:     "10.0.0.1:1234",
+			forwardedFor:   []string{"10.0.0.3, 10.0.0.2"},
+			expectedClient: "10.0.0.3",
+		},
+		{
+			name:           "malformed header",
+			remoteAddr:     "10.0.0.1:1234",
+			forwardedFor:   []string{"198.51.100.1, unknown, 10.0.0.2"},
+			expectedClient: "10.0.0.2",
+		},
+		{
+			name:           "ipv6",
+			remoteAddr:     "[fd00::1]:1234",
+			forwardedFor:   []string{"2001:db8::1"},
+			expectedClient: "2001:db8::1",
+		},
+		{
+			name:           "ipv4 mapped to ipv6",
+			remoteAddr:     "[::ffff:203.0.113.1]:1234",
+			expectedClient: "203.0.113.1",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = test.remoteAddr
+			for _, value := range test.forwardedFor {
+				req.Header.Add("X-Forwarded-For", value)
+			}
+
+			assert.Equal(t, test.expectedClient, clientIP.Resolve(req).String())
+		})
+	}
+}
+
+func TestParsePrefixes(t *testing.T) {
+	t.Parallel()
+
+	t.Run("with cidrs and addresses", func(t *testing.T) {
+		t.Parallel()
+
+		prefixes, err := ParsePrefixes([]string{"10.1.2.3/8", "192.0.2.1", "2001:db8::/32"})
+		assert.Nil(t, err)
+		assert.Equal(t, []netip.Prefix{
+			netip.MustParsePrefix("10.0.0.0/8"),
+			netip.MustParsePrefix("192.0.2.1/32"),
+			netip.MustParsePrefix("2001:db8::/32"),
+		}, prefixes)
+	})
+
+	t.Run("with invalid cidr", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := ParsePrefixes([]string{"10.0.0.0/33"})
+		assert.NotNil(t, err)
+	})
+}
@@ -0,0 +1,85 @@
+// affinity binds clients to backends with signed cookies.
+package affinity
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Cookie issues and verifies signed cookies identifying chosen backend.
+// Cookie value has format <base64(backend)>.<expiration unix time>.<base64(hmac-sha256)>.
+type Cookie struct {
+	name string
+	ttl  time.Duration
+	key  []byte
+	now  func() time.Time
+}
+
+// NewCookie creates Cookie with given name, time to live and signing key.
+func NewCookie(name string, ttl time.Duration, key []byte) *Cookie {
+	return &Cookie{
+		name: name,
+		ttl:  ttl,
+		key:  key,
+		now:  time.Now,
+	}
+}
+
+// Backend returns backend from valid affinity cookie of the request.
+func (c *Cookie) Backend(r *http.Request) (string, bool) {
+	cookie, err := r.Cookie(c.name)
+	if err != nil {
+		return "", false
+	}
+
+	parts := strings.Split(cookie.Value, ".")
+	if len(parts) != 3 {
+		return "", false
+	}
+
+	encodedBackend, expiresAt, signature := parts[0], parts[1], parts[2]
+
+	expected := c.sign(encodedBackend, expiresAt)
+	if !hmac.Equal([]byte(signature), []byte(expected)) {
+		return "", false
+	}
+
+	expiresAtUnix, err := strconv.ParseInt(expiresAt, 10, 64)
+	if err != nil || c.now().Unix() > expiresAtUnix {
+		return "", false
+	}
+
+	backend, err := base64.RawURLEncoding.DecodeString(encodedBackend)
+	if err != nil {
+		return "", false
+	}
+
+	return string(backend), true
+}
+
+// Set affinity cookie for the backend to the response.
+func (c *Cookie) Set(w http.ResponseWriter, backend string) {
+	encodedBackend := base64.RawURLEncoding.EncodeToString([]byte(backend))
+	expiresAt := strconv.FormatInt(c.now().Add(c.ttl).Unix(), 10)
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     c.name,
+		Value:    encodedBackend + "." + expiresAt + "." + c.sign(encodedBackend, expiresAt),
+		Path:     "/",
+		MaxAge:   int(c.ttl.Seconds()),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+func (c *Cookie) sign(encodedBackend, expiresAt string) string {
+	mac := hmac.New(sha256.New, c.key)
+	mac.Write([]byte(encodedBackend + "." + expiresAt))
+
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,75 @@
+package affinity
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func issue(c *Cookie, backend string) *http.Cookie {
+	recorder := httptest.NewRecorder()
+	c.Set(recorder, backend)
+
+	return recorder.Result().Cookies()[0]
+}
+
+func TestCookie(t *testing.T) {
+	now := time.Unix(1000, 0)
+	c := NewCookie("affinity", time.Minute, []byte("secret"))
+	c.now = func() time.Time { return now }
+
+	t.Run("with valid cookie", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "http://test.url", nil)
+		req.AddCookie(issue(c, "10.0.0.1:8081"))
+
+		backend, ok := c.Backend(req)
+		assert.True(t, ok)
+		assert.Equal(t, "10.0.0.1:8081", backend)
+	})
+
+	t.Run("without cookie", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "http://test.url", nil)
+
+		_, ok := c.Backend(req)
+		assert.False(t, ok)
+	})
+
+	t.Run("with tampered cookie", func(t *testing.T) {
+		cookie := issue(c, "10.0.0.1:8081")
+		other := issue(c, "10.0.0.2:8081")
+		cookie.Value = strings.Split(other.Value, ".")[0] + cookie.Value[strings.Index(cookie.Value, "."):]
+
+		req := httptest.NewRequest(http.MethodGet, "http://test.url", nil)
+		req.AddCookie(cookie)
+
+		_, ok := c.Backend(req)
+		assert.False(t, ok)
+	})
+
+	t.Run("with cookie signed by other key", func(t *testing.T) {
+		other := NewCookie("affinity", time.Minute, []byte("other secret"))
+
+		req := httptest.NewRequest(http.MethodGet, "http://test.url", nil)
+		req.AddCookie(issue(other, "10.0.0.1:8081"))
+
+		_, ok := c.Backend(req)
+		assert.False(t, ok)
+	})
+
+	t.Run("with expired cookie", func(t *testing.T) {
+		cookie := issue(c, "10.0.0.1:8081")
+
+		expired := NewCookie("affinity", time.Minute, []byte("secret"))
+		expired.now = func() time.Time { return now.Add(2 * time.Minute) }
+
+		req := httptest.NewRequest(http.MethodGet, "http://test.url", nil)
+		req.AddCookie(cookie)
+
+		_, ok := expired.Backend(req)
+		assert.False(t, ok)
+	})
+}
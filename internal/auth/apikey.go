@@ -0,0 +1,82 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+)
+
+// MethodAPIKey is the name of authentication with static API keys.
+const MethodAPIKey = "api_key"
+
+var errUnknownAPIKey = errors.New("unknown api key")
+
+// APIKeys authenticates requests with static API keys sent in header or query parameter.
+// The key is removed from request, so it does not reach backends.
+type APIKeys struct {
+	header string
+	query  string
+	keys   map[string]string
+}
+
+// NewAPIKeys creates APIKeys. Keys map API key to subject of the client.
+// Empty header or query disables the source.
+func NewAPIKeys(header, query string, keys map[string]string) *APIKeys {
+	return &APIKeys{
+		header: header,
+		query:  query,
+		keys:   keys,
+	}
+}
+
+// Method returns MethodAPIKey.
+func (a *APIKeys) Method() string {
+	return MethodAPIKey
+}
+
+// Challenge returns empty string as there is no standard challenge for API keys.
+func (a *APIKeys) Challenge() string {
+	return ""
+}
+
+// Authenticate returns identity of the client owning API key.
+func (a *APIKeys) Authenticate(r *http.Request) (Identity, error) {
+	key, ok := a.extract(r)
+	if !ok {
+		return Identity{}, ErrNoCredentials
+	}
+
+	// All keys are compared, so time of the check does not depend on which key matches.
+	subject, found := "", false
+	for candidate, candidateSubject := range a.keys {
+		if subtle.ConstantTimeCompare([]byte(candidate), []byte(key)) == 1 {
+			subject, found = candidateSubject, true
+		}
+	}
+
+	if !found {
+		return Identity{}, errUnknownAPIKey
+	}
+
+	return Identity{Subject: subject, Method: MethodAPIKey}, nil
+}
+
+func (a *APIKeys) extract(r *http.Request) (string, bool) {
+	if a.header != "" {
+		if key := r.Header.Get(a.header); key != "" {
+			r.Header.Del(a.header)
+			return key, true
+		}
+	}
+
+	if a.query != "" {
+		query := r.URL.Query()
+		if key := query.Get(a.query); key != "" {
+			query.Del(a.query)
+			r.URL.RawQuery = query.Encode()
+			return key, true
+		}
+	}
+
+	return "", false
+}
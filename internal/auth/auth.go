@@ -0,0 +1,166 @@
+// auth authenticates requests before they reach backends.
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/AleksandrMatsko/cloudru-balancer/internal/errorpage"
+)
+
+// ErrCodeUnauthorized means that request has no valid credentials.
+const ErrCodeUnauthorized = "unauthorized"
+
+// Results passed to callback.
+const (
+	ResultAuthenticated = "authenticated"
+	ResultInvalid       = "invalid"
+	ResultMissing       = "missing"
+)
+
+// ErrNoCredentials is returned by Authenticator if request has no credentials it can check.
+var ErrNoCredentials = errors.New("no credentials")
+
+// Identity of authenticated client.
+type Identity struct {
+	// Subject is the name of the client, like user name or subject of the token.
+	Subject string
+	// Method of authentication, like basic.
+	Method string
+}
+
+type identityKey struct{}
+
+// WithIdentity returns context carrying identity of the client.
+func WithIdentity(ctx context.Context, identity Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, identity)
+}
+
+// IdentityFromContext returns identity of the client authenticated by Auth, for example, to use it as key of rate limiting.
+func IdentityFromContext(ctx context.Context) (Identity, bool) {
+	identity, ok := ctx.Value(identityKey{}).(Identity)
+	return identity, ok
+}
+
+// Authenticator checks credentials of one kind.
+type Authenticator interface {
+	// Method returns name of authentication method.
+	Method() string
+	// Authenticate returns identity of the client. If request has no credentials of this kind,
+	// ErrNoCredentials is returned. Authenticator may remove credentials from request.
+	Authenticate(r *http.Request) (Identity, error)
+	// Challenge returns value of WWW-Authenticate header or empty string.
+	Challenge() string
+}
+
+// Settings of Auth.
+type Settings struct {
+	// SubjectHeader is set to subject of the client before request is sent to the next handler.
+	SubjectHeader string
+	// MethodHeader is set to method of authentication before request is sent to the next handler.
+	MethodHeader string
+}
+
+// Auth sends to the next handler only requests accepted by one of authenticators.
+type Auth struct {
+	logger         *slog.Logger
+	next           http.Handler
+	authenticators []Authenticator
+	settings       Settings
+	errorPages     *errorpage.Renderer
+	onResult       func(method, result string)
+}
+
+// New creates Auth in front of next handler. Authenticators are tried in order, the first one finding
+// credentials decides. onResult is called with method and result for every request and may be nil.
+func New(
+	logger *slog.Logger,
+	next http.Handler,
+	authenticators []Authenticator,
+	settings Settings,
+	errorPages *errorpage.Renderer,
+	onResult func(method, result string),
+) *Auth {
+	return &Auth{
+		logger:         logger,
+		next:           next,
+		authenticators: authenticators,
+		settings:       settings,
+		errorPages:     errorPages,
+		onResult:       onResult,
+	}
+}
+
+// ServeHTTP rejects request without valid credentials with 401 status code.
+// Identity headers sent by client are always removed.
+func (a *Auth) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	for _, header := range []string{a.settings.SubjectHeader, a.settings.MethodHeader} {
+		if header != "" {
+			r.Header.Del(header)
+		}
+	}
+
+	for _, authenticator := range a.authenticators {
+		identity, err := authenticator.Authenticate(r)
+		if errors.Is(err, ErrNoCredentials) {
+			continue
+		}
+
+		if err != nil {
+			a.logger.Warn("Authentication failed",
+				slog.String("method", authenticator.Method()),
+				slog.String("url", r.RequestURI),
+				slog.String("error", err.Error()),
+			)
+
+			a.report(authenticator.Method(), ResultInvalid)
+			a.reject(w, r, fmt.Errorf("invalid %s credentials", authenticator.Method()))
+			return
+		}
+
+		a.report(authenticator.Method(), ResultAuthenticated)
+
+		if a.settings.SubjectHeader != "" {
+			r.Header.Set(a.settings.SubjectHeader, identity.Subject)
+		}
+		if a.settings.MethodHeader != "" {
+			r.Header.Set(a.settings.MethodHeader, identity.Method)
+		}
+
+		a.next.ServeHTTP(w, r.WithContext(WithIdentity(r.Context(), identity)))
+		return
+	}
+
+	a.report("", ResultMissing)
+	a.reject(w, r, errors.New("credentials are required"))
+}
+
+func (a *Auth) reject(w http.ResponseWriter, r *http.Request, err error) {
+	for _, authenticator := range a.authenticators {
+		if challenge := authenticator.Challenge(); challenge != "" {
+			w.Header().Add("WWW-Authenticate", challenge)
+		}
+	}
+
+	a.errorPages.Write(w, r, http.StatusUnauthorized, ErrCodeUnauthorized, err)
+}
+
+func (a *Auth) report(method, result string) {
+	if a.onResult != nil {
+		a.onResult(method, result)
+	}
+}
+
+// authorization returns credentials from Authorization header if it has given scheme.
+func authorization(r *http.Request, scheme string) (string, bool) {
+	prefix, credentials, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(prefix, scheme) {
+		return "", false
+	}
+
+	return strings.TrimSpace(credentials), true
+}
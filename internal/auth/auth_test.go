@@ -0,0 +1,142 @@
+package auth
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/AleksandrMatsko/cloudru-balancer/internal/errorpage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuth(t *testing.T) {
+	t.Parallel()
+
+	settings := Settings{
+		SubjectHeader: "X-Auth-Subject",
+		MethodHeader:  "X-Auth-Method",
+	}
+
+	echo := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		identity, ok := IdentityFromContext(r.Context())
+		assert.True(t, ok)
+		assert.Equal(t, identity.Subject, r.Header.Get("X-Auth-Subject"))
+		assert.Empty(t, r.Header.Get("X-API-Key"))
+		assert.Empty(t, r.URL.Query().Get("api_key"))
+
+		w.Header().Set("X-Subject", r.Header.Get("X-Auth-Subject"))
+		w.Header().Set("X-Method", r.Header.Get("X-Auth-Method"))
+		w.Header().Set("X-Query", r.URL.RawQuery)
+	})
+
+	apiKeys := NewAPIKeys("X-API-Key", "api_key", map[string]string{
+		"mobile-secret": "mobile",
+		"web-secret":    "web",
+	})
+
+	tests := []struct {
+		name            string
+		request         func() *http.Request
+		expectedStatus  int
+		expectedSubject string
+		expectedQuery   string
+		expectedResult  string
+	}{
+		{
+			name: "with key in header",
+			request: func() *http.Request {
+				req := httptest.NewRequest(http.MethodGet, "/", nil)
+				req.Header.Set("X-API-Key", "mobile-secret")
+				return req
+			},
+			expectedStatus:  http.StatusOK,
+			expectedSubject: "mobile",
+			expectedResult:  ResultAuthenticated,
+		},
+		{
+			name: "with key in query",
+			request: func() *http.Request {
+				return httptest.NewRequest(http.MethodGet, "/?api_key=web-secret&page=2", nil)
+			},
+			expectedStatus:  http.StatusOK,
+			expectedSubject: "web",
+			expectedQuery:   "page=2",
+			expectedResult:  ResultAuthenticated,
+		},
+		{
+			name: "with spoofed identity",
+			request: func() *http.Request {
+				req := httptest.NewRequest(http.MethodGet, "/", nil)
+				req.Header.Set("X-API-Key", "web-secret")
+				req.Header.Set("X-Auth-Subject", "admin")
+				return req
+			},
+			expectedStatus:  http.StatusOK,
+			expectedSubject: "web",
+			expectedResult:  ResultAuthenticated,
+		},
+		{
+			name: "with unknown key",
+			request: func() *http.Request {
+				req := httptest.NewRequest(http.MethodGet, "/", nil)
+				req.Header.Set("X-API-Key", "guess")
+				return req
+			},
+			expectedStatus: http.StatusUnauthorized,
+			expectedResult: ResultInvalid,
+		},
+		{
+			name: "without credentials",
+			request: func() *http.Request {
+				req := httptest.NewRequest(http.MethodGet, "/", nil)
+				req.Header.Set("X-Auth-Subject", "admin")
+				return req
+			},
+			expectedStatus: http.StatusUnauthorized,
+			expectedResult: ResultMissing,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			var results []string
+			a := New(slog.Default(), echo, []Authenticator{apiKeys}, settings, nil, func(method, result string) {
+				results = append(results, result)
+			})
+
+			rec := httptest.NewRecorder()
+			a.ServeHTTP(rec, test.request())
+
+			assert.Equal(t, test.expectedStatus, rec.Code)
+			assert.Equal(t, []string{test.expectedResult}, results)
+
+			if test.expectedStatus == http.StatusOK {
+				assert.Equal(t, test.expectedSubject, rec.Header().Get("X-Subject"))
+				assert.Equal(t, MethodAPIKey, rec.Header().Get("X-Method"))
+				assert.Equal(t, test.expectedQuery, rec.Header().Get("X-Query"))
+				return
+			}
+
+			var dto errorpage.ErrorResponse
+			assert.Nil(t, json.NewDecoder(rec.Body).Decode(&dto))
+			assert.Equal(t, ErrCodeUnauthorized, dto.ErrCode)
+		})
+	}
+}
+
+func TestAuth_Challenge(t *testing.T) {
+	t.Parallel()
+
+	basic := &Basic{realm: "test"}
+	a := New(slog.Default(), http.NotFoundHandler(), []Authenticator{basic, &JWT{}}, Settings{}, nil, nil)
+
+	rec := httptest.NewRecorder()
+	a.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Equal(t, []string{`Basic realm="test", charset="UTF-8"`, "Bearer"}, rec.Header().Values("WWW-Authenticate"))
+}
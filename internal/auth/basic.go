@@ -0,0 +1,138 @@
+package auth
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// MethodBasic is the name of Basic authentication.
+const MethodBasic = "basic"
+
+const shaPrefix = "{SHA}"
+
+var errWrongPassword = errors.New("wrong user or password")
+
+// Basic authenticates requests with Basic authentication against users from htpasswd file.
+// Passwords must be hashed with bcrypt or SHA-1.
+type Basic struct {
+	realm  string
+	hashes map[string]string
+	// dummyHash is checked for unknown users, so they can not be found out by time of response.
+	dummyHash []byte
+	// verified keeps hashes of credentials which passed bcrypt check, as it is too slow to run on every request.
+	verified sync.Map
+}
+
+// LoadHtpasswd creates Basic with users from htpasswd file.
+func LoadHtpasswd(fileName, realm string) (*Basic, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	hashes := make(map[string]string)
+
+	scanner := bufio.NewScanner(file)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		user, hash, ok := strings.Cut(line, ":")
+		if !ok || user == "" {
+			return nil, fmt.Errorf("line %d of htpasswd file is malformed", lineNumber)
+		}
+
+		if !strings.HasPrefix(hash, shaPrefix) {
+			if _, err := bcrypt.Cost([]byte(hash)); err != nil {
+				return nil, fmt.Errorf("hash of user %s is neither bcrypt nor {SHA}: %w", user, err)
+			}
+		}
+
+		hashes[user] = hash
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	dummyHash, err := bcrypt.GenerateFromPassword([]byte("dummy"), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Basic{
+		realm:     realm,
+		hashes:    hashes,
+		dummyHash: dummyHash,
+	}, nil
+}
+
+// Method returns MethodBasic.
+func (b *Basic) Method() string {
+	return MethodBasic
+}
+
+// Challenge returns Basic challenge with realm.
+func (b *Basic) Challenge() string {
+	return fmt.Sprintf("Basic realm=%q, charset=\"UTF-8\"", b.realm)
+}
+
+// Authenticate returns identity of the user if password matches.
+func (b *Basic) Authenticate(r *http.Request) (Identity, error) {
+	if _, ok := authorization(r, "Basic"); !ok {
+		return Identity{}, ErrNoCredentials
+	}
+
+	user, password, ok := r.BasicAuth()
+	if !ok {
+		return Identity{}, errors.New("malformed basic credentials")
+	}
+
+	if !b.check(user, password) {
+		return Identity{}, errWrongPassword
+	}
+
+	return Identity{Subject: user, Method: MethodBasic}, nil
+}
+
+func (b *Basic) check(user, password string) bool {
+	hash, ok := b.hashes[user]
+	if !ok {
+		_ = bcrypt.CompareHashAndPassword(b.dummyHash, []byte(password))
+		return false
+	}
+
+	if strings.HasPrefix(hash, shaPrefix) {
+		sum := sha1.Sum([]byte(password))
+		expected := base64.StdEncoding.EncodeToString(sum[:])
+
+		return subtle.ConstantTimeCompare([]byte(expected), []byte(strings.TrimPrefix(hash, shaPrefix))) == 1
+	}
+
+	key := sha256.Sum256([]byte(user + "\x00" + password + "\x00" + hash))
+	if _, ok := b.verified.Load(key); ok {
+		return true
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) != nil {
+		return false
+	}
+
+	b.verified.Store(key, struct{}{})
+
+	return true
+}
@@ -0,0 +1,97 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestBasic(t *testing.T) {
+	t.Parallel()
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("alice-password"), bcrypt.MinCost)
+	assert.Nil(t, err)
+
+	fileName := filepath.Join(t.TempDir(), "htpasswd")
+	content := "# users\n" +
+		"alice:" + string(hash) + "\n" +
+		// Password is "bob-password".
+		"bob:{SHA}oHryCTyM4ObJvET53dSBiRe/fXQ=\n"
+	assert.Nil(t, os.WriteFile(fileName, []byte(content), 0o600))
+
+	basic, err := LoadHtpasswd(fileName, "test")
+	assert.Nil(t, err)
+
+	tests := []struct {
+		name        string
+		user        string
+		password    string
+		expectedErr bool
+	}{
+		{name: "bcrypt", user: "alice", password: "alice-password"},
+		{name: "bcrypt with wrong password", user: "alice", password: "bob-password", expectedErr: true},
+		{name: "sha", user: "bob", password: "bob-password"},
+		{name: "sha with wrong password", user: "bob", password: "alice-password", expectedErr: true},
+		{name: "unknown user", user: "eve", password: "alice-password", expectedErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			// Second check of bcrypt password uses verified credentials.
+			for range 2 {
+				req := httptest.NewRequest(http.MethodGet, "/", nil)
+				req.SetBasicAuth(test.user, test.password)
+
+				identity, err := basic.Authenticate(req)
+				if test.expectedErr {
+					assert.NotNil(t, err)
+					continue
+				}
+
+				assert.Nil(t, err)
+				assert.Equal(t, Identity{Subject: test.user, Method: MethodBasic}, identity)
+			}
+		})
+	}
+
+	t.Run("without credentials", func(t *testing.T) {
+		t.Parallel()
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Authorization", "Bearer token")
+
+		_, err := basic.Authenticate(req)
+		assert.ErrorIs(t, err, ErrNoCredentials)
+	})
+}
+
+func TestLoadHtpasswd(t *testing.T) {
+	t.Parallel()
+
+	t.Run("with unsupported hash", func(t *testing.T) {
+		t.Parallel()
+
+		fileName := filepath.Join(t.TempDir(), "htpasswd")
+		assert.Nil(t, os.WriteFile(fileName, []byte("alice:$apr1$salt$hash\n"), 0o600))
+
+		_, err := LoadHtpasswd(fileName, "test")
+		assert.NotNil(t, err)
+	})
+
+	t.Run("with malformed line", func(t *testing.T) {
+		t.Parallel()
+
+		fileName := filepath.Join(t.TempDir(), "htpasswd")
+		assert.Nil(t, os.WriteFile(fileName, []byte("alice\n"), 0o600))
+
+		_, err := LoadHtpasswd(fileName, "test")
+		assert.NotNil(t, err)
+	})
+}
@@ -0,0 +1,120 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// MethodJWT is the name of authentication with JSON Web Tokens.
+const MethodJWT = "jwt"
+
+// signingMethods are algorithms accepted in tokens. Symmetric algorithms and none are never accepted.
+var signingMethods = []string{
+	"RS256", "RS384", "RS512",
+	"PS256", "PS384", "PS512",
+	"ES256", "ES384", "ES512",
+	"EdDSA",
+}
+
+// JWTSettings of JWT validation.
+type JWTSettings struct {
+	// Issuer must be equal to iss claim. Empty issuer is not checked.
+	Issuer string
+	// Audience must be one of aud claim values. Empty audience is not checked.
+	Audience string
+	// Leeway for checks of exp, nbf and iat claims.
+	Leeway time.Duration
+	// SubjectClaim is the claim used as subject of the client.
+	SubjectClaim string
+}
+
+// JWT authenticates requests with bearer JSON Web Tokens signed by keys from JWKS file.
+// Token must have exp claim.
+type JWT struct {
+	keys         map[string]publicKey
+	parser       *jwt.Parser
+	subjectClaim string
+}
+
+// LoadJWT creates JWT checking tokens with keys from JWKS file.
+func LoadJWT(jwksFileName string, settings JWTSettings) (*JWT, error) {
+	keys, err := loadJWKS(jwksFileName)
+	if err != nil {
+		return nil, err
+	}
+
+	options := []jwt.ParserOption{
+		jwt.WithValidMethods(signingMethods),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(settings.Leeway),
+	}
+	if settings.Issuer != "" {
+		options = append(options, jwt.WithIssuer(settings.Issuer))
+	}
+	if settings.Audience != "" {
+		options = append(options, jwt.WithAudience(settings.Audience))
+	}
+
+	return &JWT{
+		keys:         keys,
+		parser:       jwt.NewParser(options...),
+		subjectClaim: settings.SubjectClaim,
+	}, nil
+}
+
+// Method returns MethodJWT.
+func (j *JWT) Method() string {
+	return MethodJWT
+}
+
+// Challenge returns Bearer challenge.
+func (j *JWT) Challenge() string {
+	return "Bearer"
+}
+
+// Authenticate returns identity with subject from the token.
+func (j *JWT) Authenticate(r *http.Request) (Identity, error) {
+	token, ok := authorization(r, "Bearer")
+	if !ok {
+		return Identity{}, ErrNoCredentials
+	}
+
+	claims := jwt.MapClaims{}
+	if _, err := j.parser.ParseWithClaims(token, claims, j.key); err != nil {
+		return Identity{}, err
+	}
+
+	subject, ok := claims[j.subjectClaim].(string)
+	if !ok || subject == "" {
+		return Identity{}, fmt.Errorf("token has no %s claim", j.subjectClaim)
+	}
+
+	return Identity{Subject: subject, Method: MethodJWT}, nil
+}
+
+// key returns public key for the token by its kid header. Token without kid may be checked
+// only if JWKS has single key.
+func (j *JWT) key(token *jwt.Token) (any, error) {
+	kid, _ := token.Header["kid"].(string)
+
+	key, ok := j.keys[kid]
+	if !ok && kid == "" && len(j.keys) == 1 {
+		for _, single := range j.keys {
+			key, ok = single, true
+		}
+	}
+
+	if !ok {
+		return nil, fmt.Errorf("unknown key id: %q", kid)
+	}
+
+	if key.algorithm != "" && key.algorithm != token.Method.Alg() {
+		return nil, errors.New("algorithm of the token does not match the key")
+	}
+
+	return key.key, nil
+}
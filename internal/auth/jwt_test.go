@@ -0,0 +1,214 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJWT(t *testing.T) {
+	t.Parallel()
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+
+	edPublic, edKey, err := ed25519.GenerateKey(rand.Reader)
+	assert.Nil(t, err)
+
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+
+	jwks := map[string]any{
+		"keys": []map[string]string{
+			{
+				"kty": "EC",
+				"kid": "ec",
+				"alg": "ES256",
+				"crv": "P-256",
+				"x":   base64.RawURLEncoding.EncodeToString(ecKey.X.Bytes()),
+				"y":   base64.RawURLEncoding.EncodeToString(ecKey.Y.Bytes()),
+			},
+			{
+				"kty": "OKP",
+				"kid": "ed",
+				"crv": "Ed25519",
+				"x":   base64.RawURLEncoding.EncodeToString(edPublic),
+			},
+		},
+	}
+
+	data, err := json.Marshal(jwks)
+	assert.Nil(t, err)
+
+	fileName := filepath.Join(t.TempDir(), "jwks.json")
+	assert.Nil(t, os.WriteFile(fileName, data, 0o600))
+
+	j, err := LoadJWT(fileName, JWTSettings{
+		Issuer:       "https://issuer.local",
+		Audience:     "balancer",
+		SubjectClaim: "sub",
+	})
+	assert.Nil(t, err)
+
+	validClaims := func() jwt.MapClaims {
+		return jwt.MapClaims{
+			"sub": "alice",
+			"iss": "https://issuer.local",
+			"aud": []string{"balancer", "other"},
+			"exp": time.Now().Add(time.Minute).Unix(),
+		}
+	}
+
+	sign := func(method jwt.SigningMethod, kid string, key any, claims jwt.MapClaims) string {
+		token := jwt.NewWithClaims(method, claims)
+		token.Header["kid"] = kid
+
+		signed, err := token.SignedString(key)
+		assert.Nil(t, err)
+
+		return signed
+	}
+
+	tests := []struct {
+		name        string
+		token       func() string
+		expectedErr bool
+	}{
+		{
+			name:  "with ecdsa key",
+			token: func() string { return sign(jwt.SigningMethodES256, "ec", ecKey, validClaims()) },
+		},
+		{
+			name:  "with ed25519 key",
+			token: func() string { return sign(jwt.SigningMethodEdDSA, "ed", edKey, validClaims()) },
+		},
+		{
+			name:        "with unknown key",
+			token:       func() string { return sign(jwt.SigningMethodES256, "ec", otherKey, validClaims()) },
+			expectedErr: true,
+		},
+		{
+			name:        "with unknown key id",
+			token:       func() string { return sign(jwt.SigningMethodES256, "other", ecKey, validClaims()) },
+			expectedErr: true,
+		},
+		{
+			name: "with hmac",
+			token: func() string {
+				return sign(jwt.SigningMethodHS256, "ec", []byte("secret"), validClaims())
+			},
+			expectedErr: true,
+		},
+		{
+			name: "expired",
+			token: func() string {
+				claims := validClaims()
+				claims["exp"] = time.Now().Add(-time.Minute).Unix()
+				return sign(jwt.SigningMethodES256, "ec", ecKey, claims)
+			},
+			expectedErr: true,
+		},
+		{
+			name: "without expiration",
+			token: func() string {
+				claims := validClaims()
+				delete(claims, "exp")
+				return sign(jwt.SigningMethodES256, "ec", ecKey, claims)
+			},
+			expectedErr: true,
+		},
+		{
+			name: "with wrong issuer",
+			token: func() string {
+				claims := validClaims()
+				claims["iss"] = "https://evil.local"
+				return sign(jwt.SigningMethodES256, "ec", ecKey, claims)
+			},
+			expectedErr: true,
+		},
+		{
+			name: "with wrong audience",
+			token: func() string {
+				claims := validClaims()
+				claims["aud"] = "other"
+				return sign(jwt.SigningMethodES256, "ec", ecKey, claims)
+			},
+			expectedErr: true,
+		},
+		{
+			name: "without subject",
+			token: func() string {
+				claims := validClaims()
+				delete(claims, "sub")
+				return sign(jwt.SigningMethodES256, "ec", ecKey, claims)
+			},
+			expectedErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("Authorization", "Bearer "+test.token())
+
+			identity, err := j.Authenticate(req)
+			if test.expectedErr {
+				assert.NotNil(t, err)
+				assert.NotErrorIs(t, err, ErrNoCredentials)
+				return
+			}
+
+			assert.Nil(t, err)
+			assert.Equal(t, Identity{Subject: "alice", Method: MethodJWT}, identity)
+		})
+	}
+
+	t.Run("without token", func(t *testing.T) {
+		t.Parallel()
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.SetBasicAuth("alice", "password")
+
+		_, err := j.Authenticate(req)
+		assert.ErrorIs(t, err, ErrNoCredentials)
+	})
+}
+
+func TestLoadJWT(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		jwks string
+	}{
+		{name: "with unsupported key type", jwks: `{"keys": [{"kty": "oct", "k": "c2VjcmV0"}]}`},
+		{name: "with point not on curve", jwks: `{"keys": [{"kty": "EC", "crv": "P-256", "x": "AQ", "y": "AQ"}]}`},
+		{name: "without signature keys", jwks: `{"keys": [{"kty": "RSA", "use": "enc", "n": "AQ", "e": "AQAB"}]}`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			fileName := filepath.Join(t.TempDir(), "jwks.json")
+			assert.Nil(t, os.WriteFile(fileName, []byte(test.jwks), 0o600))
+
+			_, err := LoadJWT(fileName, JWTSettings{SubjectClaim: "sub"})
+			assert.NotNil(t, err)
+		})
+	}
+}
@@ -1,100 +1,276 @@
 package balancer
 
 import (
-	"encoding/json"
-	"errors"
+	"context"
 	"fmt"
 	"log/slog"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
-)
+	"sync"
+	"time"
 
-var errNoAvailableBackends = errors.New("no available backends")
+	"github.com/AleksandrMatsko/cloudru-balancer/internal/affinity"
+	"github.com/AleksandrMatsko/cloudru-balancer/internal/errorpage"
+)
 
 // Balancer is reverse proxy that balance incoming requests between backends
 // according to the given strategy.
 type Balancer struct {
-	logger   *slog.Logger
-	strategy Strategy
-	proxies  map[string]http.Handler
+	logger         *slog.Logger
+	strategy       Strategy
+	proxiesLock    sync.RWMutex
+	proxies        map[string]http.Handler
+	newProxy       func(backend string) http.Handler
+	requestTimeout time.Duration
+	errorPages     *errorpage.Renderer
+	affinity       *affinity.Cookie
+	observer       ResponseObserver
+	limiter        ConcurrencyLimiter
 }
 
-// NewBalancer creates Balancer.
+// NewBalancer creates Balancer. Requests to backends are sent with given transport.
 func NewBalancer(
 	logger *slog.Logger,
 	strategy Strategy,
 	backends []string,
 	urlCreateFunc func(string) *url.URL,
+	transport http.RoundTripper,
+	opts ...Option,
 ) *Balancer {
-	proxies := make(map[string]http.Handler, len(backends))
-	for _, backend := range backends {
+	b := &Balancer{
+		logger:   logger,
+		strategy: strategy,
+	}
+
+	for _, opt := range opts {
+		opt(b)
+	}
+
+	b.newProxy = func(backend string) http.Handler {
 		rp := httputil.NewSingleHostReverseProxy(urlCreateFunc(backend))
-		rp.ErrorHandler = createErrorHandler(logger.With(slog.String("backend", backend)))
-		proxies[backend] = rp
+		rp.Transport = transport
+		rp.ErrorHandler = createErrorHandler(logger.With(slog.String("backend", backend)), b.errorPages)
+		if b.errorPages.InterceptsBackendErrors() {
+			rp.ModifyResponse = b.errorPages.ReplaceBackendError
+		}
+		return rp
 	}
 
-	return &Balancer{
-		logger:   logger,
-		strategy: strategy,
-		proxies:  proxies,
+	b.proxies = make(map[string]http.Handler, len(backends))
+	for _, backend := range backends {
+		b.proxies[backend] = b.newProxy(backend)
 	}
+
+	return b
 }
 
 func (b *Balancer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	backend := b.strategy.ChooseBackend()
+	backend, pinned := b.chooseBackend(r)
+
+	if backend == "" {
+		b.logger.Error("No available backends for request",
+			slog.String("method", r.Method),
+			slog.String("url", r.RequestURI),
+		)
+		SetRetryAfter(w)
+		b.errorPages.Write(w, r, http.StatusServiceUnavailable, ErrCodeNoAvailableBackends, errNoAvailableBackends)
+		return
+	}
+
+	if b.limiter != nil {
+		var (
+			release func()
+			err     error
+		)
+
+		backend, release, err = b.acquire(r, backend, pinned)
+		if err != nil {
+			b.rejectOverloaded(w, r, backend, err)
+			return
+		}
+		defer release()
+	}
+
 	logger := b.logger.With(
 		slog.String("method", r.Method),
 		slog.String("url", r.RequestURI),
 		slog.String("chosen_backend", backend),
 	)
 
-	if backend == "" {
-		logger.Error("No available backends for request")
-		writeErrorToClient(w, http.StatusServiceUnavailable, errNoAvailableBackends)
-		return
-	}
-
+	b.proxiesLock.RLock()
 	proxy, ok := b.proxies[backend]
+	b.proxiesLock.RUnlock()
+
 	if !ok {
 		logger.Error("Unknown backend")
-		writeErrorToClient(w, http.StatusInternalServerError, fmt.Errorf("strategy returned not existing backend: %s", backend))
+		b.errorPages.Write(w, r, http.StatusInternalServerError, ErrCodeUnknownBackend, fmt.Errorf("strategy returned not existing backend: %s", backend))
 		return
 	}
 
 	logger.Info("Serving request")
 
-	proxy.ServeHTTP(w, r)
+	if b.affinity != nil && !pinned {
+		b.affinity.Set(w, backend)
+	}
+
+	if b.requestTimeout > 0 {
+		ctx, cancel := context.WithTimeout(r.Context(), b.requestTimeout)
+		defer cancel()
+
+		r = r.WithContext(ctx)
+	}
+
+	if b.observer == nil {
+		proxy.ServeHTTP(w, r)
+		return
+	}
+
+	rec := &statusRecorder{ResponseWriter: w}
+	start := time.Now()
+
+	b.observer.ObserveRequest(backend)
+
+	// Reverse proxy may panic with http.ErrAbortHandler, but observer must be notified anyway.
+	defer func() {
+		b.observer.ObserveResponse(backend, rec.status(), time.Since(start))
+	}()
+
+	proxy.ServeHTTP(rec, r)
 }
 
-// ErrorResponse returned to client, when error occurred.
-type ErrorResponse struct {
-	// Msg includes occurred error.
-	Msg string `json:"msg"`
-	// Code is the returned http status code.
-	Code int `json:"status"`
+// chooseBackend returns backend from affinity cookie if it is still available, otherwise asks strategy.
+// It also reports if the backend is pinned with WithBackend.
+func (b *Balancer) chooseBackend(r *http.Request) (string, bool) {
+	if backend, ok := PinnedBackend(r.Context()); ok && b.hasProxy(backend) {
+		return backend, true
+	}
+
+	if b.affinity != nil {
+		if backend, ok := b.affinity.Backend(r); ok && b.hasProxy(backend) && b.strategy.IsAvailable(backend) {
+			return backend, false
+		}
+	}
+
+	return b.strategy.ChooseBackend(), false
 }
 
-func createErrorHandler(logger *slog.Logger) func(http.ResponseWriter, *http.Request, error) {
+// acquire takes slot of the chosen backend. If it has no free slot, other backends chosen by strategy are tried,
+// then request waits for slot of the chosen backend. Pinned backend is never replaced.
+func (b *Balancer) acquire(r *http.Request, backend string, pinned bool) (string, func(), error) {
+	if release, ok := b.limiter.TryAcquire(backend); ok {
+		return backend, release, nil
+	}
+
+	if !pinned {
+		b.proxiesLock.RLock()
+		attempts := len(b.proxies) - 1
+		b.proxiesLock.RUnlock()
+
+		for range attempts {
+			other := b.strategy.ChooseBackend()
+			if other == "" || other == backend {
+				continue
+			}
+
+			if release, ok := b.limiter.TryAcquire(other); ok {
+				return other, release, nil
+			}
+		}
+	}
+
+	release, err := b.limiter.Acquire(r.Context(), backend)
+
+	return backend, release, err
+}
+
+func (b *Balancer) rejectOverloaded(w http.ResponseWriter, r *http.Request, backend string, err error) {
+	logger := b.logger.With(
+		slog.String("method", r.Method),
+		slog.String("url", r.RequestURI),
+		slog.String("chosen_backend", backend),
+	)
+
+	if r.Context().Err() != nil {
+		logger.Info("Client closed request",
+			slog.Int("status", StatusClientClosedRequest),
+		)
+		return
+	}
+
+	logger.Warn("Backend concurrency limit exceeded",
+		slog.String("error", err.Error()),
+	)
+	SetRetryAfter(w)
+	b.errorPages.Write(w, r, http.StatusServiceUnavailable, ErrCodeConcurrencyLimit, err)
+}
+
+type pinnedBackendKey struct{}
+
+// WithBackend returns context making Balancer send request to given backend bypassing strategy and its health.
+// It is ignored if balancer does not know the backend.
+func WithBackend(ctx context.Context, backend string) context.Context {
+	return context.WithValue(ctx, pinnedBackendKey{}, backend)
+}
+
+// PinnedBackend returns backend set with WithBackend.
+func PinnedBackend(ctx context.Context) (string, bool) {
+	backend, ok := ctx.Value(pinnedBackendKey{}).(string)
+	return backend, ok
+}
+
+// HasBackend reports if balancer sends requests to given backend.
+func (b *Balancer) HasBackend(backend string) bool {
+	return b.hasProxy(backend)
+}
+
+func (b *Balancer) hasProxy(backend string) bool {
+	b.proxiesLock.RLock()
+	defer b.proxiesLock.RUnlock()
+
+	_, ok := b.proxies[backend]
+	return ok
+}
+
+// AddBackend creates reverse proxy for the backend.
+func (b *Balancer) AddBackend(backend string) {
+	proxy := b.newProxy(backend)
+
+	b.proxiesLock.Lock()
+	defer b.proxiesLock.Unlock()
+
+	if _, ok := b.proxies[backend]; !ok {
+		b.proxies[backend] = proxy
+	}
+}
+
+// RemoveBackend removes reverse proxy for the backend.
+func (b *Balancer) RemoveBackend(backend string) {
+	b.proxiesLock.Lock()
+	defer b.proxiesLock.Unlock()
+
+	delete(b.proxies, backend)
+}
+
+func createErrorHandler(logger *slog.Logger, errorPages *errorpage.Renderer) func(http.ResponseWriter, *http.Request, error) {
 	return func(w http.ResponseWriter, r *http.Request, err error) {
+		class := classifyProxyError(r, err)
+		if class.statusCode == StatusClientClosedRequest {
+			logger.Info("Client closed request",
+				slog.String("method", r.Method),
+				slog.String("url", r.RequestURI),
+				slog.Int("status", class.statusCode),
+			)
+			return
+		}
+
 		logger.Error("Error from backend",
 			slog.String("error", err.Error()),
+			slog.String("error_code", class.errCode),
 			slog.String("method", r.Method),
 			slog.String("url", r.RequestURI),
 		)
 
-		writeErrorToClient(w, http.StatusInternalServerError, fmt.Errorf("error from backend: %w", err))
+		errorPages.Write(w, r, class.statusCode, class.errCode, fmt.Errorf("error from backend: %w", err))
 	}
 }
-
-func writeErrorToClient(w http.ResponseWriter, statusCode int, err error) {
-	dto := ErrorResponse{
-		Msg:  err.Error(),
-		Code: statusCode,
-	}
-
-	w.WriteHeader(dto.Code)
-
-	encoder := json.NewEncoder(w)
-	_ = encoder.Encode(dto)
-}
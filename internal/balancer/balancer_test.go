@@ -1,14 +1,23 @@
 package balancer
 
 import (
+	"context"
 	"encoding/json"
 	"io"
 	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/AleksandrMatsko/cloudru-balancer/internal/affinity"
 	mock_balancer "github.com/AleksandrMatsko/cloudru-balancer/internal/balancer/mocks"
+	"github.com/AleksandrMatsko/cloudru-balancer/internal/breaker"
+	"github.com/AleksandrMatsko/cloudru-balancer/internal/concurrency"
+	"github.com/AleksandrMatsko/cloudru-balancer/internal/errorpage"
+	"github.com/AleksandrMatsko/cloudru-balancer/internal/strategies"
 	"github.com/stretchr/testify/assert"
 	"go.uber.org/mock/gomock"
 )
@@ -28,9 +37,10 @@ func TestBalancer_ServeHTTP(t *testing.T) {
 		}
 
 		mockStrategy.EXPECT().ChooseBackend().Return("").Times(1)
-		expectedDTO := ErrorResponse{
-			Msg:  "no available backends",
-			Code: http.StatusServiceUnavailable,
+		expectedDTO := errorpage.ErrorResponse{
+			Msg:     "no available backends",
+			Code:    http.StatusServiceUnavailable,
+			ErrCode: ErrCodeNoAvailableBackends,
 		}
 
 		expectedBytes, err := json.Marshal(expectedDTO)
@@ -42,6 +52,7 @@ func TestBalancer_ServeHTTP(t *testing.T) {
 		b.ServeHTTP(recorder, req)
 
 		assert.Equal(t, expectedDTO.Code, recorder.Code)
+		assert.Equal(t, "1", recorder.Header().Get("Retry-After"))
 
 		bytes, err := io.ReadAll(recorder.Body)
 		assert.Nil(t, err)
@@ -59,9 +70,10 @@ func TestBalancer_ServeHTTP(t *testing.T) {
 		}
 
 		mockStrategy.EXPECT().ChooseBackend().Return("hello").Times(1)
-		expectedDTO := ErrorResponse{
-			Msg:  "strategy returned not existing backend: hello",
-			Code: http.StatusInternalServerError,
+		expectedDTO := errorpage.ErrorResponse{
+			Msg:     "strategy returned not existing backend: hello",
+			Code:    http.StatusInternalServerError,
+			ErrCode: ErrCodeUnknownBackend,
 		}
 
 		expectedBytes, err := json.Marshal(expectedDTO)
@@ -105,3 +117,412 @@ func TestBalancer_ServeHTTP(t *testing.T) {
 		b.ServeHTTP(recorder, req)
 	})
 }
+
+func TestBalancer_ServeHTTP_Timeout(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(time.Second):
+			}
+		},
+	))
+	defer server.Close()
+
+	serverURL, err := url.Parse(server.URL)
+	assert.Nil(t, err)
+
+	mockStrategy := mock_balancer.NewMockStrategy(mockCtrl)
+	mockStrategy.EXPECT().ChooseBackend().Return(serverURL.Host).Times(1)
+
+	b := NewBalancer(
+		slog.Default(),
+		mockStrategy,
+		[]string{serverURL.Host},
+		func(string) *url.URL { return serverURL },
+		server.Client().Transport,
+		WithRequestTimeout(50*time.Millisecond),
+	)
+
+	recorder := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "http://test.url", nil)
+
+	b.ServeHTTP(recorder, req)
+
+	assert.Equal(t, http.StatusGatewayTimeout, recorder.Code)
+
+	var dto errorpage.ErrorResponse
+	assert.Nil(t, json.NewDecoder(recorder.Body).Decode(&dto))
+	assert.Equal(t, ErrCodeUpstreamTimeout, dto.ErrCode)
+}
+
+func TestBalancer_ServeHTTP_ProxyErrors(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	t.Run("when backend refuses connection", func(t *testing.T) {
+		server := httptest.NewServer(http.NotFoundHandler())
+		serverURL, err := url.Parse(server.URL)
+		assert.Nil(t, err)
+		server.Close()
+
+		mockStrategy := mock_balancer.NewMockStrategy(mockCtrl)
+		mockStrategy.EXPECT().ChooseBackend().Return(serverURL.Host).Times(1)
+
+		b := NewBalancer(
+			slog.Default(),
+			mockStrategy,
+			[]string{serverURL.Host},
+			func(string) *url.URL { return serverURL },
+			http.DefaultTransport,
+		)
+
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "http://test.url", nil)
+
+		b.ServeHTTP(recorder, req)
+
+		assert.Equal(t, http.StatusBadGateway, recorder.Code)
+
+		var dto errorpage.ErrorResponse
+		assert.Nil(t, json.NewDecoder(recorder.Body).Decode(&dto))
+		assert.Equal(t, ErrCodeUpstreamConnect, dto.ErrCode)
+	})
+
+	t.Run("when request body is too large", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				_, _ = io.Copy(io.Discard, r.Body)
+			},
+		))
+		defer server.Close()
+
+		serverURL, err := url.Parse(server.URL)
+		assert.Nil(t, err)
+
+		mockStrategy := mock_balancer.NewMockStrategy(mockCtrl)
+		mockStrategy.EXPECT().ChooseBackend().Return(serverURL.Host).Times(1)
+
+		b := NewBalancer(
+			slog.Default(),
+			mockStrategy,
+			[]string{serverURL.Host},
+			func(string) *url.URL { return serverURL },
+			server.Client().Transport,
+		)
+
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "http://test.url", nil)
+		req.Body = http.MaxBytesReader(recorder, io.NopCloser(strings.NewReader(strings.Repeat("a", 100))), 10)
+		req.ContentLength = -1
+
+		b.ServeHTTP(recorder, req)
+
+		assert.Equal(t, http.StatusRequestEntityTooLarge, recorder.Code)
+
+		var dto errorpage.ErrorResponse
+		assert.Nil(t, json.NewDecoder(recorder.Body).Decode(&dto))
+		assert.Equal(t, ErrCodeRequestBodyTooLarge, dto.ErrCode)
+	})
+
+	t.Run("when client closes request", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				<-r.Context().Done()
+			},
+		))
+		defer server.Close()
+
+		serverURL, err := url.Parse(server.URL)
+		assert.Nil(t, err)
+
+		mockStrategy := mock_balancer.NewMockStrategy(mockCtrl)
+		mockStrategy.EXPECT().ChooseBackend().Return(serverURL.Host).Times(1)
+
+		b := NewBalancer(
+			slog.Default(),
+			mockStrategy,
+			[]string{serverURL.Host},
+			func(string) *url.URL { return serverURL },
+			server.Client().Transport,
+		)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(50*time.Millisecond, cancel)
+
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "http://test.url", nil).WithContext(ctx)
+
+		b.ServeHTTP(recorder, req)
+
+		assert.False(t, recorder.Flushed)
+		assert.Equal(t, 0, recorder.Body.Len())
+	})
+}
+
+func TestBalancer_ServeHTTP_Affinity(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	newServer := func(name string) (*httptest.Server, string) {
+		server := httptest.NewServer(http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				_, _ = io.WriteString(w, name)
+			},
+		))
+
+		serverURL, err := url.Parse(server.URL)
+		assert.Nil(t, err)
+
+		return server, serverURL.Host
+	}
+
+	serverA, backendA := newServer("A")
+	defer serverA.Close()
+
+	serverB, backendB := newServer("B")
+	defer serverB.Close()
+
+	mockStrategy := mock_balancer.NewMockStrategy(mockCtrl)
+	cookie := affinity.NewCookie("affinity", time.Minute, []byte("secret"))
+
+	b := NewBalancer(
+		slog.Default(),
+		mockStrategy,
+		[]string{backendA, backendB},
+		func(backend string) *url.URL { return &url.URL{Scheme: "http", Host: backend} },
+		http.DefaultTransport,
+		WithAffinity(cookie),
+	)
+
+	serve := func(cookies ...*http.Cookie) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "http://test.url", nil)
+		for _, c := range cookies {
+			req.AddCookie(c)
+		}
+
+		b.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	mockStrategy.EXPECT().ChooseBackend().Return(backendA).Times(1)
+
+	first := serve()
+	assert.Equal(t, "A", first.Body.String())
+
+	cookies := first.Result().Cookies()
+	assert.Len(t, cookies, 1)
+
+	mockStrategy.EXPECT().IsAvailable(backendA).Return(true).Times(1)
+
+	second := serve(cookies...)
+	assert.Equal(t, "A", second.Body.String())
+
+	mockStrategy.EXPECT().IsAvailable(backendA).Return(false).Times(1)
+	mockStrategy.EXPECT().ChooseBackend().Return(backendB).Times(1)
+
+	third := serve(cookies...)
+	assert.Equal(t, "B", third.Body.String())
+}
+
+func TestBalancer_ServeHTTP_WithBackend(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			_, _ = io.WriteString(w, "pinned")
+		},
+	))
+	defer server.Close()
+
+	serverURL, err := url.Parse(server.URL)
+	assert.Nil(t, err)
+
+	mockStrategy := mock_balancer.NewMockStrategy(mockCtrl)
+
+	b := NewBalancer(
+		slog.Default(),
+		mockStrategy,
+		[]string{serverURL.Host},
+		func(backend string) *url.URL { return &url.URL{Scheme: "http", Host: backend} },
+		http.DefaultTransport,
+		WithAffinity(affinity.NewCookie("affinity", time.Minute, []byte("secret"))),
+	)
+
+	assert.True(t, b.HasBackend(serverURL.Host))
+	assert.False(t, b.HasBackend("unknown:8081"))
+
+	recorder := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "http://test.url", nil)
+	b.ServeHTTP(recorder, req.WithContext(WithBackend(req.Context(), serverURL.Host)))
+
+	assert.Equal(t, "pinned", recorder.Body.String())
+	assert.Empty(t, recorder.Result().Cookies())
+
+	mockStrategy.EXPECT().ChooseBackend().Return("").Times(1)
+
+	recorder = httptest.NewRecorder()
+	b.ServeHTTP(recorder, req.WithContext(WithBackend(req.Context(), "unknown:8081")))
+
+	assert.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+}
+
+func TestBalancer_ServeHTTP_ConcurrencyLimit(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	started := make(chan struct{})
+	unblock := make(chan struct{})
+
+	newServer := func(name string) (*httptest.Server, string) {
+		server := httptest.NewServer(http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == "/block" {
+					started <- struct{}{}
+					<-unblock
+				}
+
+				_, _ = io.WriteString(w, name)
+			},
+		))
+
+		serverURL, err := url.Parse(server.URL)
+		assert.Nil(t, err)
+
+		return server, serverURL.Host
+	}
+
+	serverA, backendA := newServer("A")
+	defer serverA.Close()
+
+	serverB, backendB := newServer("B")
+	defer serverB.Close()
+
+	mockStrategy := mock_balancer.NewMockStrategy(mockCtrl)
+
+	b := NewBalancer(
+		slog.Default(),
+		mockStrategy,
+		[]string{backendA, backendB},
+		func(backend string) *url.URL { return &url.URL{Scheme: "http", Host: backend} },
+		http.DefaultTransport,
+		WithLimiter(concurrency.New(concurrency.Settings{MaxConcurrency: 1, QueueSize: 1, QueueTimeout: 50 * time.Millisecond}, nil, nil, nil)),
+	)
+
+	serve := func(path string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		b.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "http://test.url"+path, nil))
+		return recorder
+	}
+
+	mockStrategy.EXPECT().ChooseBackend().Return(backendA).Times(1)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		assert.Equal(t, "A", serve("/block").Body.String())
+	}()
+	<-started
+
+	t.Run("goes to another backend", func(t *testing.T) {
+		mockStrategy.EXPECT().ChooseBackend().Return(backendA).Times(1)
+		mockStrategy.EXPECT().ChooseBackend().Return(backendB).Times(1)
+
+		assert.Equal(t, "B", serve("/").Body.String())
+	})
+
+	t.Run("rejects after queue timeout", func(t *testing.T) {
+		mockStrategy.EXPECT().ChooseBackend().Return(backendA).Times(2)
+
+		recorder := serve("/")
+		assert.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+		assert.Equal(t, "1", recorder.Header().Get("Retry-After"))
+
+		var dto errorpage.ErrorResponse
+		assert.Nil(t, json.NewDecoder(recorder.Body).Decode(&dto))
+		assert.Equal(t, ErrCodeConcurrencyLimit, dto.ErrCode)
+	})
+
+	close(unblock)
+	<-done
+}
+
+func TestBalancer_ServeHTTP_ConcurrencyLimitWithBreaker(t *testing.T) {
+	newServer := func(name string) (*httptest.Server, string) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			_, _ = io.WriteString(w, name)
+		}))
+
+		serverURL, err := url.Parse(server.URL)
+		assert.Nil(t, err)
+
+		return server, serverURL.Host
+	}
+
+	serverA, backendA := newServer("A")
+	defer serverA.Close()
+
+	serverB, backendB := newServer("B")
+	defer serverB.Close()
+
+	backends := []string{backendA, backendB}
+
+	breakers := breaker.NewGroup(slog.Default(), breaker.Settings{
+		Window:               10 * time.Second,
+		MinRequests:          1,
+		FailureRateThreshold: 0.5,
+		OpenTimeout:          10 * time.Millisecond,
+		HalfOpenMaxRequests:  1,
+	}, backends)
+
+	strategy := strategies.NewRoundRobin(backends, strategies.WithFilter(breakers))
+	for _, backend := range backends {
+		strategy.UpdateBackendHealth(backend, true)
+	}
+
+	limiter := concurrency.New(concurrency.Settings{MaxConcurrency: 1, QueueSize: 1, QueueTimeout: 10 * time.Millisecond}, nil, nil, nil)
+
+	b := NewBalancer(
+		slog.Default(),
+		strategy,
+		backends,
+		func(backend string) *url.URL { return &url.URL{Scheme: "http", Host: backend} },
+		http.DefaultTransport,
+		WithObserver(breakers),
+		WithLimiter(limiter),
+	)
+
+	serve := func() *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		b.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "http://test.url", nil))
+		return recorder
+	}
+
+	// Breaker of B opens and becomes half-open.
+	breakers.ObserveRequest(backendB)
+	breakers.ObserveResponse(backendB, http.StatusBadGateway, time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+
+	// Both backends are busy, so half-open B is chosen, but request is rejected without proxying to it.
+	releaseA, ok := limiter.TryAcquire(backendA)
+	assert.True(t, ok)
+	releaseB, ok := limiter.TryAcquire(backendB)
+	assert.True(t, ok)
+
+	for range 2 {
+		assert.Equal(t, http.StatusServiceUnavailable, serve().Code)
+	}
+
+	releaseA()
+	releaseB()
+
+	assert.True(t, breakers.Allow(backendB))
+
+	bodies := []string{serve().Body.String(), serve().Body.String()}
+	assert.ElementsMatch(t, []string{"A", "B"}, bodies)
+}
@@ -0,0 +1,114 @@
+package balancer
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+)
+
+// Machine-readable error codes returned in ErrorResponse.
+const (
+	// ErrCodeNoAvailableBackends means that there is no healthy backend to handle request.
+	ErrCodeNoAvailableBackends = "no_available_backends"
+	// ErrCodeUnknownBackend means that strategy returned backend balancer knows nothing about.
+	ErrCodeUnknownBackend = "unknown_backend"
+	// ErrCodeUpstreamConnect means that connection to backend can not be established.
+	ErrCodeUpstreamConnect = "upstream_connect_failed"
+	// ErrCodeUpstreamTLS means that TLS handshake with backend failed.
+	ErrCodeUpstreamTLS = "upstream_tls_error"
+	// ErrCodeUpstreamTimeout means that backend did not respond in time.
+	ErrCodeUpstreamTimeout = "upstream_timeout"
+	// ErrCodeUpstream means any other error while proxying request to backend.
+	ErrCodeUpstream = "upstream_error"
+	// ErrCodeRequestBodyTooLarge means that request body exceeds the limit.
+	ErrCodeRequestBodyTooLarge = "request_body_too_large"
+	// ErrCodeConcurrencyLimit means that backend has no free slot for request and waiting for it failed.
+	ErrCodeConcurrencyLimit = "concurrency_limit_exceeded"
+)
+
+// StatusClientClosedRequest is used only in logs when client disconnects before response is written.
+const StatusClientClosedRequest = 499
+
+// retryAfterSeconds is sent in Retry-After header with 503 responses.
+const retryAfterSeconds = 1
+
+var errNoAvailableBackends = errors.New("no available backends")
+
+// errorClass describes how proxy error should be reported to client.
+type errorClass struct {
+	statusCode int
+	errCode    string
+}
+
+// classifyProxyError maps error of reverse proxy to status code and error code.
+// Returned statusCode is StatusClientClosedRequest if the client went away.
+func classifyProxyError(r *http.Request, err error) errorClass {
+	if errors.Is(err, context.Canceled) && r.Context().Err() != nil {
+		return errorClass{statusCode: StatusClientClosedRequest}
+	}
+
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return errorClass{statusCode: http.StatusRequestEntityTooLarge, errCode: ErrCodeRequestBodyTooLarge}
+	}
+
+	if isTimeout(err) {
+		return errorClass{statusCode: http.StatusGatewayTimeout, errCode: ErrCodeUpstreamTimeout}
+	}
+
+	if isTLSError(err) {
+		return errorClass{statusCode: http.StatusBadGateway, errCode: ErrCodeUpstreamTLS}
+	}
+
+	if isConnectError(err) {
+		return errorClass{statusCode: http.StatusBadGateway, errCode: ErrCodeUpstreamConnect}
+	}
+
+	return errorClass{statusCode: http.StatusBadGateway, errCode: ErrCodeUpstream}
+}
+
+func isTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+func isConnectError(err error) bool {
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return true
+	}
+
+	var dnsErr *net.DNSError
+	return errors.As(err, &dnsErr)
+}
+
+func isTLSError(err error) bool {
+	var (
+		recordHeaderErr  tls.RecordHeaderError
+		alertErr         tls.AlertError
+		verificationErr  *tls.CertificateVerificationError
+		unknownAuthority x509.UnknownAuthorityError
+		invalidCert      x509.CertificateInvalidError
+		hostnameMismatch x509.HostnameError
+	)
+
+	return errors.As(err, &recordHeaderErr) ||
+		errors.As(err, &alertErr) ||
+		errors.As(err, &verificationErr) ||
+		errors.As(err, &unknownAuthority) ||
+		errors.As(err, &invalidCert) ||
+		errors.As(err, &hostnameMismatch)
+}
+
+// SetRetryAfter asks client to retry request later. It is set in responses to requests rejected because of overload.
+func SetRetryAfter(w http.ResponseWriter) {
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfterSeconds))
+}
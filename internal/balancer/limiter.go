@@ -0,0 +1,12 @@
+package balancer
+
+import "context"
+
+// ConcurrencyLimiter limits amount of concurrent requests to each backend.
+type ConcurrencyLimiter interface {
+	// TryAcquire takes free slot of the backend. It returns false if there is no free slot.
+	TryAcquire(backend string) (release func(), ok bool)
+	// Acquire takes free slot of the backend or waits for it.
+	// If slot is not acquired, request is rejected with 503 status code.
+	Acquire(ctx context.Context, backend string) (release func(), err error)
+}
@@ -52,3 +52,17 @@ func (mr *MockStrategyMockRecorder) ChooseBackend() *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ChooseBackend", reflect.TypeOf((*MockStrategy)(nil).ChooseBackend))
 }
+
+// IsAvailable mocks base method.
+func (m *MockStrategy) IsAvailable(backend string) bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IsAvailable", backend)
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// IsAvailable indicates an expected call of IsAvailable.
+func (mr *MockStrategyMockRecorder) IsAvailable(backend any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IsAvailable", reflect.TypeOf((*MockStrategy)(nil).IsAvailable), backend)
+}
@@ -0,0 +1,30 @@
+package balancer
+
+import "time"
+
+// ResponseObserver is notified about requests proxied to backends.
+type ResponseObserver interface {
+	// ObserveRequest is called before request is proxied to the backend. Backends chosen by strategy,
+	// but not proxied to, like busy ones, are never observed, so it is the place to reserve trial requests.
+	ObserveRequest(backend string)
+	// ObserveResponse is called after the backend handled request.
+	// Status code is the one returned to client, so errors of reverse proxy are included.
+	ObserveResponse(backend string, statusCode int, latency time.Duration)
+}
+
+// ResponseObservers notifies all observers in order.
+type ResponseObservers []ResponseObserver
+
+// ObserveRequest calls ObserveRequest of all observers.
+func (observers ResponseObservers) ObserveRequest(backend string) {
+	for _, observer := range observers {
+		observer.ObserveRequest(backend)
+	}
+}
+
+// ObserveResponse calls ObserveResponse of all observers.
+func (observers ResponseObservers) ObserveResponse(backend string, statusCode int, latency time.Duration) {
+	for _, observer := range observers {
+		observer.ObserveResponse(backend, statusCode, latency)
+	}
+}
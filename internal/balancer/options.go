@@ -0,0 +1,48 @@
+package balancer
+
+import (
+	"time"
+
+	"github.com/AleksandrMatsko/cloudru-balancer/internal/affinity"
+	"github.com/AleksandrMatsko/cloudru-balancer/internal/errorpage"
+)
+
+// Option configures Balancer.
+type Option func(*Balancer)
+
+// WithRequestTimeout limits each proxied request by timeout. Zero timeout disables the limit.
+func WithRequestTimeout(timeout time.Duration) Option {
+	return func(b *Balancer) {
+		b.requestTimeout = timeout
+	}
+}
+
+// WithErrorPages makes Balancer write errors to client with given renderer.
+// Without it built-in templates are used.
+func WithErrorPages(errorPages *errorpage.Renderer) Option {
+	return func(b *Balancer) {
+		b.errorPages = errorPages
+	}
+}
+
+// WithAffinity makes clients stick to the chosen backend while it is available.
+func WithAffinity(cookie *affinity.Cookie) Option {
+	return func(b *Balancer) {
+		b.affinity = cookie
+	}
+}
+
+// WithObserver makes Balancer notify observer about proxied requests.
+func WithObserver(observer ResponseObserver) Option {
+	return func(b *Balancer) {
+		b.observer = observer
+	}
+}
+
+// WithLimiter makes Balancer send requests exceeding concurrency of the backend to other backends
+// or wait for it.
+func WithLimiter(limiter ConcurrencyLimiter) Option {
+	return func(b *Balancer) {
+		b.limiter = limiter
+	}
+}
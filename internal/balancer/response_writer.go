@@ -0,0 +1,39 @@
+package balancer
+
+import "net/http"
+
+// statusRecorder remembers status code written to the underlying ResponseWriter.
+type statusRecorder struct {
+	http.ResponseWriter
+	statusCode int
+}
+
+func (rec *statusRecorder) WriteHeader(statusCode int) {
+	if rec.statusCode == 0 {
+		rec.statusCode = statusCode
+	}
+
+	rec.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (rec *statusRecorder) Write(b []byte) (int, error) {
+	if rec.statusCode == 0 {
+		rec.statusCode = http.StatusOK
+	}
+
+	return rec.ResponseWriter.Write(b)
+}
+
+// Unwrap is used by http.ResponseController to reach the underlying ResponseWriter.
+func (rec *statusRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
+// status returns written status code. If nothing was written, http.StatusOK will be sent by the server.
+func (rec *statusRecorder) status() int {
+	if rec.statusCode == 0 {
+		return http.StatusOK
+	}
+
+	return rec.statusCode
+}
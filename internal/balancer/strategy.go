@@ -4,4 +4,6 @@ package balancer
 type Strategy interface {
 	// ChooseBackend returns backend host which is ready to receive request.
 	ChooseBackend() string
+	// IsAvailable reports if given backend is ready to receive request.
+	IsAvailable(backend string) bool
 }
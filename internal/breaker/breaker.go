@@ -0,0 +1,193 @@
+// breaker contains per backend circuit breakers.
+package breaker
+
+import (
+	"sync"
+	"time"
+)
+
+// State of the circuit breaker.
+type State int
+
+const (
+	// StateClosed means that requests pass to the backend.
+	StateClosed State = iota
+	// StateOpen means that backend does not receive any requests.
+	StateOpen
+	// StateHalfOpen means that backend receives limited amount of trial requests.
+	StateHalfOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// Settings of the circuit breaker.
+type Settings struct {
+	// Window is the size of sliding window used to calculate error and slow call rates.
+	Window time.Duration
+	// MinRequests is the minimum amount of requests inside the window to trip the breaker.
+	MinRequests uint32
+	// FailureRateThreshold in range [0, 1]. Breaker opens when failure rate is greater or equal to it.
+	FailureRateThreshold float64
+	// SlowCallDuration is the latency after which request is considered slow.
+	SlowCallDuration time.Duration
+	// SlowCallRateThreshold in range [0, 1]. Breaker opens when slow call rate is greater or equal to it.
+	// Zero value disables latency based tripping.
+	SlowCallRateThreshold float64
+	// OpenTimeout is the time breaker stays open before switching to half-open.
+	OpenTimeout time.Duration
+	// HalfOpenMaxRequests is the amount of trial requests in half-open state.
+	// Breaker closes after this amount of successful trial requests.
+	HalfOpenMaxRequests uint32
+}
+
+// Breaker is a circuit breaker driven by error rate and latency over a sliding window.
+type Breaker struct {
+	settings Settings
+	now      func() time.Time
+	onChange func(from, to State)
+
+	lock               sync.Mutex
+	state              State
+	openedAt           time.Time
+	window             *window
+	halfOpenInFlight   uint32
+	halfOpenSuccessful uint32
+}
+
+// NewBreaker creates closed Breaker. Function onChange, if not nil, is called on every state change.
+func NewBreaker(settings Settings, onChange func(from, to State)) *Breaker {
+	return &Breaker{
+		settings: settings,
+		now:      time.Now,
+		onChange: onChange,
+		state:    StateClosed,
+		window:   newWindow(settings.Window),
+	}
+}
+
+// State returns current state of the breaker.
+func (b *Breaker) State() State {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.refreshState()
+	return b.state
+}
+
+// Allow reports if request may be sent to the backend. It does not take trial slot in half-open state,
+// so backends may be checked without sending requests to them.
+func (b *Breaker) Allow() bool {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.refreshState()
+
+	switch b.state {
+	case StateClosed:
+		return true
+	case StateHalfOpen:
+		return b.halfOpenInFlight+b.halfOpenSuccessful < b.settings.HalfOpenMaxRequests
+	default:
+		return false
+	}
+}
+
+// Start takes one of the trial slots in half-open state. It must be called when request is actually sent
+// to the backend and must be followed by Record. Concurrent requests allowed at the same time may take
+// slightly more slots than HalfOpenMaxRequests.
+func (b *Breaker) Start() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.refreshState()
+
+	if b.state == StateHalfOpen {
+		b.halfOpenInFlight += 1
+	}
+}
+
+// Record result of the request to the backend.
+func (b *Breaker) Record(failed bool, latency time.Duration) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.refreshState()
+
+	switch b.state {
+	case StateClosed:
+		now := b.now()
+		slow := b.settings.SlowCallDuration > 0 && latency >= b.settings.SlowCallDuration
+		b.window.record(now, failed, slow)
+
+		if b.shouldTrip(now) {
+			b.setState(StateOpen)
+		}
+	case StateHalfOpen:
+		if b.halfOpenInFlight > 0 {
+			b.halfOpenInFlight -= 1
+		}
+
+		if failed {
+			b.setState(StateOpen)
+			return
+		}
+
+		b.halfOpenSuccessful += 1
+		if b.halfOpenSuccessful >= b.settings.HalfOpenMaxRequests {
+			b.setState(StateClosed)
+		}
+	case StateOpen:
+		// Requests started before the breaker opened are ignored.
+	}
+}
+
+func (b *Breaker) shouldTrip(now time.Time) bool {
+	total, failures, slow := b.window.stats(now)
+	if total == 0 || total < b.settings.MinRequests {
+		return false
+	}
+
+	if float64(failures)/float64(total) >= b.settings.FailureRateThreshold {
+		return true
+	}
+
+	return b.settings.SlowCallRateThreshold > 0 &&
+		float64(slow)/float64(total) >= b.settings.SlowCallRateThreshold
+}
+
+// refreshState switches open breaker to half-open after open timeout. Must be called under lock.
+func (b *Breaker) refreshState() {
+	if b.state == StateOpen && b.now().Sub(b.openedAt) >= b.settings.OpenTimeout {
+		b.setState(StateHalfOpen)
+	}
+}
+
+// setState must be called under lock.
+func (b *Breaker) setState(state State) {
+	from := b.state
+	b.state = state
+	b.halfOpenInFlight = 0
+	b.halfOpenSuccessful = 0
+
+	if state == StateOpen {
+		b.openedAt = b.now()
+	}
+	if state == StateClosed {
+		b.window.reset()
+	}
+
+	if b.onChange != nil {
+		b.onChange(from, state)
+	}
+}
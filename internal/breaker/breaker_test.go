@@ -0,0 +1,183 @@
+package breaker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func newTestBreaker(clock *fakeClock) *Breaker {
+	b := NewBreaker(Settings{
+		Window:                10 * time.Second,
+		MinRequests:           4,
+		FailureRateThreshold:  0.5,
+		SlowCallDuration:      time.Second,
+		SlowCallRateThreshold: 0.75,
+		OpenTimeout:           30 * time.Second,
+		HalfOpenMaxRequests:   2,
+	}, nil)
+	b.now = clock.Now
+
+	return b
+}
+
+func TestBreaker(t *testing.T) {
+	t.Run("stays closed with low error rate", func(t *testing.T) {
+		t.Parallel()
+
+		clock := &fakeClock{now: time.Unix(1000, 0)}
+		b := newTestBreaker(clock)
+
+		b.Record(true, time.Millisecond)
+		b.Record(false, time.Millisecond)
+		b.Record(false, time.Millisecond)
+		b.Record(false, time.Millisecond)
+
+		assert.Equal(t, StateClosed, b.State())
+		assert.True(t, b.Allow())
+	})
+
+	t.Run("does not open with less than min requests", func(t *testing.T) {
+		t.Parallel()
+
+		clock := &fakeClock{now: time.Unix(1000, 0)}
+		b := newTestBreaker(clock)
+
+		b.Record(true, time.Millisecond)
+		b.Record(true, time.Millisecond)
+		b.Record(true, time.Millisecond)
+
+		assert.Equal(t, StateClosed, b.State())
+	})
+
+	t.Run("opens with high error rate", func(t *testing.T) {
+		t.Parallel()
+
+		clock := &fakeClock{now: time.Unix(1000, 0)}
+		b := newTestBreaker(clock)
+
+		b.Record(true, time.Millisecond)
+		b.Record(false, time.Millisecond)
+		b.Record(true, time.Millisecond)
+		b.Record(false, time.Millisecond)
+
+		assert.Equal(t, StateOpen, b.State())
+		assert.False(t, b.Allow())
+	})
+
+	t.Run("opens with high slow call rate", func(t *testing.T) {
+		t.Parallel()
+
+		clock := &fakeClock{now: time.Unix(1000, 0)}
+		b := newTestBreaker(clock)
+
+		for range 4 {
+			b.Record(false, 2*time.Second)
+		}
+
+		assert.Equal(t, StateOpen, b.State())
+	})
+
+	t.Run("forgets requests outside the window", func(t *testing.T) {
+		t.Parallel()
+
+		clock := &fakeClock{now: time.Unix(1000, 0)}
+		b := newTestBreaker(clock)
+
+		b.Record(true, time.Millisecond)
+		b.Record(true, time.Millisecond)
+		b.Record(true, time.Millisecond)
+
+		clock.now = clock.now.Add(11 * time.Second)
+
+		b.Record(true, time.Millisecond)
+
+		assert.Equal(t, StateClosed, b.State())
+	})
+
+	t.Run("closes after successful trial requests", func(t *testing.T) {
+		t.Parallel()
+
+		clock := &fakeClock{now: time.Unix(1000, 0)}
+		b := newTestBreaker(clock)
+
+		for range 4 {
+			b.Record(true, time.Millisecond)
+		}
+
+		clock.now = clock.now.Add(30 * time.Second)
+
+		assert.Equal(t, StateHalfOpen, b.State())
+		assert.True(t, b.Allow())
+		b.Start()
+		assert.True(t, b.Allow())
+		b.Start()
+		assert.False(t, b.Allow())
+
+		b.Record(false, time.Millisecond)
+
+		assert.Equal(t, StateHalfOpen, b.State())
+		assert.False(t, b.Allow())
+
+		b.Record(false, time.Millisecond)
+
+		assert.Equal(t, StateClosed, b.State())
+		assert.True(t, b.Allow())
+	})
+
+	t.Run("opens again after failed trial request", func(t *testing.T) {
+		t.Parallel()
+
+		clock := &fakeClock{now: time.Unix(1000, 0)}
+		b := newTestBreaker(clock)
+
+		for range 4 {
+			b.Record(true, time.Millisecond)
+		}
+
+		clock.now = clock.now.Add(30 * time.Second)
+
+		assert.True(t, b.Allow())
+		b.Start()
+
+		b.Record(true, time.Millisecond)
+
+		assert.Equal(t, StateOpen, b.State())
+		assert.False(t, b.Allow())
+	})
+
+	t.Run("checks do not take trial slots", func(t *testing.T) {
+		t.Parallel()
+
+		clock := &fakeClock{now: time.Unix(1000, 0)}
+		b := newTestBreaker(clock)
+
+		for range 4 {
+			b.Record(true, time.Millisecond)
+		}
+
+		clock.now = clock.now.Add(30 * time.Second)
+
+		// Backend is considered many times, but request is not sent to it.
+		for range 10 {
+			assert.True(t, b.Allow())
+		}
+
+		for range 2 {
+			assert.True(t, b.Allow())
+			b.Start()
+			b.Record(false, time.Millisecond)
+		}
+
+		assert.Equal(t, StateClosed, b.State())
+	})
+}
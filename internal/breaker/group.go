@@ -0,0 +1,91 @@
+package breaker
+
+import (
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Group holds circuit breakers for the set of backends.
+// It may be used as backend filter for strategies and as response observer for balancer.
+type Group struct {
+	logger   *slog.Logger
+	settings Settings
+	lock     sync.RWMutex
+	breakers map[string]*Breaker
+}
+
+// NewGroup creates Group with closed breaker for each backend.
+func NewGroup(logger *slog.Logger, settings Settings, backends []string) *Group {
+	g := &Group{
+		logger:   logger,
+		settings: settings,
+		breakers: make(map[string]*Breaker, len(backends)),
+	}
+
+	for _, backend := range backends {
+		g.AddBackend(backend)
+	}
+
+	return g
+}
+
+// AddBackend creates closed breaker for the backend.
+func (g *Group) AddBackend(backend string) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	if _, ok := g.breakers[backend]; ok {
+		return
+	}
+
+	backendLogger := g.logger.With(slog.String("backend", backend))
+	g.breakers[backend] = NewBreaker(g.settings, func(from, to State) {
+		backendLogger.Warn("Circuit breaker state changed",
+			slog.String("from", from.String()),
+			slog.String("to", to.String()),
+		)
+	})
+}
+
+// RemoveBackend removes breaker of the backend.
+func (g *Group) RemoveBackend(backend string) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	delete(g.breakers, backend)
+}
+
+func (g *Group) get(backend string) (*Breaker, bool) {
+	g.lock.RLock()
+	defer g.lock.RUnlock()
+
+	b, ok := g.breakers[backend]
+	return b, ok
+}
+
+// Allow reports if request may be sent to the backend.
+// Backends unknown to the group are always allowed.
+func (g *Group) Allow(backend string) bool {
+	if b, ok := g.get(backend); ok {
+		return b.Allow()
+	}
+
+	return true
+}
+
+// ObserveRequest takes trial slot of the backend if its breaker is half-open.
+func (g *Group) ObserveRequest(backend string) {
+	if b, ok := g.get(backend); ok {
+		b.Start()
+	}
+}
+
+// ObserveResponse records the result of request to the backend.
+// Responses with 5xx status codes are considered as failures.
+func (g *Group) ObserveResponse(backend string, statusCode int, latency time.Duration) {
+	if b, ok := g.get(backend); ok {
+		b.Record(statusCode >= http.StatusInternalServerError, latency)
+	}
+}
@@ -0,0 +1,46 @@
+package breaker
+
+import (
+	"log/slog"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/AleksandrMatsko/cloudru-balancer/internal/strategies"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGroup_halfOpenCandidateNotProxied(t *testing.T) {
+	t.Parallel()
+
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	g := NewGroup(slog.Default(), Settings{
+		Window:               10 * time.Second,
+		MinRequests:          1,
+		FailureRateThreshold: 0.5,
+		OpenTimeout:          30 * time.Second,
+		HalfOpenMaxRequests:  1,
+	}, []string{"A"})
+	g.breakers["A"].now = clock.Now
+
+	strategy := strategies.NewRoundRobin([]string{"A"}, strategies.WithFilter(g))
+	strategy.UpdateBackendHealth("A", true)
+
+	g.ObserveRequest("A")
+	g.ObserveResponse("A", http.StatusBadGateway, time.Millisecond)
+	assert.Equal(t, "", strategy.ChooseBackend())
+
+	clock.now = clock.now.Add(30 * time.Second)
+
+	// Half-open backend is chosen, but request is not proxied to it, for example because of concurrency limit.
+	assert.Equal(t, "A", strategy.ChooseBackend())
+	assert.True(t, strategy.IsAvailable("A"))
+
+	// It is still chosen later and closes after successful trial request.
+	assert.Equal(t, "A", strategy.ChooseBackend())
+
+	g.ObserveRequest("A")
+	g.ObserveResponse("A", http.StatusOK, time.Millisecond)
+
+	assert.Equal(t, StateClosed, g.breakers["A"].State())
+}
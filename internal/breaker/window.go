@@ -0,0 +1,64 @@
+package breaker
+
+import "time"
+
+const windowBuckets = 10
+
+type bucket struct {
+	epoch    int64
+	total    uint32
+	failures uint32
+	slow     uint32
+}
+
+// window is a sliding time window split into fixed amount of buckets.
+type window struct {
+	bucketWidth time.Duration
+	buckets     [windowBuckets]bucket
+}
+
+func newWindow(size time.Duration) *window {
+	width := size / windowBuckets
+	if width <= 0 {
+		width = time.Millisecond
+	}
+
+	return &window{bucketWidth: width}
+}
+
+func (w *window) record(now time.Time, failed, slow bool) {
+	epoch := now.UnixNano() / int64(w.bucketWidth)
+	b := &w.buckets[epoch%windowBuckets]
+
+	if b.epoch != epoch {
+		*b = bucket{epoch: epoch}
+	}
+
+	b.total += 1
+	if failed {
+		b.failures += 1
+	}
+	if slow {
+		b.slow += 1
+	}
+}
+
+// stats returns amount of all, failed and slow calls inside the window.
+func (w *window) stats(now time.Time) (total, failures, slow uint32) {
+	epoch := now.UnixNano() / int64(w.bucketWidth)
+
+	for i := range w.buckets {
+		b := &w.buckets[i]
+		if b.epoch > epoch-windowBuckets && b.epoch <= epoch {
+			total += b.total
+			failures += b.failures
+			slow += b.slow
+		}
+	}
+
+	return total, failures, slow
+}
+
+func (w *window) reset() {
+	w.buckets = [windowBuckets]bucket{}
+}
@@ -0,0 +1,284 @@
+// cache stores responses of backends in memory according to HTTP caching rules.
+package cache
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Header tells client how the request was served by the cache.
+const Header = "X-Cache"
+
+// Results of serving request.
+const (
+	// ResultHit means response was served from the cache.
+	ResultHit = "HIT"
+	// ResultMiss means response was received from backend.
+	ResultMiss = "MISS"
+	// ResultRevalidated means stale response was confirmed by backend and served from the cache.
+	ResultRevalidated = "REVALIDATED"
+	// ResultBypass means request can not be served from the cache.
+	ResultBypass = "BYPASS"
+)
+
+// Settings of the cache.
+type Settings struct {
+	// MaxBytes is the maximum total size of stored responses.
+	MaxBytes int64
+	// MaxEntryBytes is the maximum size of single stored response.
+	MaxEntryBytes int64
+	// Bypass reports if request must not be served from the cache, for example, because it is sent
+	// to particular backend. It may be nil.
+	Bypass func(r *http.Request) bool
+}
+
+// Cache serves GET and HEAD requests from memory if stored response is fresh.
+// Stale responses with ETag or Last-Modified are revalidated with conditional requests.
+// Concurrent misses for the same key are coalesced, so only one request reaches backend.
+type Cache struct {
+	next     http.Handler
+	settings Settings
+	storage  *lru
+	now      func() time.Time
+
+	flightsLock sync.Mutex
+	flights     map[string]chan struct{}
+
+	onResult func(result string)
+	onSize   func(bytes int64)
+}
+
+// New creates Cache in front of next handler. onResult is called with the result of every request,
+// onSize is called with the total size of stored responses when it changes. Both may be nil.
+func New(next http.Handler, settings Settings, onResult func(result string), onSize func(bytes int64)) *Cache {
+	return &Cache{
+		next:     next,
+		settings: settings,
+		storage:  newLRU(settings.MaxBytes),
+		now:      time.Now,
+		flights:  make(map[string]chan struct{}),
+		onResult: onResult,
+		onSize:   onSize,
+	}
+}
+
+// ServeHTTP serves request from the cache or sends it to the next handler.
+func (c *Cache) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !c.cacheableRequest(r) {
+		w.Header().Set(Header, ResultBypass)
+		c.result(ResultBypass)
+		c.next.ServeHTTP(w, r)
+		return
+	}
+
+	primaryKey := r.Method + " " + r.Host + r.URL.RequestURI()
+
+	for coalesce := true; ; coalesce = false {
+		key := requestKey(primaryKey, c.storage.varyHeaders(primaryKey), r)
+
+		stored, ok := c.storage.get(key)
+		if ok && stored.fresh(c.now()) {
+			c.serveEntry(w, r, stored, ResultHit)
+			return
+		}
+
+		if !coalesce {
+			c.fetch(w, r, primaryKey, stored)
+			return
+		}
+
+		wait, leader := c.join(key)
+		if leader {
+			c.lead(w, r, primaryKey, key, stored)
+			return
+		}
+
+		select {
+		case <-wait:
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func (c *Cache) cacheableRequest(r *http.Request) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+
+	if c.settings.Bypass != nil && c.settings.Bypass(r) {
+		return false
+	}
+
+	if r.Header.Get("Authorization") != "" {
+		return false
+	}
+
+	return !parseCacheControl(r.Header).has("no-store")
+}
+
+// requestKey returns key of the response for the request according to headers response varies on.
+func requestKey(primaryKey string, varyHeaders []string, r *http.Request) string {
+	if len(varyHeaders) == 0 {
+		return primaryKey
+	}
+
+	var key strings.Builder
+	key.WriteString(primaryKey)
+
+	for _, name := range varyHeaders {
+		key.WriteString("\n")
+		key.WriteString(name)
+		key.WriteString(":")
+		key.WriteString(strings.Join(r.Header.Values(name), ","))
+	}
+
+	return key.String()
+}
+
+func (c *Cache) join(key string) (chan struct{}, bool) {
+	c.flightsLock.Lock()
+	defer c.flightsLock.Unlock()
+
+	if wait, ok := c.flights[key]; ok {
+		return wait, false
+	}
+
+	c.flights[key] = make(chan struct{})
+
+	return nil, true
+}
+
+func (c *Cache) leave(key string) {
+	c.flightsLock.Lock()
+	defer c.flightsLock.Unlock()
+
+	close(c.flights[key])
+	delete(c.flights, key)
+}
+
+// lead fetches response for requests waiting for the same key.
+func (c *Cache) lead(w http.ResponseWriter, r *http.Request, primaryKey, key string, stale *entry) {
+	defer c.leave(key)
+
+	c.fetch(w, r, primaryKey, stale)
+}
+
+// fetch sends request to the next handler and stores response if it is cacheable.
+// If stale response may be revalidated, request is made conditional.
+func (c *Cache) fetch(w http.ResponseWriter, r *http.Request, primaryKey string, stale *entry) {
+	revalidating := stale != nil && stale.revalidatable()
+
+	req := r
+	if revalidating {
+		req = r.Clone(r.Context())
+		req.Header.Del("If-None-Match")
+		req.Header.Del("If-Modified-Since")
+
+		if etag := stale.header.Get("ETag"); etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		if lastModified := stale.header.Get("Last-Modified"); lastModified != "" {
+			req.Header.Set("If-Modified-Since", lastModified)
+		}
+	}
+
+	capture := &captureWriter{
+		ResponseWriter: w,
+		revalidating:   revalidating,
+		maxBody:        c.settings.MaxEntryBytes,
+	}
+	c.next.ServeHTTP(capture, req)
+
+	now := c.now()
+
+	if capture.notModified {
+		header := stale.header.Clone()
+		for _, name := range []string{"Cache-Control", "Expires", "Date", "ETag", "Last-Modified", "Age"} {
+			if values := capture.Header().Values(name); len(values) != 0 {
+				header[name] = values
+			}
+		}
+
+		revalidated := c.store(primaryKey, r, stale.statusCode, header, stale.body, now)
+		if revalidated == nil {
+			revalidated = stale
+		}
+
+		c.serveEntry(w, r, revalidated, ResultRevalidated)
+		return
+	}
+
+	c.result(ResultMiss)
+
+	if capture.wroteHeader && !capture.tooLarge {
+		c.store(primaryKey, r, capture.statusCode, capture.header, capture.body.Bytes(), now)
+	}
+}
+
+// store saves response if it is cacheable and returns stored entry.
+func (c *Cache) store(primaryKey string, r *http.Request, statusCode int, header http.Header, body []byte, now time.Time) *entry {
+	ttl, ok := freshness(statusCode, header, now)
+	if !ok {
+		return nil
+	}
+
+	e := &entry{
+		statusCode: statusCode,
+		header:     header,
+		body:       body,
+		storedAt:   now.Add(-age(header)),
+		expiresAt:  now.Add(ttl),
+	}
+
+	if ttl <= 0 && !e.revalidatable() {
+		return nil
+	}
+
+	names := varyHeaders(header)
+	e.key = requestKey(primaryKey, names, r)
+	e.size = entrySize(e.key, header, body)
+
+	if e.size > c.settings.MaxEntryBytes {
+		return nil
+	}
+
+	size := c.storage.set(primaryKey, names, e)
+	if c.onSize != nil {
+		c.onSize(size)
+	}
+
+	return e
+}
+
+func (c *Cache) serveEntry(w http.ResponseWriter, r *http.Request, e *entry, result string) {
+	header := w.Header()
+	clear(header)
+
+	for name, values := range e.header {
+		header[name] = values
+	}
+
+	header.Set("Age", strconv.FormatInt(int64(c.now().Sub(e.storedAt)/time.Second), 10))
+	header.Set(Header, result)
+	c.result(result)
+
+	if etag := e.header.Get("ETag"); etag != "" && r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.WriteHeader(e.statusCode)
+	if r.Method != http.MethodHead {
+		_, _ = w.Write(e.body)
+	}
+}
+
+func (c *Cache) result(result string) {
+	if c.onResult != nil {
+		c.onResult(result)
+	}
+}
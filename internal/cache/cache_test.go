@@ -0,0 +1,326 @@
+package cache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type backend struct {
+	calls   atomic.Int32
+	handler func(w http.ResponseWriter, r *http.Request, call int32)
+}
+
+func (b *backend) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	b.handler(w, r, b.calls.Add(1))
+}
+
+func newTestCache(next http.Handler) (*Cache, *time.Time) {
+	now := time.Now()
+	c := New(next, Settings{MaxBytes: 1 << 20, MaxEntryBytes: 1 << 10}, nil, nil)
+	c.now = func() time.Time { return now }
+
+	return c, &now
+}
+
+func get(c *Cache, prepare ...func(r *http.Request)) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "http://test.url/path", nil)
+	for _, p := range prepare {
+		p(req)
+	}
+
+	rec := httptest.NewRecorder()
+	c.ServeHTTP(rec, req)
+
+	return rec
+}
+
+func TestCache_Freshness(t *testing.T) {
+	t.Parallel()
+
+	b := &backend{handler: func(w http.ResponseWriter, _ *http.Request, call int32) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Header().Set("ETag", `"v`+strconv.Itoa(int(call))+`"`)
+		_, _ = w.Write([]byte(strconv.Itoa(int(call))))
+	}}
+	c, now := newTestCache(b)
+
+	first := get(c)
+	assert.Equal(t, "1", first.Body.String())
+	assert.Equal(t, ResultMiss, first.Header().Get(Header))
+
+	second := get(c)
+	assert.Equal(t, "1", second.Body.String())
+	assert.Equal(t, ResultHit, second.Header().Get(Header))
+
+	notModified := get(c, func(r *http.Request) { r.Header.Set("If-None-Match", `"v1"`) })
+	assert.Equal(t, http.StatusNotModified, notModified.Code)
+	assert.Empty(t, notModified.Body.String())
+
+	*now = now.Add(30 * time.Second)
+	assert.Equal(t, "30", get(c).Header().Get("Age"))
+
+	*now = now.Add(31 * time.Second)
+	third := get(c)
+	assert.Equal(t, "2", third.Body.String())
+	assert.Equal(t, ResultMiss, third.Header().Get(Header))
+}
+
+func TestCache_NotStored(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		header  http.Header
+		status  int
+		prepare func(r *http.Request)
+	}{
+		{name: "no-store", header: http.Header{"Cache-Control": {"no-store"}}, status: http.StatusOK},
+		{name: "private", header: http.Header{"Cache-Control": {"private, max-age=60"}}, status: http.StatusOK},
+		{name: "without freshness", header: http.Header{}, status: http.StatusOK},
+		{name: "vary all", header: http.Header{"Cache-Control": {"max-age=60"}, "Vary": {"*"}}, status: http.StatusOK},
+		{name: "set cookie", header: http.Header{"Cache-Control": {"max-age=60"}, "Set-Cookie": {"a=b"}}, status: http.StatusOK},
+		{name: "server error", header: http.Header{"Cache-Control": {"max-age=60"}}, status: http.StatusInternalServerError},
+		{
+			name:    "request with authorization",
+			header:  http.Header{"Cache-Control": {"max-age=60"}},
+			status:  http.StatusOK,
+			prepare: func(r *http.Request) { r.Header.Set("Authorization", "Bearer token") },
+		},
+		{
+			name:    "request with no-store",
+			header:  http.Header{"Cache-Control": {"max-age=60"}},
+			status:  http.StatusOK,
+			prepare: func(r *http.Request) { r.Header.Set("Cache-Control", "no-store") },
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			b := &backend{handler: func(w http.ResponseWriter, _ *http.Request, _ int32) {
+				for name, values := range test.header {
+					w.Header()[name] = values
+				}
+				w.WriteHeader(test.status)
+			}}
+			c, _ := newTestCache(b)
+
+			prepare := func(*http.Request) {}
+			if test.prepare != nil {
+				prepare = test.prepare
+			}
+
+			get(c, prepare)
+			get(c, prepare)
+			assert.Equal(t, int32(2), b.calls.Load())
+		})
+	}
+}
+
+func TestCache_Revalidation(t *testing.T) {
+	t.Parallel()
+
+	b := &backend{handler: func(w http.ResponseWriter, r *http.Request, call int32) {
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("ETag", `"v1"`)
+
+		if r.Header.Get("If-None-Match") == `"v1"` && call < 3 {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		w.Header().Set("ETag", `"v`+strconv.Itoa(int(call))+`"`)
+		_, _ = w.Write([]byte("body " + strconv.Itoa(int(call))))
+	}}
+	c, _ := newTestCache(b)
+
+	first := get(c)
+	assert.Equal(t, "body 1", first.Body.String())
+	assert.Equal(t, ResultMiss, first.Header().Get(Header))
+
+	second := get(c)
+	assert.Equal(t, http.StatusOK, second.Code)
+	assert.Equal(t, "body 1", second.Body.String())
+	assert.Equal(t, ResultRevalidated, second.Header().Get(Header))
+
+	third := get(c)
+	assert.Equal(t, "body 3", third.Body.String())
+	assert.Equal(t, ResultMiss, third.Header().Get(Header))
+	assert.Equal(t, int32(3), b.calls.Load())
+}
+
+func TestCache_Vary(t *testing.T) {
+	t.Parallel()
+
+	b := &backend{handler: func(w http.ResponseWriter, r *http.Request, _ int32) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Header().Set("Vary", "Accept-Language")
+		_, _ = w.Write([]byte(r.Header.Get("Accept-Language")))
+	}}
+	c, _ := newTestCache(b)
+
+	withLanguage := func(language string) func(r *http.Request) {
+		return func(r *http.Request) { r.Header.Set("Accept-Language", language) }
+	}
+
+	assert.Equal(t, "en", get(c, withLanguage("en")).Body.String())
+	assert.Equal(t, "ru", get(c, withLanguage("ru")).Body.String())
+
+	hit := get(c, withLanguage("en"))
+	assert.Equal(t, "en", hit.Body.String())
+	assert.Equal(t, ResultHit, hit.Header().Get(Header))
+	assert.Equal(t, int32(2), b.calls.Load())
+}
+
+func TestCache_Eviction(t *testing.T) {
+	t.Parallel()
+
+	b := &backend{handler: func(w http.ResponseWriter, _ *http.Request, _ int32) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		_, _ = w.Write(make([]byte, 400))
+	}}
+
+	var size int64
+	c := New(b, Settings{MaxBytes: 1000, MaxEntryBytes: 500}, nil, func(bytes int64) { size = bytes })
+
+	request := func(path string) string {
+		rec := httptest.NewRecorder()
+		c.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "http://test.url/"+path, nil))
+
+		return rec.Header().Get(Header)
+	}
+
+	assert.Equal(t, ResultMiss, request("a"))
+	assert.Equal(t, ResultMiss, request("b"))
+	assert.Equal(t, ResultHit, request("a"))
+	assert.Equal(t, ResultMiss, request("c"))
+	assert.LessOrEqual(t, size, int64(1000))
+
+	assert.Equal(t, ResultHit, request("a"))
+	assert.Equal(t, ResultMiss, request("b"))
+
+	// Vary headers are kept only for stored responses.
+	for i := range 10 {
+		request(strconv.Itoa(i))
+	}
+	assert.Len(t, c.storage.vary, 2)
+}
+
+func TestLRU_vary(t *testing.T) {
+	t.Parallel()
+
+	l := newLRU(100)
+	newEntry := func(key string) *entry {
+		return &entry{key: key, size: 40}
+	}
+
+	l.set("A", []string{"Accept"}, newEntry("A\nAccept:json"))
+	l.set("A", []string{"Accept"}, newEntry("A\nAccept:xml"))
+	assert.Equal(t, []string{"Accept"}, l.varyHeaders("A"))
+
+	// One variant of A is evicted, another one is still stored.
+	l.set("B", nil, newEntry("B"))
+	assert.Equal(t, []string{"Accept"}, l.varyHeaders("A"))
+	assert.Len(t, l.vary, 2)
+
+	l.set("C", nil, newEntry("C"))
+	assert.Nil(t, l.varyHeaders("A"))
+	assert.Len(t, l.vary, 2)
+
+	// Replaced entry does not leave its primary key.
+	l.set("C", nil, newEntry("C"))
+	assert.Len(t, l.vary, 2)
+	assert.Equal(t, 1, l.vary["C"].entries)
+}
+
+func TestCache_Bypass(t *testing.T) {
+	t.Parallel()
+
+	b := &backend{handler: func(w http.ResponseWriter, _ *http.Request, _ int32) {
+		w.Header().Set("Cache-Control", "max-age=60")
+	}}
+	c, _ := newTestCache(b)
+
+	for range 2 {
+		rec := httptest.NewRecorder()
+		c.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "http://test.url/path", nil))
+		assert.Equal(t, ResultBypass, rec.Header().Get(Header))
+	}
+
+	assert.Equal(t, int32(2), b.calls.Load())
+}
+
+func TestCache_BypassSettings(t *testing.T) {
+	t.Parallel()
+
+	b := &backend{handler: func(w http.ResponseWriter, _ *http.Request, _ int32) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		_, _ = w.Write([]byte("body"))
+	}}
+	c := New(b, Settings{
+		MaxBytes:      1 << 20,
+		MaxEntryBytes: 1 << 10,
+		Bypass: func(r *http.Request) bool {
+			return r.Header.Get("X-Pinned") != ""
+		},
+	}, nil, nil)
+
+	pinned := func(r *http.Request) {
+		r.Header.Set("X-Pinned", "A")
+	}
+
+	assert.Equal(t, ResultMiss, get(c).Header().Get(Header))
+	assert.Equal(t, ResultBypass, get(c, pinned).Header().Get(Header))
+	assert.Equal(t, ResultBypass, get(c, pinned).Header().Get(Header))
+	assert.Equal(t, ResultHit, get(c).Header().Get(Header))
+
+	assert.Equal(t, int32(3), b.calls.Load())
+}
+
+func TestCache_CoalesceMisses(t *testing.T) {
+	t.Parallel()
+
+	release := make(chan struct{})
+	b := &backend{handler: func(w http.ResponseWriter, _ *http.Request, _ int32) {
+		<-release
+		w.Header().Set("Cache-Control", "max-age=60")
+		_, _ = w.Write([]byte("body"))
+	}}
+
+	results := map[string]int{}
+	var lock sync.Mutex
+	c := New(b, Settings{MaxBytes: 1 << 20, MaxEntryBytes: 1 << 10}, func(result string) {
+		lock.Lock()
+		defer lock.Unlock()
+		results[result]++
+	}, nil)
+
+	const clients = 10
+
+	var wg sync.WaitGroup
+	for range clients {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			rec := httptest.NewRecorder()
+			c.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "http://test.url/path", nil))
+			assert.Equal(t, "body", rec.Body.String())
+		}()
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), b.calls.Load())
+	assert.Equal(t, map[string]int{ResultMiss: 1, ResultHit: clients - 1}, results)
+}
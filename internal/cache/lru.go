@@ -0,0 +1,138 @@
+package cache
+
+import (
+	"container/list"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// entry is the stored response.
+type entry struct {
+	key        string
+	primaryKey string
+	statusCode int
+	header     http.Header
+	body       []byte
+	// storedAt is the time response was received or revalidated.
+	storedAt time.Time
+	// expiresAt is the time response becomes stale.
+	expiresAt time.Time
+	size      int64
+}
+
+func (e *entry) fresh(now time.Time) bool {
+	return now.Before(e.expiresAt)
+}
+
+func (e *entry) revalidatable() bool {
+	return e.header.Get("ETag") != "" || e.header.Get("Last-Modified") != ""
+}
+
+func entrySize(key string, header http.Header, body []byte) int64 {
+	size := int64(len(key) + len(body))
+	for name, values := range header {
+		for _, value := range values {
+			size += int64(len(name) + len(value))
+		}
+	}
+
+	return size
+}
+
+// lru is the storage of responses limited by total size. The least recently used responses are evicted first.
+type lru struct {
+	lock    sync.Mutex
+	maxSize int64
+	size    int64
+	order   *list.List
+	entries map[string]*list.Element
+	// vary maps primary key to names of headers response varies on.
+	// It is kept while there are stored entries with the primary key.
+	vary map[string]*variants
+}
+
+// variants of the response with the same primary key.
+type variants struct {
+	varyHeaders []string
+	entries     int
+}
+
+func newLRU(maxSize int64) *lru {
+	return &lru{
+		maxSize: maxSize,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+		vary:    make(map[string]*variants),
+	}
+}
+
+func (l *lru) varyHeaders(primaryKey string) []string {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if v, ok := l.vary[primaryKey]; ok {
+		return v.varyHeaders
+	}
+
+	return nil
+}
+
+func (l *lru) get(key string) (*entry, bool) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	element, ok := l.entries[key]
+	if !ok {
+		return nil, false
+	}
+
+	l.order.MoveToFront(element)
+
+	return element.Value.(*entry), true
+}
+
+// set stores entry and returns total size of stored entries.
+func (l *lru) set(primaryKey string, varyHeaders []string, e *entry) int64 {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if element, ok := l.entries[e.key]; ok {
+		l.removeElement(element)
+	}
+
+	if e.size > l.maxSize {
+		return l.size
+	}
+
+	v, ok := l.vary[primaryKey]
+	if !ok {
+		v = &variants{}
+		l.vary[primaryKey] = v
+	}
+	v.varyHeaders = varyHeaders
+	v.entries++
+
+	e.primaryKey = primaryKey
+	l.entries[e.key] = l.order.PushFront(e)
+	l.size += e.size
+
+	for l.size > l.maxSize {
+		l.removeElement(l.order.Back())
+	}
+
+	return l.size
+}
+
+func (l *lru) removeElement(element *list.Element) {
+	e := l.order.Remove(element).(*entry)
+	delete(l.entries, e.key)
+	l.size -= e.size
+
+	if v, ok := l.vary[e.primaryKey]; ok {
+		v.entries--
+		if v.entries <= 0 {
+			delete(l.vary, e.primaryKey)
+		}
+	}
+}
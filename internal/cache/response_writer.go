@@ -0,0 +1,79 @@
+package cache
+
+import (
+	"bytes"
+	"net/http"
+)
+
+// captureWriter passes response to the client and keeps its copy for storing.
+// When revalidating, 304 Not Modified response is kept from the client.
+type captureWriter struct {
+	http.ResponseWriter
+	revalidating bool
+	maxBody      int64
+
+	wroteHeader bool
+	notModified bool
+	statusCode  int
+	header      http.Header
+	body        bytes.Buffer
+	tooLarge    bool
+}
+
+func (c *captureWriter) WriteHeader(statusCode int) {
+	if c.wroteHeader {
+		return
+	}
+
+	if statusCode < http.StatusOK {
+		c.ResponseWriter.WriteHeader(statusCode)
+		return
+	}
+
+	c.wroteHeader = true
+
+	if c.revalidating && statusCode == http.StatusNotModified {
+		c.notModified = true
+		return
+	}
+
+	c.statusCode = statusCode
+	c.header = c.Header().Clone()
+	c.Header().Set(Header, ResultMiss)
+	c.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (c *captureWriter) Write(b []byte) (int, error) {
+	if !c.wroteHeader {
+		c.WriteHeader(http.StatusOK)
+	}
+
+	if c.notModified {
+		return len(b), nil
+	}
+
+	if !c.tooLarge {
+		if int64(c.body.Len()+len(b)) > c.maxBody {
+			c.tooLarge = true
+			c.body = bytes.Buffer{}
+		} else {
+			c.body.Write(b)
+		}
+	}
+
+	return c.ResponseWriter.Write(b)
+}
+
+// FlushError flushes response to the client unless 304 Not Modified response is kept from it.
+func (c *captureWriter) FlushError() error {
+	if c.notModified {
+		return nil
+	}
+
+	return http.NewResponseController(c.ResponseWriter).Flush()
+}
+
+// Unwrap is used by http.ResponseController to reach the underlying ResponseWriter.
+func (c *captureWriter) Unwrap() http.ResponseWriter {
+	return c.ResponseWriter
+}
@@ -0,0 +1,176 @@
+// coalesce shares single upstream call between identical in-flight requests.
+package coalesce
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// Header is set in responses shared with waiting requests.
+const Header = "X-Coalesced"
+
+// Results of serving request.
+const (
+	// ResultLeader means request was sent to backend and its response was shared.
+	ResultLeader = "leader"
+	// ResultShared means request received response of identical in-flight request.
+	ResultShared = "shared"
+	// ResultFallback means request waited for identical one, but its response could not be shared.
+	ResultFallback = "fallback"
+	// ResultBypass means request is not idempotent or is bypassed by settings and is never coalesced.
+	ResultBypass = "bypass"
+)
+
+// Settings of coalescing.
+type Settings struct {
+	// KeyHeaders are request headers which must be equal for requests to be identical,
+	// besides method and URL.
+	KeyHeaders []string
+	// MaxBodyBytes is the maximum size of response body shared with waiting requests.
+	MaxBodyBytes int64
+	// Bypass reports if request must not be coalesced, for example, because it is sent to particular backend.
+	// It may be nil.
+	Bypass func(r *http.Request) bool
+}
+
+// response is the copy of the response shared with waiting requests.
+type response struct {
+	statusCode int
+	header     http.Header
+	body       []byte
+}
+
+type flight struct {
+	done chan struct{}
+	// resp is nil if response can not be shared.
+	resp *response
+}
+
+// Coalescer sends only the first of identical GET and HEAD requests to the next handler.
+// Others wait for it and receive the same response. Unlike cache, response is not kept after that.
+type Coalescer struct {
+	next     http.Handler
+	settings Settings
+
+	lock    sync.Mutex
+	flights map[string]*flight
+
+	onResult func(result string)
+}
+
+// New creates Coalescer in front of next handler. onResult is called with the result of every request and may be nil.
+func New(next http.Handler, settings Settings, onResult func(result string)) *Coalescer {
+	keyHeaders := make([]string, 0, len(settings.KeyHeaders))
+	for _, name := range settings.KeyHeaders {
+		keyHeaders = append(keyHeaders, http.CanonicalHeaderKey(name))
+	}
+	settings.KeyHeaders = keyHeaders
+
+	return &Coalescer{
+		next:     next,
+		settings: settings,
+		flights:  make(map[string]*flight),
+		onResult: onResult,
+	}
+}
+
+// ServeHTTP sends request to the next handler or waits for identical in-flight request.
+func (c *Coalescer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if (r.Method != http.MethodGet && r.Method != http.MethodHead) || (c.settings.Bypass != nil && c.settings.Bypass(r)) {
+		c.result(ResultBypass)
+		c.next.ServeHTTP(w, r)
+		return
+	}
+
+	key := c.key(r)
+
+	c.lock.Lock()
+	f, ok := c.flights[key]
+	if !ok {
+		f = &flight{done: make(chan struct{})}
+		c.flights[key] = f
+	}
+	c.lock.Unlock()
+
+	if !ok {
+		c.lead(w, r, key, f)
+		return
+	}
+
+	select {
+	case <-f.done:
+	case <-r.Context().Done():
+		return
+	}
+
+	if f.resp == nil {
+		c.result(ResultFallback)
+		c.next.ServeHTTP(w, r)
+		return
+	}
+
+	c.result(ResultShared)
+	f.resp.write(w)
+}
+
+func (c *Coalescer) key(r *http.Request) string {
+	var key strings.Builder
+	key.WriteString(r.Method)
+	key.WriteString(" ")
+	key.WriteString(r.Host)
+	key.WriteString(r.URL.RequestURI())
+
+	for _, name := range c.settings.KeyHeaders {
+		key.WriteString("\n")
+		key.WriteString(name)
+		key.WriteString(":")
+		key.WriteString(strings.Join(r.Header.Values(name), ","))
+	}
+
+	return key.String()
+}
+
+func (c *Coalescer) lead(w http.ResponseWriter, r *http.Request, key string, f *flight) {
+	defer func() {
+		c.lock.Lock()
+		delete(c.flights, key)
+		c.lock.Unlock()
+
+		close(f.done)
+	}()
+
+	c.result(ResultLeader)
+
+	rec := &recordingWriter{ResponseWriter: w, maxBody: c.settings.MaxBodyBytes}
+	c.next.ServeHTTP(rec, r)
+
+	if rec.wroteHeader && !rec.tooLarge {
+		header := rec.header
+		// Cookies are not shared, because they may belong to the client of the first request.
+		header.Del("Set-Cookie")
+		header.Set(Header, "true")
+
+		f.resp = &response{
+			statusCode: rec.statusCode,
+			header:     header,
+			body:       rec.body.Bytes(),
+		}
+	}
+}
+
+func (resp *response) write(w http.ResponseWriter) {
+	header := w.Header()
+	for name, values := range resp.header {
+		header[name] = values
+	}
+
+	w.WriteHeader(resp.statusCode)
+	_, _ = w.Write(resp.body)
+}
+
+func (c *Coalescer) result(result string) {
+	if c.onResult != nil {
+		c.onResult(result)
+	}
+}
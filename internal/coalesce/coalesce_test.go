@@ -0,0 +1,201 @@
+package coalesce
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type results struct {
+	lock   sync.Mutex
+	counts map[string]int
+}
+
+func (r *results) onResult(result string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.counts[result]++
+}
+
+func serveConcurrently(c *Coalescer, requests []*http.Request, release chan struct{}) []*httptest.ResponseRecorder {
+	recorders := make([]*httptest.ResponseRecorder, len(requests))
+
+	var wg sync.WaitGroup
+	for i, req := range requests {
+		recorders[i] = httptest.NewRecorder()
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.ServeHTTP(recorders[i], req)
+		}()
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	return recorders
+}
+
+func TestCoalescer(t *testing.T) {
+	t.Parallel()
+
+	settings := Settings{KeyHeaders: []string{"accept"}, MaxBodyBytes: 10}
+
+	t.Run("identical requests share response", func(t *testing.T) {
+		t.Parallel()
+
+		var calls atomic.Int32
+		release := make(chan struct{})
+		next := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			calls.Add(1)
+			<-release
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "secret"})
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte("body"))
+		})
+
+		res := &results{counts: map[string]int{}}
+		c := New(next, settings, res.onResult)
+
+		requests := make([]*http.Request, 5)
+		for i := range requests {
+			requests[i] = httptest.NewRequest(http.MethodGet, "http://test.url/path?a=1", nil)
+		}
+
+		recorders := serveConcurrently(c, requests, release)
+
+		assert.Equal(t, int32(1), calls.Load())
+		assert.Equal(t, map[string]int{ResultLeader: 1, ResultShared: 4}, res.counts)
+
+		shared := 0
+		for _, rec := range recorders {
+			assert.Equal(t, http.StatusCreated, rec.Code)
+			assert.Equal(t, "body", rec.Body.String())
+
+			if rec.Header().Get(Header) == "true" {
+				shared++
+				assert.Empty(t, rec.Header().Get("Set-Cookie"))
+			}
+		}
+		assert.Equal(t, 4, shared)
+	})
+
+	t.Run("different key headers are not coalesced", func(t *testing.T) {
+		t.Parallel()
+
+		var calls atomic.Int32
+		release := make(chan struct{})
+		next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls.Add(1)
+			<-release
+			_, _ = w.Write([]byte(r.Header.Get("Accept")))
+		})
+
+		c := New(next, settings, nil)
+
+		first := httptest.NewRequest(http.MethodGet, "http://test.url/path", nil)
+		first.Header.Set("Accept", "a")
+		second := httptest.NewRequest(http.MethodGet, "http://test.url/path", nil)
+		second.Header.Set("Accept", "b")
+
+		recorders := serveConcurrently(c, []*http.Request{first, second}, release)
+
+		assert.Equal(t, int32(2), calls.Load())
+		assert.Equal(t, "a", recorders[0].Body.String())
+		assert.Equal(t, "b", recorders[1].Body.String())
+	})
+
+	t.Run("too large response is not shared", func(t *testing.T) {
+		t.Parallel()
+
+		var calls atomic.Int32
+		release := make(chan struct{})
+		next := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			if calls.Add(1) == 1 {
+				<-release
+			}
+			_, _ = w.Write([]byte("body longer than limit"))
+		})
+
+		res := &results{counts: map[string]int{}}
+		c := New(next, settings, res.onResult)
+
+		requests := []*http.Request{
+			httptest.NewRequest(http.MethodGet, "http://test.url/path", nil),
+			httptest.NewRequest(http.MethodGet, "http://test.url/path", nil),
+		}
+
+		recorders := serveConcurrently(c, requests, release)
+
+		assert.Equal(t, int32(2), calls.Load())
+		assert.Equal(t, map[string]int{ResultLeader: 1, ResultFallback: 1}, res.counts)
+		for _, rec := range recorders {
+			assert.Equal(t, "body longer than limit", rec.Body.String())
+		}
+	})
+
+	t.Run("non idempotent requests are not coalesced", func(t *testing.T) {
+		t.Parallel()
+
+		var calls atomic.Int32
+		release := make(chan struct{})
+		next := http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+			calls.Add(1)
+			<-release
+		})
+
+		c := New(next, settings, nil)
+
+		requests := []*http.Request{
+			httptest.NewRequest(http.MethodPost, "http://test.url/path", nil),
+			httptest.NewRequest(http.MethodPost, "http://test.url/path", nil),
+		}
+
+		serveConcurrently(c, requests, release)
+		assert.Equal(t, int32(2), calls.Load())
+	})
+	t.Run("requests bypassed by settings are not coalesced", func(t *testing.T) {
+		t.Parallel()
+
+		var calls atomic.Int32
+		release := make(chan struct{})
+		next := http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+			calls.Add(1)
+			<-release
+		})
+
+		var results []string
+		var mu sync.Mutex
+		c := New(next, Settings{
+			KeyHeaders:   settings.KeyHeaders,
+			MaxBodyBytes: settings.MaxBodyBytes,
+			Bypass: func(r *http.Request) bool {
+				return r.Header.Get("X-Pinned") != ""
+			},
+		}, func(result string) {
+			mu.Lock()
+			defer mu.Unlock()
+			results = append(results, result)
+		})
+
+		requests := []*http.Request{
+			httptest.NewRequest(http.MethodGet, "http://test.url/path", nil),
+			httptest.NewRequest(http.MethodGet, "http://test.url/path", nil),
+		}
+		for _, req := range requests {
+			req.Header.Set("X-Pinned", "A")
+		}
+
+		serveConcurrently(c, requests, release)
+		assert.Equal(t, int32(2), calls.Load())
+		assert.Equal(t, []string{ResultBypass, ResultBypass}, results)
+	})
+}
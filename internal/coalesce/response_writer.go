@@ -0,0 +1,54 @@
+package coalesce
+
+import (
+	"bytes"
+	"net/http"
+)
+
+// recordingWriter passes response to the client and keeps its copy for waiting requests.
+type recordingWriter struct {
+	http.ResponseWriter
+	maxBody int64
+
+	wroteHeader bool
+	statusCode  int
+	header      http.Header
+	body        bytes.Buffer
+	tooLarge    bool
+}
+
+func (rec *recordingWriter) WriteHeader(statusCode int) {
+	if rec.wroteHeader {
+		return
+	}
+
+	if statusCode >= http.StatusOK {
+		rec.wroteHeader = true
+		rec.statusCode = statusCode
+		rec.header = rec.Header().Clone()
+	}
+
+	rec.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (rec *recordingWriter) Write(b []byte) (int, error) {
+	if !rec.wroteHeader {
+		rec.WriteHeader(http.StatusOK)
+	}
+
+	if !rec.tooLarge {
+		if int64(rec.body.Len()+len(b)) > rec.maxBody {
+			rec.tooLarge = true
+			rec.body = bytes.Buffer{}
+		} else {
+			rec.body.Write(b)
+		}
+	}
+
+	return rec.ResponseWriter.Write(b)
+}
+
+// Unwrap is used by http.ResponseController to reach the underlying ResponseWriter.
+func (rec *recordingWriter) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
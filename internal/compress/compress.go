@@ -0,0 +1,65 @@
+// compress compresses responses according to Accept-Encoding header of the request.
+package compress
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Settings of compression.
+type Settings struct {
+	// Encodings in order of preference. Supported are gzip, br and zstd.
+	Encodings []string
+	// MinSize is the minimum size of response body to compress.
+	MinSize int
+	// ContentTypes of compressed responses. Value ending with / matches all subtypes, like text/.
+	ContentTypes []string
+}
+
+// Compressor compresses responses of the next handler.
+type Compressor struct {
+	next     http.Handler
+	settings Settings
+}
+
+// New creates Compressor in front of next handler.
+func New(next http.Handler, settings Settings) *Compressor {
+	return &Compressor{
+		next:     next,
+		settings: settings,
+	}
+}
+
+// ServeHTTP sends request to the next handler and compresses its response if client accepts it.
+func (c *Compressor) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	encoding := negotiate(r.Header.Get("Accept-Encoding"), c.settings.Encodings)
+	if encoding == "" || r.Method == http.MethodHead {
+		c.next.ServeHTTP(w, r)
+		return
+	}
+
+	cw := &compressWriter{
+		ResponseWriter: w,
+		settings:       &c.settings,
+		encoding:       encoding,
+	}
+	defer cw.close()
+
+	c.next.ServeHTTP(cw, r)
+}
+
+func (s *Settings) allowsContentType(contentType string) bool {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+	if mediaType == "" {
+		return false
+	}
+
+	for _, allowed := range s.ContentTypes {
+		if mediaType == allowed || (strings.HasSuffix(allowed, "/") && strings.HasPrefix(mediaType, allowed)) {
+			return true
+		}
+	}
+
+	return false
+}
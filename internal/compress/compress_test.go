@@ -0,0 +1,210 @@
+package compress
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+)
+
+var testSettings = Settings{
+	Encodings:    []string{EncodingZstd, EncodingBrotli, EncodingGzip},
+	MinSize:      100,
+	ContentTypes: []string{"application/json", "text/"},
+}
+
+func decode(t *testing.T, encoding string, body []byte) string {
+	t.Helper()
+
+	var (
+		reader io.Reader
+		err    error
+	)
+
+	switch encoding {
+	case EncodingGzip:
+		reader, err = gzip.NewReader(bytes.NewReader(body))
+	case EncodingBrotli:
+		reader = brotli.NewReader(bytes.NewReader(body))
+	case EncodingZstd:
+		var decoder *zstd.Decoder
+		decoder, err = zstd.NewReader(bytes.NewReader(body))
+		reader = decoder
+	default:
+		return string(body)
+	}
+	assert.Nil(t, err)
+
+	decoded, err := io.ReadAll(reader)
+	assert.Nil(t, err)
+
+	return string(decoded)
+}
+
+func TestNegotiate(t *testing.T) {
+	t.Parallel()
+
+	preferred := []string{EncodingZstd, EncodingBrotli, EncodingGzip}
+
+	tests := []struct {
+		acceptEncoding string
+		expected       string
+	}{
+		{acceptEncoding: "", expected: ""},
+		{acceptEncoding: "gzip", expected: EncodingGzip},
+		{acceptEncoding: "gzip, deflate, br", expected: EncodingBrotli},
+		{acceptEncoding: "gzip, br, zstd", expected: EncodingZstd},
+		{acceptEncoding: "gzip;q=1.0, br;q=0.5", expected: EncodingGzip},
+		{acceptEncoding: "zstd;q=0, gzip", expected: EncodingGzip},
+		{acceptEncoding: "*", expected: EncodingZstd},
+		{acceptEncoding: "identity", expected: ""},
+		{acceptEncoding: "deflate", expected: ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.acceptEncoding, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, test.expected, negotiate(test.acceptEncoding, preferred))
+		})
+	}
+}
+
+func TestCompressor(t *testing.T) {
+	t.Parallel()
+
+	largeBody := strings.Repeat(`{"key": "value"}`, 100)
+
+	tests := []struct {
+		name             string
+		acceptEncoding   string
+		contentType      string
+		contentEncoding  string
+		body             string
+		expectedEncoding string
+	}{
+		{name: "gzip", acceptEncoding: "gzip", contentType: "application/json", body: largeBody, expectedEncoding: EncodingGzip},
+		{name: "brotli", acceptEncoding: "br", contentType: "application/json", body: largeBody, expectedEncoding: EncodingBrotli},
+		{name: "zstd", acceptEncoding: "zstd", contentType: "text/plain; charset=utf-8", body: largeBody, expectedEncoding: EncodingZstd},
+		{name: "small body", acceptEncoding: "gzip", contentType: "application/json", body: "{}"},
+		{name: "not allowed content type", acceptEncoding: "gzip", contentType: "image/png", body: largeBody},
+		{name: "not accepted", acceptEncoding: "", contentType: "application/json", body: largeBody},
+		{
+			name:             "already encoded",
+			acceptEncoding:   "gzip",
+			contentType:      "application/json",
+			contentEncoding:  "br",
+			body:             largeBody,
+			expectedEncoding: "br",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			c := New(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.Header().Set("Content-Type", test.contentType)
+				if test.contentEncoding != "" {
+					w.Header().Set("Content-Encoding", test.contentEncoding)
+				}
+
+				// Body is written in parts to check buffering.
+				half := len(test.body) / 2
+				_, _ = w.Write([]byte(test.body[:half]))
+				_, _ = w.Write([]byte(test.body[half:]))
+			}), testSettings)
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("Accept-Encoding", test.acceptEncoding)
+
+			rec := httptest.NewRecorder()
+			c.ServeHTTP(rec, req)
+
+			assert.Equal(t, test.expectedEncoding, rec.Header().Get("Content-Encoding"))
+			if test.contentEncoding == "" {
+				assert.Equal(t, test.body, decode(t, test.expectedEncoding, rec.Body.Bytes()))
+			} else {
+				assert.Equal(t, test.body, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestCompressor_ContentLength(t *testing.T) {
+	t.Parallel()
+
+	body := strings.Repeat("a", 200)
+	c := New(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Header().Set("Content-Length", "200")
+		w.Header().Set("ETag", `"v1"`)
+		_, _ = w.Write([]byte(body))
+	}), testSettings)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+
+	rec := httptest.NewRecorder()
+	c.ServeHTTP(rec, req)
+
+	assert.Equal(t, EncodingGzip, rec.Header().Get("Content-Encoding"))
+	assert.Empty(t, rec.Header().Get("Content-Length"))
+	assert.Equal(t, `W/"v1"`, rec.Header().Get("ETag"))
+	assert.Equal(t, "Accept-Encoding", rec.Header().Get("Vary"))
+	assert.Equal(t, body, decode(t, EncodingGzip, rec.Body.Bytes()))
+}
+
+func TestCompressor_Flush(t *testing.T) {
+	t.Parallel()
+
+	flushed := make(chan struct{})
+	proceed := make(chan struct{})
+
+	c := New(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = w.Write([]byte("data: first\n\n"))
+		assert.Nil(t, http.NewResponseController(w).Flush())
+
+		close(flushed)
+		<-proceed
+
+		_, _ = w.Write([]byte("data: second\n\n"))
+	}), testSettings)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+
+	rec := httptest.NewRecorder()
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		c.ServeHTTP(rec, req)
+	}()
+
+	<-flushed
+	assert.True(t, rec.Flushed)
+	assert.Equal(t, EncodingGzip, rec.Header().Get("Content-Encoding"))
+
+	// Data written before flush can be decoded without the rest of the stream.
+	reader, err := gzip.NewReader(bytes.NewReader(rec.Body.Bytes()))
+	assert.Nil(t, err)
+
+	first := make([]byte, len("data: first\n\n"))
+	_, err = io.ReadFull(reader, first)
+	assert.Nil(t, err)
+	assert.Equal(t, "data: first\n\n", string(first))
+
+	close(proceed)
+	<-done
+
+	assert.Equal(t, "data: first\n\ndata: second\n\n", decode(t, EncodingGzip, rec.Body.Bytes()))
+}
@@ -0,0 +1,176 @@
+package compress
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// compressWriter buffers the beginning of response body until it is known whether to compress it.
+// Response is compressed if it has allowed content type, is not encoded yet and is not smaller than MinSize.
+type compressWriter struct {
+	http.ResponseWriter
+	settings *Settings
+	encoding string
+
+	statusCode  int
+	wroteHeader bool
+	decided     bool
+	buf         []byte
+	encoder     encoder
+}
+
+func (cw *compressWriter) WriteHeader(statusCode int) {
+	if cw.wroteHeader {
+		return
+	}
+
+	if statusCode < http.StatusOK {
+		cw.ResponseWriter.WriteHeader(statusCode)
+		return
+	}
+
+	cw.wroteHeader = true
+	cw.statusCode = statusCode
+
+	if !cw.eligible() {
+		cw.decide(false)
+		return
+	}
+
+	cw.Header().Add("Vary", "Accept-Encoding")
+
+	if contentLength, err := strconv.Atoi(cw.Header().Get("Content-Length")); err == nil {
+		cw.decide(contentLength >= cw.settings.MinSize)
+	}
+}
+
+func (cw *compressWriter) Write(b []byte) (int, error) {
+	if !cw.wroteHeader {
+		cw.WriteHeader(http.StatusOK)
+	}
+
+	if cw.decided {
+		if cw.encoder != nil {
+			return cw.encoder.Write(b)
+		}
+
+		return cw.ResponseWriter.Write(b)
+	}
+
+	cw.buf = append(cw.buf, b...)
+	if len(cw.buf) >= cw.settings.MinSize {
+		cw.decide(true)
+		if err := cw.writeBuffered(); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(b), nil
+}
+
+// FlushError flushes compressed data to the client, so streaming responses are not delayed.
+// Streaming response is compressed even if it is smaller than MinSize, because its size is unknown.
+func (cw *compressWriter) FlushError() error {
+	if !cw.wroteHeader {
+		cw.WriteHeader(http.StatusOK)
+	}
+
+	if !cw.decided {
+		cw.decide(true)
+		if err := cw.writeBuffered(); err != nil {
+			return err
+		}
+	}
+
+	if cw.encoder != nil {
+		if err := cw.encoder.Flush(); err != nil {
+			return err
+		}
+	}
+
+	return http.NewResponseController(cw.ResponseWriter).Flush()
+}
+
+// Unwrap is used by http.ResponseController to reach the underlying ResponseWriter.
+func (cw *compressWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+// eligible reports if response may be compressed regardless of its size.
+func (cw *compressWriter) eligible() bool {
+	if cw.statusCode == http.StatusNoContent || cw.statusCode == http.StatusNotModified ||
+		cw.statusCode == http.StatusPartialContent {
+		return false
+	}
+
+	header := cw.Header()
+	if encoding := header.Get("Content-Encoding"); encoding != "" && encoding != "identity" {
+		return false
+	}
+
+	if strings.Contains(header.Get("Cache-Control"), "no-transform") {
+		return false
+	}
+
+	return cw.settings.allowsContentType(header.Get("Content-Type"))
+}
+
+// decide writes response header with or without compression.
+func (cw *compressWriter) decide(compress bool) {
+	cw.decided = true
+
+	if compress {
+		header := cw.Header()
+		header.Del("Content-Length")
+		header.Del("Accept-Ranges")
+		header.Set("Content-Encoding", cw.encoding)
+
+		// Compressed body differs from the original one, so strong validator becomes weak.
+		if etag := header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+			header.Set("ETag", "W/"+etag)
+		}
+
+		cw.encoder = getEncoder(cw.encoding, cw.ResponseWriter)
+	}
+
+	cw.ResponseWriter.WriteHeader(cw.statusCode)
+}
+
+func (cw *compressWriter) writeBuffered() error {
+	if len(cw.buf) == 0 {
+		return nil
+	}
+
+	buf := cw.buf
+	cw.buf = nil
+
+	var err error
+	if cw.encoder != nil {
+		_, err = cw.encoder.Write(buf)
+	} else {
+		_, err = cw.ResponseWriter.Write(buf)
+	}
+
+	return err
+}
+
+// close writes the rest of response. Response smaller than MinSize is not compressed.
+func (cw *compressWriter) close() {
+	if !cw.wroteHeader {
+		// Nothing was written, so server sends empty 200 response itself.
+		return
+	}
+
+	if !cw.decided {
+		cw.decide(len(cw.buf) >= cw.settings.MinSize)
+	}
+
+	_ = cw.writeBuffered()
+
+	if cw.encoder != nil {
+		_ = cw.encoder.Close()
+		putEncoder(cw.encoding, cw.encoder)
+		cw.encoder = nil
+	}
+}
@@ -0,0 +1,178 @@
+package concurrency
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ReasonLimitExceeded is the reason of rejection by Adaptive.
+const ReasonLimitExceeded = "limit_exceeded"
+
+// ErrLimitExceeded is returned if backend has no free slot under adaptive limit.
+var ErrLimitExceeded = errors.New("concurrency limit of the backend is exceeded")
+
+// AdaptiveSettings of Adaptive.
+type AdaptiveSettings struct {
+	// InitialLimit of each backend.
+	InitialLimit int
+	// MinLimit is the lowest limit.
+	MinLimit int
+	// MaxLimit is the highest limit.
+	MaxLimit int
+	// NewAlgorithm creates algorithm for each backend.
+	NewAlgorithm func() Algorithm
+}
+
+// adaptiveState holds slots and limit of the backend.
+type adaptiveState struct {
+	inFlight  int
+	limit     float64
+	algorithm Algorithm
+}
+
+// Limit of the backend.
+type Limit struct {
+	// Limit is the current limit of concurrent requests.
+	Limit int `json:"limit"`
+	// InFlight is the amount of concurrent requests.
+	InFlight int `json:"in_flight"`
+}
+
+// Adaptive limits amount of concurrent requests to each backend. Limit is adjusted by algorithm from latency
+// and errors of responses, so Adaptive must be notified about them as balancer.ResponseObserver.
+// Requests over the limit are rejected without waiting.
+type Adaptive struct {
+	settings AdaptiveSettings
+	lock     sync.Mutex
+	backends map[string]*adaptiveState
+	onLimit  func(backend string, limit int)
+	onReject func(backend, reason string)
+}
+
+// NewAdaptive creates Adaptive. onLimit is called with initial limit of the backend and with new limit when it changes,
+// onReject is called with reason of rejection. Callbacks may be nil.
+func NewAdaptive(settings AdaptiveSettings, onLimit func(backend string, limit int), onReject func(backend, reason string)) *Adaptive {
+	return &Adaptive{
+		settings: settings,
+		backends: make(map[string]*adaptiveState),
+		onLimit:  onLimit,
+		onReject: onReject,
+	}
+}
+
+// TryAcquire takes free slot of the backend. It returns false if there is no free slot.
+func (a *Adaptive) TryAcquire(backend string) (func(), bool) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	state := a.state(backend)
+	if state.inFlight >= int(state.limit) {
+		return nil, false
+	}
+
+	state.inFlight++
+
+	var once sync.Once
+
+	return func() {
+		once.Do(func() {
+			a.lock.Lock()
+			defer a.lock.Unlock()
+
+			state.inFlight--
+		})
+	}, true
+}
+
+// Acquire takes free slot of the backend or returns ErrLimitExceeded.
+func (a *Adaptive) Acquire(_ context.Context, backend string) (func(), error) {
+	if release, ok := a.TryAcquire(backend); ok {
+		return release, nil
+	}
+
+	if a.onReject != nil {
+		a.onReject(backend, ReasonLimitExceeded)
+	}
+
+	return nil, ErrLimitExceeded
+}
+
+// ObserveRequest does nothing, as slots are counted by Adaptive itself.
+func (a *Adaptive) ObserveRequest(string) {}
+
+// ObserveResponse updates limit of the backend. Responses with 5xx status code are failures.
+func (a *Adaptive) ObserveResponse(backend string, statusCode int, latency time.Duration) {
+	a.lock.Lock()
+
+	state, ok := a.backends[backend]
+	if !ok {
+		a.lock.Unlock()
+		return
+	}
+
+	previous := int(state.limit)
+	state.limit = state.algorithm.Update(state.limit, Sample{
+		Latency:  latency,
+		Failed:   statusCode >= http.StatusInternalServerError,
+		InFlight: state.inFlight,
+	})
+	state.limit = math.Max(float64(a.settings.MinLimit), math.Min(float64(a.settings.MaxLimit), state.limit))
+	current := int(state.limit)
+
+	a.lock.Unlock()
+
+	if current != previous && a.onLimit != nil {
+		a.onLimit(backend, current)
+	}
+}
+
+// Limits returns current limits of backends.
+func (a *Adaptive) Limits() map[string]Limit {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	limits := make(map[string]Limit, len(a.backends))
+	for backend, state := range a.backends {
+		limits[backend] = Limit{Limit: int(state.limit), InFlight: state.inFlight}
+	}
+
+	return limits
+}
+
+// AddBackend starts tracking limit of the backend.
+func (a *Adaptive) AddBackend(backend string) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	a.state(backend)
+}
+
+// RemoveBackend stops tracking limit of the backend.
+func (a *Adaptive) RemoveBackend(backend string) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	delete(a.backends, backend)
+}
+
+// state returns state of the backend creating it with initial limit if needed. Must be called under lock.
+func (a *Adaptive) state(backend string) *adaptiveState {
+	state, ok := a.backends[backend]
+	if !ok {
+		state = &adaptiveState{
+			limit:     float64(a.settings.InitialLimit),
+			algorithm: a.settings.NewAlgorithm(),
+		}
+		a.backends[backend] = state
+
+		if a.onLimit != nil {
+			a.onLimit(backend, a.settings.InitialLimit)
+		}
+	}
+
+	return state
+}
@@ -0,0 +1,132 @@
+package concurrency
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAIMD(t *testing.T) {
+	t.Parallel()
+
+	aimd := &AIMD{BackoffRatio: 0.5, LatencyThreshold: time.Second}
+
+	t.Run("increases limit when utilized", func(t *testing.T) {
+		t.Parallel()
+
+		assert.InDelta(t, 10.1, aimd.Update(10, Sample{Latency: time.Millisecond, InFlight: 5}), 1e-9)
+	})
+
+	t.Run("keeps limit when not utilized", func(t *testing.T) {
+		t.Parallel()
+
+		assert.InDelta(t, 10.0, aimd.Update(10, Sample{Latency: time.Millisecond, InFlight: 4}), 1e-9)
+	})
+
+	t.Run("decreases limit on failure", func(t *testing.T) {
+		t.Parallel()
+
+		assert.InDelta(t, 5.0, aimd.Update(10, Sample{Latency: time.Millisecond, Failed: true, InFlight: 10}), 1e-9)
+	})
+
+	t.Run("decreases limit on slow response", func(t *testing.T) {
+		t.Parallel()
+
+		assert.InDelta(t, 5.0, aimd.Update(10, Sample{Latency: 2 * time.Second, InFlight: 10}), 1e-9)
+	})
+}
+
+func TestGradient(t *testing.T) {
+	t.Parallel()
+
+	newGradient := func() *Gradient {
+		g := &Gradient{Smoothing: 1, Tolerance: 1, Window: 10}
+		g.Update(16, Sample{Latency: 100 * time.Millisecond, InFlight: 16})
+		return g
+	}
+
+	t.Run("increases limit when latency is stable", func(t *testing.T) {
+		t.Parallel()
+
+		assert.InDelta(t, 20.0, newGradient().Update(16, Sample{Latency: 100 * time.Millisecond, InFlight: 16}), 1e-9)
+	})
+
+	t.Run("keeps limit when not utilized", func(t *testing.T) {
+		t.Parallel()
+
+		assert.InDelta(t, 16.0, newGradient().Update(16, Sample{Latency: 100 * time.Millisecond, InFlight: 1}), 1e-9)
+	})
+
+	t.Run("decreases limit when latency grows", func(t *testing.T) {
+		t.Parallel()
+
+		// Long-term latency becomes 190ms, so gradient is 0.5.
+		assert.InDelta(t, 12.0, newGradient().Update(16, Sample{Latency: time.Second, InFlight: 16}), 1e-9)
+	})
+
+	t.Run("decreases limit on failure", func(t *testing.T) {
+		t.Parallel()
+
+		assert.InDelta(t, 12.0, newGradient().Update(16, Sample{Latency: 100 * time.Millisecond, Failed: true, InFlight: 16}), 1e-9)
+	})
+}
+
+func TestAdaptive(t *testing.T) {
+	t.Parallel()
+
+	var (
+		limits  []int
+		reasons []string
+	)
+
+	a := NewAdaptive(
+		AdaptiveSettings{
+			InitialLimit: 2,
+			MinLimit:     1,
+			MaxLimit:     3,
+			NewAlgorithm: func() Algorithm { return &AIMD{BackoffRatio: 0.5} },
+		},
+		func(_ string, limit int) {
+			limits = append(limits, limit)
+		},
+		func(_, reason string) {
+			reasons = append(reasons, reason)
+		},
+	)
+
+	releaseFirst, err := a.Acquire(context.Background(), "A")
+	assert.Nil(t, err)
+
+	releaseSecond, ok := a.TryAcquire("A")
+	assert.True(t, ok)
+
+	_, err = a.Acquire(context.Background(), "A")
+	assert.ErrorIs(t, err, ErrLimitExceeded)
+	assert.Equal(t, []string{ReasonLimitExceeded}, reasons)
+	assert.Equal(t, map[string]Limit{"A": {Limit: 2, InFlight: 2}}, a.Limits())
+
+	// Limit grows while backend is utilized, but not above maximum.
+	for range 20 {
+		a.ObserveResponse("A", http.StatusOK, time.Millisecond)
+	}
+	assert.Equal(t, map[string]Limit{"A": {Limit: 3, InFlight: 2}}, a.Limits())
+
+	releaseFirst()
+	releaseSecond()
+
+	// Limit drops on failures, but not below minimum.
+	for range 5 {
+		a.ObserveResponse("A", http.StatusBadGateway, time.Millisecond)
+	}
+	assert.Equal(t, map[string]Limit{"A": {Limit: 1, InFlight: 0}}, a.Limits())
+	assert.Equal(t, []int{2, 3, 1}, limits)
+
+	_, ok = a.TryAcquire("A")
+	assert.True(t, ok)
+
+	_, ok = a.TryAcquire("A")
+	assert.False(t, ok)
+}
@@ -0,0 +1,87 @@
+package concurrency
+
+import (
+	"math"
+	"time"
+)
+
+// Sample is the response observed for the backend.
+type Sample struct {
+	// Latency of the response.
+	Latency time.Duration
+	// Failed reports if backend failed to handle request.
+	Failed bool
+	// InFlight is the amount of requests to the backend when response was received, including this one.
+	InFlight int
+}
+
+// Algorithm computes new limit of the backend from observed response. Algorithm is used for single backend
+// and is never called concurrently.
+type Algorithm interface {
+	// Update returns new limit.
+	Update(limit float64, sample Sample) float64
+}
+
+// AIMD increases limit by one per limit of successful responses when backend is utilized,
+// and multiplies limit by BackoffRatio when it fails or is too slow.
+type AIMD struct {
+	// BackoffRatio in range (0, 1) is applied to the limit on failure.
+	BackoffRatio float64
+	// LatencyThreshold is the latency treated as failure. Zero disables it.
+	LatencyThreshold time.Duration
+}
+
+// Update returns new limit.
+func (a *AIMD) Update(limit float64, sample Sample) float64 {
+	if sample.Failed || (a.LatencyThreshold > 0 && sample.Latency > a.LatencyThreshold) {
+		return limit * a.BackoffRatio
+	}
+
+	// Limit is not increased if backend does not receive enough requests to show that it can handle more.
+	if float64(sample.InFlight)*2 < limit {
+		return limit
+	}
+
+	return limit + 1/limit
+}
+
+// Gradient is the algorithm similar to gradient2 from Netflix concurrency-limits. It compares latency of
+// the response with long-term average latency. When latency grows, limit decreases in proportion,
+// otherwise limit grows by the square root of itself to probe for more capacity.
+type Gradient struct {
+	// Smoothing in range (0, 1] is the weight of new limit.
+	Smoothing float64
+	// Tolerance is the ratio of latency to long-term latency which does not decrease the limit.
+	Tolerance float64
+	// Window is the amount of responses averaged in long-term latency.
+	Window int
+
+	longLatency float64
+}
+
+// minGradient limits decrease of the limit on single response.
+const minGradient = 0.5
+
+// Update returns new limit.
+func (g *Gradient) Update(limit float64, sample Sample) float64 {
+	latency := float64(sample.Latency)
+	if g.longLatency == 0 {
+		g.longLatency = latency
+	} else {
+		g.longLatency += (latency - g.longLatency) / float64(g.Window)
+	}
+
+	gradient := minGradient
+	if !sample.Failed && latency > 0 {
+		gradient = math.Max(minGradient, math.Min(1, g.Tolerance*g.longLatency/latency))
+	}
+
+	// Limit is not increased if backend does not receive enough requests to show that it can handle more.
+	if gradient == 1 && float64(sample.InFlight)*2 < limit {
+		return limit
+	}
+
+	newLimit := limit*gradient + math.Sqrt(limit)
+
+	return limit*(1-g.Smoothing) + newLimit*g.Smoothing
+}
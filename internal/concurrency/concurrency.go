@@ -0,0 +1,248 @@
+// concurrency limits amount of concurrent requests to each backend.
+package concurrency
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// Reasons of rejection passed to callback.
+const (
+	ReasonQueueFull    = "queue_full"
+	ReasonQueueTimeout = "queue_timeout"
+)
+
+var (
+	// ErrQueueFull is returned if queue of the backend has no room for request.
+	ErrQueueFull = errors.New("queue of the backend is full")
+	// ErrQueueTimeout is returned if request waited in queue for too long.
+	ErrQueueTimeout = errors.New("timeout of waiting in queue of the backend")
+)
+
+// Settings of Limiter.
+type Settings struct {
+	// MaxConcurrency is the maximum amount of concurrent requests to each backend.
+	MaxConcurrency int
+	// QueueSize is the maximum amount of requests waiting for each backend. Zero disables queue.
+	QueueSize int
+	// QueueTimeout is the maximum time of waiting in queue.
+	QueueTimeout time.Duration
+}
+
+// waiter is the request waiting in queue.
+type waiter struct {
+	ready chan struct{}
+	// granted is set under lock when slot is passed to the waiter.
+	granted bool
+}
+
+// backendState holds slots of the backend.
+type backendState struct {
+	inFlight int
+	queue    list.List
+}
+
+// Limiter gives each backend fixed amount of slots for concurrent requests.
+// Requests waiting for slot are served in order of arrival.
+type Limiter struct {
+	settings Settings
+	lock     sync.Mutex
+	backends map[string]*backendState
+	// queued is the amount of requests waiting for all backends.
+	queued   int
+	onQueue  func(backend string, depth int)
+	onWait   func(backend string, wait time.Duration)
+	onReject func(backend, reason string)
+}
+
+// New creates Limiter. onQueue is called with depth of the queue when it changes,
+// onWait is called with time spent in queue, onReject is called with reason of rejection. Callbacks may be nil.
+func New(
+	settings Settings,
+	onQueue func(backend string, depth int),
+	onWait func(backend string, wait time.Duration),
+	onReject func(backend, reason string),
+) *Limiter {
+	return &Limiter{
+		settings: settings,
+		backends: make(map[string]*backendState),
+		onQueue:  onQueue,
+		onWait:   onWait,
+		onReject: onReject,
+	}
+}
+
+// TryAcquire takes free slot of the backend. It returns false if there is no free slot.
+func (l *Limiter) TryAcquire(backend string) (func(), bool) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	state := l.state(backend)
+	if state.inFlight >= l.settings.MaxConcurrency {
+		return nil, false
+	}
+
+	state.inFlight++
+
+	return l.releaser(backend, state), true
+}
+
+// Acquire takes free slot of the backend or waits for it in queue.
+// Error is ErrQueueFull, ErrQueueTimeout or error of the context.
+func (l *Limiter) Acquire(ctx context.Context, backend string) (func(), error) {
+	l.lock.Lock()
+
+	state := l.state(backend)
+	if state.inFlight < l.settings.MaxConcurrency {
+		state.inFlight++
+		l.lock.Unlock()
+
+		return l.releaser(backend, state), nil
+	}
+
+	if state.queue.Len() >= l.settings.QueueSize {
+		l.lock.Unlock()
+		l.reject(backend, ReasonQueueFull)
+
+		return nil, ErrQueueFull
+	}
+
+	w := &waiter{ready: make(chan struct{})}
+	element := state.queue.PushBack(w)
+	depth := state.queue.Len()
+	l.queued++
+	l.lock.Unlock()
+
+	l.reportQueue(backend, depth)
+
+	start := time.Now()
+	timer := time.NewTimer(l.settings.QueueTimeout)
+	defer timer.Stop()
+
+	var err error
+	select {
+	case <-w.ready:
+	case <-timer.C:
+		err = ErrQueueTimeout
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	if l.onWait != nil {
+		l.onWait(backend, time.Since(start))
+	}
+
+	if err == nil {
+		return l.releaser(backend, state), nil
+	}
+
+	l.lock.Lock()
+	if w.granted {
+		// Slot was passed to the waiter right before timeout, so it is used.
+		l.lock.Unlock()
+		return l.releaser(backend, state), nil
+	}
+
+	state.queue.Remove(element)
+	depth = state.queue.Len()
+	l.queued--
+	l.lock.Unlock()
+
+	l.reportQueue(backend, depth)
+
+	if errors.Is(err, ErrQueueTimeout) {
+		l.reject(backend, ReasonQueueTimeout)
+	}
+
+	return nil, err
+}
+
+// Load returns amount of requests waiting in queue of the backend.
+func (l *Limiter) Load(backend string) int {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	state, ok := l.backends[backend]
+	if !ok {
+		return 0
+	}
+
+	return state.queue.Len()
+}
+
+// Queued returns amount of requests waiting for all backends.
+func (l *Limiter) Queued() int {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	return l.queued
+}
+
+// AddBackend starts tracking slots of the backend.
+func (l *Limiter) AddBackend(backend string) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.state(backend)
+}
+
+// RemoveBackend stops tracking slots of the backend. Requests already sent to it release their slots as usual.
+func (l *Limiter) RemoveBackend(backend string) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	delete(l.backends, backend)
+}
+
+// state returns state of the backend creating it if needed. Must be called under lock.
+func (l *Limiter) state(backend string) *backendState {
+	state, ok := l.backends[backend]
+	if !ok {
+		state = &backendState{}
+		l.backends[backend] = state
+	}
+
+	return state
+}
+
+// releaser returns function releasing the slot. The slot is passed to the first waiter in queue if any.
+func (l *Limiter) releaser(backend string, state *backendState) func() {
+	var once sync.Once
+
+	return func() {
+		once.Do(func() {
+			l.lock.Lock()
+
+			front := state.queue.Front()
+			if front == nil {
+				state.inFlight--
+				l.lock.Unlock()
+				return
+			}
+
+			w, _ := state.queue.Remove(front).(*waiter)
+			w.granted = true
+			l.queued--
+			close(w.ready)
+			depth := state.queue.Len()
+			l.lock.Unlock()
+
+			l.reportQueue(backend, depth)
+		})
+	}
+}
+
+func (l *Limiter) reportQueue(backend string, depth int) {
+	if l.onQueue != nil {
+		l.onQueue(backend, depth)
+	}
+}
+
+func (l *Limiter) reject(backend, reason string) {
+	if l.onReject != nil {
+		l.onReject(backend, reason)
+	}
+}
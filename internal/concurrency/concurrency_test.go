@@ -0,0 +1,177 @@
+package concurrency
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLimiter_TryAcquire(t *testing.T) {
+	t.Parallel()
+
+	l := New(Settings{MaxConcurrency: 2}, nil, nil, nil)
+
+	releaseFirst, ok := l.TryAcquire("A")
+	assert.True(t, ok)
+
+	_, ok = l.TryAcquire("A")
+	assert.True(t, ok)
+
+	_, ok = l.TryAcquire("A")
+	assert.False(t, ok)
+
+	_, ok = l.TryAcquire("B")
+	assert.True(t, ok)
+
+	releaseFirst()
+	// Release is idempotent.
+	releaseFirst()
+
+	_, ok = l.TryAcquire("A")
+	assert.True(t, ok)
+
+	_, ok = l.TryAcquire("A")
+	assert.False(t, ok)
+}
+
+func TestLimiter_Acquire(t *testing.T) {
+	t.Parallel()
+
+	t.Run("waits for released slot in order", func(t *testing.T) {
+		t.Parallel()
+
+		var (
+			lock   sync.Mutex
+			depths []int
+		)
+
+		l := New(Settings{MaxConcurrency: 1, QueueSize: 2, QueueTimeout: time.Minute}, func(_ string, depth int) {
+			lock.Lock()
+			defer lock.Unlock()
+
+			depths = append(depths, depth)
+		}, nil, nil)
+
+		release, err := l.Acquire(context.Background(), "A")
+		assert.Nil(t, err)
+
+		order := make(chan int, 2)
+		var wg sync.WaitGroup
+
+		for i := range 2 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+
+				waiterRelease, err := l.Acquire(context.Background(), "A")
+				assert.Nil(t, err)
+
+				order <- i
+				waiterRelease()
+			}()
+
+			// Waiters enter the queue one by one.
+			assert.Eventually(t, func() bool { return l.Load("A") == i+1 }, time.Second, time.Millisecond)
+		}
+
+		assert.Equal(t, 2, l.Queued())
+
+		release()
+		wg.Wait()
+		close(order)
+
+		assert.Equal(t, []int{0, 1}, []int{<-order, <-order})
+		assert.Equal(t, 0, l.Load("A"))
+		assert.Equal(t, 0, l.Queued())
+		assert.Equal(t, []int{1, 2, 1, 0}, depths)
+
+		_, ok := l.TryAcquire("A")
+		assert.True(t, ok)
+	})
+
+	t.Run("rejects when queue is full", func(t *testing.T) {
+		t.Parallel()
+
+		var reasons []string
+		l := New(Settings{MaxConcurrency: 1, QueueSize: 0, QueueTimeout: time.Minute}, nil, nil, func(_, reason string) {
+			reasons = append(reasons, reason)
+		})
+
+		_, err := l.Acquire(context.Background(), "A")
+		assert.Nil(t, err)
+
+		_, err = l.Acquire(context.Background(), "A")
+		assert.ErrorIs(t, err, ErrQueueFull)
+		assert.Equal(t, []string{ReasonQueueFull}, reasons)
+	})
+
+	t.Run("rejects after timeout", func(t *testing.T) {
+		t.Parallel()
+
+		var (
+			reasons []string
+			waits   []time.Duration
+		)
+
+		l := New(Settings{MaxConcurrency: 1, QueueSize: 1, QueueTimeout: 10 * time.Millisecond}, nil,
+			func(_ string, wait time.Duration) {
+				waits = append(waits, wait)
+			},
+			func(_, reason string) {
+				reasons = append(reasons, reason)
+			},
+		)
+
+		release, err := l.Acquire(context.Background(), "A")
+		assert.Nil(t, err)
+
+		_, err = l.Acquire(context.Background(), "A")
+		assert.ErrorIs(t, err, ErrQueueTimeout)
+		assert.Equal(t, []string{ReasonQueueTimeout}, reasons)
+		assert.Len(t, waits, 1)
+		assert.GreaterOrEqual(t, waits[0], 10*time.Millisecond)
+		assert.Equal(t, 0, l.Load("A"))
+		assert.Equal(t, 0, l.Queued())
+
+		// Slot is not leaked by timed out waiter.
+		release()
+
+		_, ok := l.TryAcquire("A")
+		assert.True(t, ok)
+	})
+
+	t.Run("stops waiting when context is done", func(t *testing.T) {
+		t.Parallel()
+
+		l := New(Settings{MaxConcurrency: 1, QueueSize: 1, QueueTimeout: time.Minute}, nil, nil, nil)
+
+		_, err := l.Acquire(context.Background(), "A")
+		assert.Nil(t, err)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err = l.Acquire(ctx, "A")
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Equal(t, 0, l.Load("A"))
+	})
+}
+
+func TestLimiter_RemoveBackend(t *testing.T) {
+	t.Parallel()
+
+	l := New(Settings{MaxConcurrency: 1}, nil, nil, nil)
+	l.AddBackend("A")
+
+	release, ok := l.TryAcquire("A")
+	assert.True(t, ok)
+
+	l.RemoveBackend("A")
+	release()
+
+	_, ok = l.TryAcquire("A")
+	assert.True(t, ok)
+}
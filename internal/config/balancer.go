@@ -1,16 +1,621 @@
 package config
 
+import (
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
 // Balancer represents config for the balancer.
+// Fields of the default pool are set at the top level. Its timeouts and affinity are defaults for other pools.
 type Balancer struct {
-	// Backends is a list of <host>:<port> strings.
-	Backends []string `yaml:"backends"`
+	// Pool is the default pool of backends.
+	Pool `yaml:",inline"`
+	// Pools are additional named pools of backends, for example, canary.
+	Pools Pools `yaml:"pools"`
+	// Routing config.
+	Routing Routing `yaml:"routing"`
 	// Port to listen.
 	Port uint32 `yaml:"port"`
+	// Admin server config.
+	Admin Admin `yaml:"admin"`
+	// ServerTimeouts for client connections.
+	ServerTimeouts ServerTimeouts `yaml:"server_timeouts"`
+	// ErrorPages config.
+	ErrorPages ErrorPages `yaml:"error_pages"`
+	// Compression config.
+	Compression Compression `yaml:"compression"`
+	// TrustedProxies is a list of CIDRs or addresses of proxies allowed to set X-Forwarded-For header.
+	TrustedProxies []string `yaml:"trusted_proxies"`
+	// Shedding config.
+	Shedding Shedding `yaml:"shedding"`
+	// RateLimitStore config shared by rate limits of all pools.
+	RateLimitStore RateLimitStore `yaml:"rate_limit_store"`
+}
+
+// RateLimitStore represents config for the store of token buckets.
+type RateLimitStore struct {
+	// Type of the store. Available are:
+	//	- local - buckets are kept in memory of each balancer;
+	//	- redis - buckets are kept in Redis and shared by balancers.
+	Type string `yaml:"type"`
+	// Redis config.
+	Redis RedisStore `yaml:"redis"`
+	// FallbackRetrySeconds is the time during which local buckets are used after failure of Redis.
+	FallbackRetrySeconds uint32 `yaml:"fallback_retry_seconds"`
+}
+
+// RedisStore represents config for connection to Redis or compatible server.
+type RedisStore struct {
+	// Address of the server in <host>:<port> format.
+	Address string `yaml:"address"`
+	// Username for authentication.
+	Username string `yaml:"username"`
+	// Password for authentication.
+	Password string `yaml:"password"`
+	// DB number.
+	DB int `yaml:"db"`
+	// KeyPrefix is added to keys of buckets.
+	KeyPrefix string `yaml:"key_prefix"`
+	// TimeoutMilliseconds for connection, reads and writes.
+	TimeoutMilliseconds uint32 `yaml:"timeout_milliseconds"`
+}
+
+// Shedding represents config for rejecting requests of lower priority first when balancer is overloaded.
+// Shedding is enabled if any of thresholds is set.
+type Shedding struct {
+	// MaxInFlight is the amount of requests in flight at which requests of the highest priority are shed.
+	MaxInFlight int `yaml:"max_in_flight"`
+	// MaxQueued is the amount of requests waiting in queues of concurrency limits of all pools
+	// at which requests of the highest priority are shed.
+	MaxQueued int `yaml:"max_queued"`
+	// LowestPriorityThreshold in range (0, 1] is the share of thresholds at which requests of the lowest priority are shed.
+	LowestPriorityThreshold float64 `yaml:"lowest_priority_threshold"`
+	// DefaultPriority of requests not matching any rule. 0 is the highest priority.
+	DefaultPriority int `yaml:"default_priority"`
+	// Rules are checked in order. The first rule matching request decides its priority.
+	Rules []SheddingRule `yaml:"rules"`
+}
+
+// SheddingRule represents config for the priority of matching requests.
+// Rule matches if all of its conditions succeed.
+type SheddingRule struct {
+	// Priority of matching requests. 0 is the highest priority.
+	Priority int `yaml:"priority"`
+	// PathPrefix of request URL path.
+	PathPrefix string `yaml:"path_prefix"`
+	// Header name.
+	Header string `yaml:"header"`
+	// Value of the header. If empty, any non-empty value matches.
+	Value string `yaml:"value"`
+	// CIDRs of client networks. Single IP address is allowed too.
+	CIDRs []string `yaml:"cidrs"`
+}
+
+// Compression represents config for compression of responses.
+type Compression struct {
+	// Enabled turns compression on.
+	Enabled bool `yaml:"enabled"`
+	// Encodings in order of preference. Available are:
+	//	- zstd;
+	//	- br;
+	//	- gzip.
+	Encodings []string `yaml:"encodings"`
+	// MinSizeBytes is the minimum size of response body to compress.
+	MinSizeBytes int `yaml:"min_size_bytes"`
+	// ContentTypes of compressed responses. Value ending with / matches all subtypes, like text/.
+	ContentTypes []string `yaml:"content_types"`
+}
+
+// DefaultPool is the name of the pool configured at the top level.
+const DefaultPool = "default"
+
+// Pool represents config for the pool of backends balanced with single strategy.
+type Pool struct {
+	// Backends is a list of backends. Each backend is either <host>:<port> string or mapping.
+	// If discovery is configured, these backends are used until the first discovery.
+	Backends []Backend `yaml:"backends"`
+	// PriorityGroups config.
+	PriorityGroups PriorityGroups `yaml:"priority_groups"`
+	// PanicThreshold in range [0, 1]. When the fraction of healthy backends drops below it,
+	// balancer ignores health and spreads requests across all backends. Zero disables panic mode.
+	PanicThreshold float64 `yaml:"panic_threshold"`
+	// SlowStart config.
+	SlowStart SlowStart `yaml:"slow_start"`
+	// Discovery config for dynamic set of backends.
+	Discovery Discovery `yaml:"discovery"`
 	// Strategy name to use. Available are:
-	//	- RoundRobin.
+	//	- RoundRobin;
+	//	- Random;
+	//	- P2C.
 	Strategy string `yaml:"strategy"`
 	// Healthcheck config.
 	Heathcheck Heathcheck `yaml:"healthcheck"`
+	// CircuitBreaker config.
+	CircuitBreaker CircuitBreaker `yaml:"circuit_breaker"`
+	// Mirror config.
+	Mirror Mirror `yaml:"mirror"`
+	// Cache config.
+	Cache Cache `yaml:"cache"`
+	// Coalesce config.
+	Coalesce Coalesce `yaml:"coalesce"`
+	// Limits config. Limits of the default pool are used for other pools if they do not set them.
+	Limits Limits `yaml:"limits"`
+	// Access config of the pool. It is checked after access config of routing.
+	Access Access `yaml:"access"`
+	// Auth config of the pool.
+	Auth Auth `yaml:"auth"`
+	// Concurrency config.
+	Concurrency Concurrency `yaml:"concurrency"`
+	// AdaptiveConcurrency config. It can not be used together with concurrency limit.
+	AdaptiveConcurrency AdaptiveConcurrency `yaml:"adaptive_concurrency"`
+	// RateLimit config.
+	RateLimit RateLimit `yaml:"rate_limit"`
+	// Timeouts for requests to backends of the pool.
+	Timeouts Timeouts `yaml:"timeouts"`
+	// Affinity config of the pool.
+	Affinity Affinity `yaml:"affinity"`
+}
+
+// RateLimit represents config for limiting rate of requests from each client with token bucket.
+// Client is identified by subject if it is authenticated, otherwise by IP address.
+// Rate limit is enabled if capacity is set.
+type RateLimit struct {
+	// Capacity of the bucket, so it is the allowed burst of requests.
+	Capacity int `yaml:"capacity"`
+	// RatePerSecond is the amount of requests allowed every second.
+	RatePerSecond float64 `yaml:"rate_per_second"`
+	// Clients with their own limits.
+	Clients []RateLimitClient `yaml:"clients"`
+}
+
+// RateLimitClient represents config for rate limit of particular client.
+type RateLimitClient struct {
+	// Client is the subject of authenticated client or IP address.
+	Client string `yaml:"client"`
+	// Capacity of the bucket.
+	Capacity int `yaml:"capacity"`
+	// RatePerSecond is the amount of requests allowed every second.
+	RatePerSecond float64 `yaml:"rate_per_second"`
+}
+
+// AdaptiveConcurrency represents config for limit of concurrent requests to each backend
+// adjusted from latency and errors of responses.
+type AdaptiveConcurrency struct {
+	// Algorithm name to use. Empty algorithm disables adaptive limit. Available are:
+	//	- aimd;
+	//	- gradient.
+	Algorithm string `yaml:"algorithm"`
+	// InitialLimit of each backend.
+	InitialLimit int `yaml:"initial_limit"`
+	// MinLimit is the lowest limit.
+	MinLimit int `yaml:"min_limit"`
+	// MaxLimit is the highest limit.
+	MaxLimit int `yaml:"max_limit"`
+	// AIMD config.
+	AIMD AIMD `yaml:"aimd"`
+	// Gradient config.
+	Gradient Gradient `yaml:"gradient"`
+}
+
+// AIMD represents config for additive increase and multiplicative decrease of the limit.
+type AIMD struct {
+	// BackoffRatio in range (0, 1) is applied to the limit on failure.
+	BackoffRatio float64 `yaml:"backoff_ratio"`
+	// LatencyThresholdMilliseconds is the latency treated as failure. Zero disables it.
+	LatencyThresholdMilliseconds uint32 `yaml:"latency_threshold_milliseconds"`
+}
+
+// Gradient represents config for the limit following ratio of long-term latency to latency of responses.
+type Gradient struct {
+	// Smoothing in range (0, 1] is the weight of new limit.
+	Smoothing float64 `yaml:"smoothing"`
+	// Tolerance is the ratio of latency to long-term latency which does not decrease the limit.
+	Tolerance float64 `yaml:"tolerance"`
+	// Window is the amount of responses averaged in long-term latency.
+	Window int `yaml:"window"`
+}
+
+// Concurrency represents config for limiting amount of concurrent requests to each backend.
+type Concurrency struct {
+	// MaxPerBackend is the maximum amount of concurrent requests to each backend. Zero disables the limit.
+	MaxPerBackend int `yaml:"max_per_backend"`
+	// QueueSize is the maximum amount of requests waiting for each backend. Zero disables queue.
+	QueueSize int `yaml:"queue_size"`
+	// QueueTimeoutMilliseconds is the maximum time of waiting in queue.
+	QueueTimeoutMilliseconds uint32 `yaml:"queue_timeout_milliseconds"`
+}
+
+// Auth represents config for authentication of requests. Each method is enabled if it is configured.
+// If no method is enabled, requests are not authenticated.
+type Auth struct {
+	// APIKeys config.
+	APIKeys APIKeysAuth `yaml:"api_keys"`
+	// Basic config.
+	Basic BasicAuth `yaml:"basic"`
+	// JWT config.
+	JWT JWTAuth `yaml:"jwt"`
+	// SubjectHeader is set to subject of authenticated client in requests to backends.
+	SubjectHeader string `yaml:"subject_header"`
+	// MethodHeader is set to method of authentication in requests to backends.
+	MethodHeader string `yaml:"method_header"`
+}
+
+// APIKeysAuth represents config for authentication with static API keys. Empty keys disable it.
+type APIKeysAuth struct {
+	// Header with API key. Empty header disables it.
+	Header string `yaml:"header"`
+	// Query parameter with API key. Empty query disables it.
+	Query string `yaml:"query"`
+	// Keys of clients.
+	Keys []APIKey `yaml:"keys"`
+}
+
+// APIKey represents API key of the client.
+type APIKey struct {
+	// Key sent by the client.
+	Key string `yaml:"key"`
+	// Subject is the name of the client.
+	Subject string `yaml:"subject"`
+}
+
+// BasicAuth represents config for Basic authentication. Empty htpasswd file disables it.
+type BasicAuth struct {
+	// HtpasswdFile with users and passwords hashed with bcrypt or SHA-1.
+	HtpasswdFile string `yaml:"htpasswd_file"`
+	// Realm sent to clients in WWW-Authenticate header.
+	Realm string `yaml:"realm"`
+}
+
+// JWTAuth represents config for authentication with bearer JSON Web Tokens. Empty JWKS file disables it.
+type JWTAuth struct {
+	// JWKSFile with public keys for signatures of tokens.
+	JWKSFile string `yaml:"jwks_file"`
+	// Issuer must be equal to iss claim. Empty issuer is not checked.
+	Issuer string `yaml:"issuer"`
+	// Audience must be one of aud claim values. Empty audience is not checked.
+	Audience string `yaml:"audience"`
+	// LeewaySeconds for checks of exp, nbf and iat claims.
+	LeewaySeconds uint32 `yaml:"leeway_seconds"`
+	// SubjectClaim is the claim used as subject of the client.
+	SubjectClaim string `yaml:"subject_claim"`
+}
+
+// Access represents config for allowing or denying requests by IP address of the client.
+type Access struct {
+	// Rules are checked in order. The first rule matching client decides.
+	Rules []AccessRule `yaml:"rules"`
+	// Default action if no rule matches. Available are:
+	//	- allow;
+	//	- deny.
+	Default string `yaml:"default"`
+}
+
+// AccessRule represents config for the action applied to clients from networks.
+type AccessRule struct {
+	// Action is either allow or deny.
+	Action string `yaml:"action"`
+	// CIDRs of client networks. Single IP address is allowed too.
+	CIDRs []string `yaml:"cidrs"`
+}
+
+// Limits represents config for limits of request size. Zero value disables the limit
+// or, for additional pools, means the limit of the default pool.
+type Limits struct {
+	// MaxBodyBytes is the maximum size of request body.
+	MaxBodyBytes int64 `yaml:"max_body_bytes"`
+	// MaxHeaderBytes is the maximum total size of request header lines.
+	MaxHeaderBytes int `yaml:"max_header_bytes"`
+	// MaxURLLength is the maximum length of request URI.
+	MaxURLLength int `yaml:"max_url_length"`
+}
+
+// WithDefaults returns limits with zero values replaced by defaults.
+func (conf Limits) WithDefaults(defaults Limits) Limits {
+	if conf.MaxBodyBytes == 0 {
+		conf.MaxBodyBytes = defaults.MaxBodyBytes
+	}
+
+	if conf.MaxHeaderBytes == 0 {
+		conf.MaxHeaderBytes = defaults.MaxHeaderBytes
+	}
+
+	if conf.MaxURLLength == 0 {
+		conf.MaxURLLength = defaults.MaxURLLength
+	}
+
+	return conf
+}
+
+// Coalesce represents config for sharing single upstream call between identical in-flight GET and HEAD requests.
+type Coalesce struct {
+	// Enabled turns coalescing on.
+	Enabled bool `yaml:"enabled"`
+	// KeyHeaders are request headers which must be equal for requests to be identical, besides method and URL.
+	KeyHeaders []string `yaml:"key_headers"`
+	// MaxBodyBytes is the maximum size of response body shared with waiting requests.
+	MaxBodyBytes int64 `yaml:"max_body_bytes"`
+}
+
+// Cache represents config for in-memory cache of responses to GET and HEAD requests.
+type Cache struct {
+	// Enabled turns cache on.
+	Enabled bool `yaml:"enabled"`
+	// MaxBytes is the maximum total size of stored responses.
+	MaxBytes int64 `yaml:"max_bytes"`
+	// MaxEntryBytes is the maximum size of single stored response.
+	MaxEntryBytes int64 `yaml:"max_entry_bytes"`
+}
+
+// Mirror represents config for sending copies of requests to the shadow pool.
+type Mirror struct {
+	// Pool name to send copies of requests to. Empty pool disables mirroring.
+	Pool string `yaml:"pool"`
+	// SampleRate in range [0, 1] is the share of requests to mirror.
+	SampleRate float64 `yaml:"sample_rate"`
+	// MaxBodyBytes is the maximum size of request body buffered for mirroring.
+	MaxBodyBytes int64 `yaml:"max_body_bytes"`
+	// TimeoutMilliseconds is timeout of mirrored request.
+	TimeoutMilliseconds uint32 `yaml:"timeout_milliseconds"`
+	// MaxInFlight is the maximum amount of mirrored requests at the same time.
+	MaxInFlight uint32 `yaml:"max_in_flight"`
+}
+
+// UnmarshalYAML decodes config and sets timeouts and affinity of the default pool
+// as defaults for other pools, regardless of the order of fields.
+func (b *Balancer) UnmarshalYAML(node *yaml.Node) error {
+	type plain Balancer
+	if err := node.Decode((*plain)(b)); err != nil {
+		return err
+	}
+
+	poolsNode := mappingValue(node, "pools")
+	if poolsNode == nil {
+		return nil
+	}
+
+	pools, err := decodePools(poolsNode, func() Pool {
+		pool := DefaultForPool()
+		pool.Timeouts = b.Timeouts
+		pool.Affinity = b.Affinity
+
+		return pool
+	})
+	if err != nil {
+		return err
+	}
+
+	b.Pools = pools
+
+	return nil
+}
+
+// mappingValue returns value of the key in mapping node or nil if there is no such key.
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node.Kind != yaml.MappingNode {
+		return nil
+	}
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+
+	return nil
+}
+
+// Pools maps pool name to its config. Fields not set for the pool have default values.
+type Pools map[string]Pool
+
+// UnmarshalYAML sets default values for fields not set for the pool.
+func (p *Pools) UnmarshalYAML(node *yaml.Node) error {
+	pools, err := decodePools(node, DefaultForPool)
+	if err != nil {
+		return err
+	}
+
+	*p = pools
+
+	return nil
+}
+
+// decodePools decodes pools into configs created by defaults, so fields not set for the pool keep default values.
+func decodePools(node *yaml.Node, defaults func() Pool) (Pools, error) {
+	var nodes map[string]yaml.Node
+	if err := node.Decode(&nodes); err != nil {
+		return nil, err
+	}
+
+	pools := make(Pools, len(nodes))
+	for name, poolNode := range nodes {
+		if name == DefaultPool {
+			return nil, fmt.Errorf("line %d: pool name %q is reserved for the top level pool", poolNode.Line, DefaultPool)
+		}
+
+		pool := defaults()
+		if err := poolNode.Decode(&pool); err != nil {
+			return nil, err
+		}
+
+		pools[name] = pool
+	}
+
+	return pools, nil
+}
+
+// Routing represents config for routing requests between pools.
+type Routing struct {
+	// Rules are checked in order before splits. Request is sent to the pool of the first matching rule.
+	Rules []Rule `yaml:"rules"`
+	// DebugBackend config.
+	DebugBackend DebugBackend `yaml:"debug_backend"`
+	// Splits of traffic between pools. If empty, all requests are sent to the default pool.
+	Splits []Split `yaml:"splits"`
+	// HashOn config pins the client to one side of the splits.
+	HashOn HashOn `yaml:"hash_on"`
+	// Access config checked for all requests before routing.
+	Access Access `yaml:"access"`
+}
+
+// Split represents the share of traffic sent to the pool.
+type Split struct {
+	// Pool name. Default pool is named "default".
+	Pool string `yaml:"pool"`
+	// Weight of the split. Share of traffic is the weight divided by the sum of all weights.
+	Weight uint32 `yaml:"weight"`
+}
+
+// Rule represents config for sending matching requests to the pool.
+type Rule struct {
+	// Pool name. Default pool is named "default".
+	Pool string `yaml:"pool"`
+	// Match is a list of conditions. Rule matches if all of them succeed.
+	Match []Match `yaml:"match"`
+}
+
+// Match represents condition on header, query parameter or cookie. Exactly one of them must be set.
+type Match struct {
+	// Header name.
+	Header string `yaml:"header"`
+	// Query parameter name.
+	Query string `yaml:"query"`
+	// Cookie name.
+	Cookie string `yaml:"cookie"`
+	// Value to compare with. If empty, any non-empty value matches.
+	Value string `yaml:"value"`
+}
+
+// DebugBackend represents config for sending request to the backend from header.
+type DebugBackend struct {
+	// Header with backend <host>:<port>. Empty header disables debug routing.
+	Header string `yaml:"header"`
+	// AllowedBackends may be requested without secret.
+	AllowedBackends []string `yaml:"allowed_backends"`
+	// SecretHeader contains the secret.
+	SecretHeader string `yaml:"secret_header"`
+	// Secret allowing to request any backend. Empty secret is never accepted.
+	Secret string `yaml:"secret"`
+}
+
+// HashOn represents config for the key pinning the client to one side of the splits.
+// If the key is absent in request, the split is chosen randomly.
+type HashOn struct {
+	// Header name to take the key from.
+	Header string `yaml:"header"`
+	// Cookie name to take the key from. Used if header is not set.
+	Cookie string `yaml:"cookie"`
+}
+
+// Admin represents config for admin server exposing metrics.
+type Admin struct {
+	// Port to listen. Zero disables admin server.
+	Port uint32 `yaml:"port"`
+}
+
+// Backend represents config for single backend.
+type Backend struct {
+	// Address is <host>:<port> string.
+	Address string `yaml:"address"`
+	// Priority of the backend. 0 is the highest priority.
+	Priority uint32 `yaml:"priority"`
+}
+
+// UnmarshalYAML allows to set backend as plain <host>:<port> string.
+func (b *Backend) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		*b = Backend{Address: node.Value}
+		return nil
+	}
+
+	type plain Backend
+	if err := node.Decode((*plain)(b)); err != nil {
+		return err
+	}
+
+	if b.Address == "" {
+		return fmt.Errorf("line %d: backend address is not set", node.Line)
+	}
+
+	return nil
+}
+
+// BackendAddresses returns addresses of configured backends.
+func (conf Pool) BackendAddresses() []string {
+	addresses := make([]string, 0, len(conf.Backends))
+	for _, backend := range conf.Backends {
+		addresses = append(addresses, backend.Address)
+	}
+
+	return addresses
+}
+
+// BackendPriorities returns priority of each configured backend.
+func (conf Pool) BackendPriorities() map[string]uint32 {
+	priorities := make(map[string]uint32, len(conf.Backends))
+	for _, backend := range conf.Backends {
+		priorities[backend.Address] = backend.Priority
+	}
+
+	return priorities
+}
+
+// PriorityGroups represents config for failover between backends with different priorities.
+type PriorityGroups struct {
+	// MinHealthy is the amount of healthy backends in priority group required to send traffic to it.
+	// If there are less healthy backends, traffic spills over to the group with lower priority.
+	MinHealthy uint32 `yaml:"min_healthy"`
+}
+
+// SlowStart represents config for ramping traffic to backends which became healthy recently.
+type SlowStart struct {
+	// WindowSeconds is the duration of the ramp. Zero disables slow start.
+	WindowSeconds uint32 `yaml:"window_seconds"`
+	// MinWeight in range (0, 1] is the share of traffic backend receives at the beginning of the ramp.
+	MinWeight float64 `yaml:"min_weight"`
+	// Curve of the ramp. Available are:
+	//	- linear;
+	//	- exponential.
+	Curve string `yaml:"curve"`
+}
+
+// Discovery represents config for discovering backends.
+type Discovery struct {
+	// Type of discovery. Empty string means static backends. Available are:
+	//	- dns;
+	//	- file;
+	//	- http.
+	Type string `yaml:"type"`
+	// RefreshSeconds is period between discoveries.
+	RefreshSeconds uint32 `yaml:"refresh_seconds"`
+	// DNS config is used when type is dns.
+	DNS DNSDiscovery `yaml:"dns"`
+	// File config is used when type is file.
+	File FileDiscovery `yaml:"file"`
+	// HTTP config is used when type is http.
+	HTTP HTTPDiscovery `yaml:"http"`
+}
+
+// DNSDiscovery represents config for discovering backends with DNS.
+type DNSDiscovery struct {
+	// Name to resolve. For SRV records it is full name, like _http._tcp.example.com.
+	Name string `yaml:"name"`
+	// RecordType is A (A and AAAA records) or SRV.
+	RecordType string `yaml:"record_type"`
+	// Port of backends for A records.
+	Port uint16 `yaml:"port"`
+}
+
+// FileDiscovery represents config for discovering backends from file.
+type FileDiscovery struct {
+	// Path to JSON or YAML file with list of target groups in Prometheus file_sd format.
+	Path string `yaml:"path"`
+}
+
+// HTTPDiscovery represents config for discovering backends from HTTP endpoint.
+type HTTPDiscovery struct {
+	// URL of endpoint returning list of target groups in Prometheus http_sd format.
+	URL string `yaml:"url"`
 }
 
 // Heathcheck represents config for healhchecks.
@@ -21,15 +626,221 @@ type Heathcheck struct {
 	RequestTimeoutSeconds uint32 `yaml:"request_timeout_seconds"`
 }
 
+// CircuitBreaker represents config for per backend circuit breakers.
+type CircuitBreaker struct {
+	// Enabled turns circuit breakers on.
+	Enabled bool `yaml:"enabled"`
+	// WindowSeconds is the size of sliding window to calculate error and slow call rates.
+	WindowSeconds uint32 `yaml:"window_seconds"`
+	// MinRequests is the minimum amount of requests inside the window to open the circuit.
+	MinRequests uint32 `yaml:"min_requests"`
+	// FailureRateThreshold in range (0, 1]. Responses with 5xx codes are failures.
+	FailureRateThreshold float64 `yaml:"failure_rate_threshold"`
+	// SlowCallDurationMilliseconds is the latency after which request is considered slow.
+	SlowCallDurationMilliseconds uint32 `yaml:"slow_call_duration_milliseconds"`
+	// SlowCallRateThreshold in range [0, 1]. Zero disables latency based opening.
+	SlowCallRateThreshold float64 `yaml:"slow_call_rate_threshold"`
+	// OpenTimeoutSeconds is the time circuit stays open before switching to half-open.
+	OpenTimeoutSeconds uint32 `yaml:"open_timeout_seconds"`
+	// HalfOpenMaxRequests is the positive amount of trial requests in half-open state.
+	HalfOpenMaxRequests uint32 `yaml:"half_open_max_requests"`
+}
+
+// Timeouts represents config for timeouts of requests to backends. Zero value disables timeout.
+type Timeouts struct {
+	// DialMilliseconds is timeout for establishing connection to backend.
+	DialMilliseconds uint32 `yaml:"dial_milliseconds"`
+	// TLSHandshakeMilliseconds is timeout for TLS handshake with backend.
+	TLSHandshakeMilliseconds uint32 `yaml:"tls_handshake_milliseconds"`
+	// ResponseHeaderMilliseconds is timeout for waiting backend's response headers after request is written.
+	ResponseHeaderMilliseconds uint32 `yaml:"response_header_milliseconds"`
+	// IdleConnSeconds is the time idle keep-alive connection to backend remains open.
+	IdleConnSeconds uint32 `yaml:"idle_conn_seconds"`
+	// RequestSeconds is timeout for the whole proxied request including response body.
+	RequestSeconds uint32 `yaml:"request_seconds"`
+}
+
+// ServerTimeouts represents config for timeouts of client connections. Zero value disables timeout.
+type ServerTimeouts struct {
+	// ReadHeaderSeconds is timeout for reading request headers.
+	ReadHeaderSeconds uint32 `yaml:"read_header_seconds"`
+	// ReadSeconds is timeout for reading the entire request including body.
+	ReadSeconds uint32 `yaml:"read_seconds"`
+	// WriteSeconds is timeout for writing response. Should be greater than timeouts.request_seconds.
+	WriteSeconds uint32 `yaml:"write_seconds"`
+	// IdleSeconds is the time to wait for the next request on keep-alive connection.
+	IdleSeconds uint32 `yaml:"idle_seconds"`
+}
+
+// ErrorPages represents config for error responses of the balancer.
+type ErrorPages struct {
+	// Templates maps status code to templates used for it.
+	Templates map[int]ErrorTemplates `yaml:"templates"`
+	// InterceptBackendErrors makes balancer replace bodies of backend 5xx responses with error pages.
+	InterceptBackendErrors bool `yaml:"intercept_backend_errors"`
+}
+
+// ErrorTemplates contains paths to Go templates for each response format.
+// If path is empty, built-in template is used.
+type ErrorTemplates struct {
+	// JSON template is used when client accepts application/json.
+	JSON string `yaml:"json"`
+	// HTML template is used when client accepts text/html.
+	HTML string `yaml:"html"`
+	// Text template is used when client accepts text/plain.
+	Text string `yaml:"text"`
+}
+
+// Affinity represents config for cookie based session affinity.
+type Affinity struct {
+	// Enabled turns session affinity on.
+	Enabled bool `yaml:"enabled"`
+	// CookieName is the name of affinity cookie.
+	CookieName string `yaml:"cookie_name"`
+	// TTLSeconds is the lifetime of affinity cookie. It is prolonged on every request.
+	TTLSeconds uint32 `yaml:"ttl_seconds"`
+	// SigningKey is the secret used to sign affinity cookie.
+	SigningKey string `yaml:"signing_key"`
+}
+
 // DefaultForBalancer returns default config for balancer.
 func DefaultForBalancer() Balancer {
 	return Balancer{
-		Backends: []string{},
-		Port:     8080,
+		Pool: DefaultForPool(),
+		Port: 8080,
+		Routing: Routing{
+			Access: Access{
+				Default: "allow",
+			},
+		},
+		Shedding: Shedding{
+			LowestPriorityThreshold: 0.8,
+			DefaultPriority:         1,
+		},
+		RateLimitStore: RateLimitStore{
+			Type: "local",
+			Redis: RedisStore{
+				KeyPrefix:           "cloudru_balancer:rate_limit:",
+				TimeoutMilliseconds: 100,
+			},
+			FallbackRetrySeconds: 5,
+		},
+		Compression: Compression{
+			Enabled:      false,
+			Encodings:    []string{"zstd", "br", "gzip"},
+			MinSizeBytes: 1024,
+			ContentTypes: []string{
+				"text/",
+				"application/json",
+				"application/javascript",
+				"application/xml",
+				"image/svg+xml",
+			},
+		},
+		ServerTimeouts: ServerTimeouts{
+			ReadHeaderSeconds: 10,
+			ReadSeconds:       60,
+			WriteSeconds:      90,
+			IdleSeconds:       120,
+		},
+	}
+}
+
+// DefaultForPool returns default config for the pool of backends.
+func DefaultForPool() Pool {
+	return Pool{
+		Backends: []Backend{},
+		Timeouts: Timeouts{
+			DialMilliseconds:           5000,
+			TLSHandshakeMilliseconds:   5000,
+			ResponseHeaderMilliseconds: 30000,
+			IdleConnSeconds:            90,
+			RequestSeconds:             60,
+		},
+		Affinity: Affinity{
+			Enabled:    false,
+			CookieName: "cloudru_balancer_affinity",
+			TTLSeconds: 3600,
+		},
+		PriorityGroups: PriorityGroups{
+			MinHealthy: 1,
+		},
+		SlowStart: SlowStart{
+			WindowSeconds: 0,
+			MinWeight:     0.1,
+			Curve:         "linear",
+		},
 		Strategy: "RoundRobin",
+		Discovery: Discovery{
+			RefreshSeconds: 30,
+			DNS: DNSDiscovery{
+				RecordType: "A",
+			},
+		},
 		Heathcheck: Heathcheck{
 			CheckTimeoutSeconds:   60,
 			RequestTimeoutSeconds: 30,
 		},
+		CircuitBreaker: CircuitBreaker{
+			Enabled:                      false,
+			WindowSeconds:                10,
+			MinRequests:                  20,
+			FailureRateThreshold:         0.5,
+			SlowCallDurationMilliseconds: 5000,
+			SlowCallRateThreshold:        0,
+			OpenTimeoutSeconds:           30,
+			HalfOpenMaxRequests:          5,
+		},
+		Mirror: Mirror{
+			SampleRate:          1,
+			MaxBodyBytes:        1 << 20,
+			TimeoutMilliseconds: 5000,
+			MaxInFlight:         100,
+		},
+		Cache: Cache{
+			Enabled:       false,
+			MaxBytes:      64 << 20,
+			MaxEntryBytes: 1 << 20,
+		},
+		Coalesce: Coalesce{
+			Enabled:      false,
+			KeyHeaders:   []string{"Accept", "Accept-Encoding", "Authorization", "Cookie"},
+			MaxBodyBytes: 1 << 20,
+		},
+		Access: Access{
+			Default: "allow",
+		},
+		Auth: Auth{
+			APIKeys: APIKeysAuth{
+				Header: "X-API-Key",
+			},
+			Basic: BasicAuth{
+				Realm: "cloudru-balancer",
+			},
+			JWT: JWTAuth{
+				LeewaySeconds: 30,
+				SubjectClaim:  "sub",
+			},
+			SubjectHeader: "X-Auth-Subject",
+			MethodHeader:  "X-Auth-Method",
+		},
+		Concurrency: Concurrency{
+			MaxPerBackend:            0,
+			QueueSize:                100,
+			QueueTimeoutMilliseconds: 1000,
+		},
+		AdaptiveConcurrency: AdaptiveConcurrency{
+			InitialLimit: 20,
+			MinLimit:     1,
+			MaxLimit:     1000,
+			AIMD: AIMD{
+				BackoffRatio: 0.9,
+			},
+			Gradient: Gradient{
+				Smoothing: 0.2,
+				Tolerance: 1.5,
+				Window:    600,
+			},
+		},
 	}
 }
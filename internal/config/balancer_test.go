@@ -0,0 +1,113 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
+)
+
+func TestBalancer_Backends(t *testing.T) {
+	t.Run("with strings and mappings", func(t *testing.T) {
+		conf := DefaultForBalancer()
+		err := yaml.Unmarshal([]byte(`
+backends:
+  - "primary:8081"
+  - address: "backup:8081"
+    priority: 1
+`), &conf)
+		assert.Nil(t, err)
+
+		assert.Equal(t, []string{"primary:8081", "backup:8081"}, conf.BackendAddresses())
+		assert.Equal(t, map[string]uint32{"primary:8081": 0, "backup:8081": 1}, conf.BackendPriorities())
+	})
+
+	t.Run("with mapping without address", func(t *testing.T) {
+		conf := DefaultForBalancer()
+		err := yaml.Unmarshal([]byte(`
+backends:
+  - priority: 1
+`), &conf)
+		assert.NotNil(t, err)
+	})
+}
+
+func TestBalancer_Pools(t *testing.T) {
+	t.Run("with defaults", func(t *testing.T) {
+		conf := DefaultForBalancer()
+		err := yaml.Unmarshal([]byte(`
+backends:
+  - "stable:8081"
+strategy: "P2C"
+pools:
+  canary:
+    backends:
+      - "canary:8081"
+routing:
+  splits:
+    - pool: "default"
+      weight: 95
+    - pool: "canary"
+      weight: 5
+  hash_on:
+    header: "X-User-ID"
+`), &conf)
+		assert.Nil(t, err)
+
+		assert.Equal(t, []string{"stable:8081"}, conf.BackendAddresses())
+		assert.Equal(t, "P2C", conf.Strategy)
+
+		canary := conf.Pools["canary"]
+		assert.Equal(t, []string{"canary:8081"}, canary.BackendAddresses())
+		assert.Equal(t, "RoundRobin", canary.Strategy)
+		assert.Equal(t, DefaultForPool().Heathcheck, canary.Heathcheck)
+
+		assert.Equal(t, []Split{{Pool: "default", Weight: 95}, {Pool: "canary", Weight: 5}}, conf.Routing.Splits)
+		assert.Equal(t, "X-User-ID", conf.Routing.HashOn.Header)
+	})
+
+	t.Run("with timeouts and affinity of the default pool", func(t *testing.T) {
+		conf := DefaultForBalancer()
+		err := yaml.Unmarshal([]byte(`
+pools:
+  canary:
+    timeouts:
+      request_seconds: 5
+  shadow:
+    affinity:
+      enabled: false
+timeouts:
+  dial_milliseconds: 100
+  request_seconds: 10
+affinity:
+  enabled: true
+  signing_key: "secret"
+`), &conf)
+		assert.Nil(t, err)
+
+		assert.Equal(t, uint32(100), conf.Timeouts.DialMilliseconds)
+		assert.Equal(t, uint32(10), conf.Timeouts.RequestSeconds)
+		assert.True(t, conf.Affinity.Enabled)
+
+		canary := conf.Pools["canary"]
+		assert.Equal(t, uint32(100), canary.Timeouts.DialMilliseconds)
+		assert.Equal(t, uint32(5), canary.Timeouts.RequestSeconds)
+		assert.Equal(t, conf.Affinity, canary.Affinity)
+
+		shadow := conf.Pools["shadow"]
+		assert.Equal(t, conf.Timeouts, shadow.Timeouts)
+		assert.False(t, shadow.Affinity.Enabled)
+		assert.Equal(t, "secret", shadow.Affinity.SigningKey)
+	})
+
+	t.Run("with reserved name", func(t *testing.T) {
+		conf := DefaultForBalancer()
+		err := yaml.Unmarshal([]byte(`
+pools:
+  default:
+    backends:
+      - "canary:8081"
+`), &conf)
+		assert.NotNil(t, err)
+	})
+}
@@ -0,0 +1,95 @@
+// discovery keeps set of backends up to date with external sources.
+package discovery
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"time"
+)
+
+// Provider returns current list of backends. Each backend is <host>:<port> string.
+type Provider interface {
+	// Targets returns current list of backends.
+	Targets(ctx context.Context) ([]string, error)
+}
+
+// Updater receives new set of backends.
+type Updater interface {
+	// UpdateBackends replaces current set of backends with given one.
+	UpdateBackends(backends []string)
+}
+
+// Watcher periodically asks Provider for backends and passes changed set to Updater.
+type Watcher struct {
+	logger   *slog.Logger
+	provider Provider
+	interval time.Duration
+	updater  Updater
+	current  []string
+}
+
+// NewWatcher creates Watcher. Backends given to Updater before watcher start must be passed as initial.
+func NewWatcher(
+	logger *slog.Logger,
+	provider Provider,
+	interval time.Duration,
+	updater Updater,
+	initial []string,
+) *Watcher {
+	return &Watcher{
+		logger:   logger,
+		provider: provider,
+		interval: interval,
+		updater:  updater,
+		current:  normalize(initial),
+	}
+}
+
+// Run watch loop. Should be started in separate goroutine.
+func (w *Watcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		w.refresh(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (w *Watcher) refresh(ctx context.Context) {
+	var cancel context.CancelFunc
+	ctx, cancel = context.WithTimeout(ctx, w.interval)
+	defer cancel()
+
+	targets, err := w.provider.Targets(ctx)
+	if err != nil {
+		w.logger.Warn("Discover backends",
+			slog.String("error", err.Error()),
+		)
+		return
+	}
+
+	targets = normalize(targets)
+	if slices.Equal(targets, w.current) {
+		return
+	}
+
+	w.logger.Info("Backends changed",
+		slog.Any("backends", targets),
+	)
+
+	w.current = targets
+	w.updater.UpdateBackends(targets)
+}
+
+func normalize(backends []string) []string {
+	result := slices.Clone(backends)
+	slices.Sort(result)
+	return slices.Compact(result)
+}
@@ -0,0 +1,206 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDNS(t *testing.T) {
+	resolver := NewFakeResolver()
+	resolver.SetIPs("backend", "10.0.0.1", "10.0.0.2", "fd00::1")
+	resolver.SetSRV("_http._tcp.backend",
+		&net.SRV{Target: "backend-1.", Port: 8081},
+		&net.SRV{Target: "backend-2.", Port: 8082},
+	)
+
+	t.Run("with A records", func(t *testing.T) {
+		provider, err := NewDNS(resolver, "backend", 8081, RecordTypeA)
+		assert.Nil(t, err)
+
+		targets, err := provider.Targets(context.Background())
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"10.0.0.1:8081", "10.0.0.2:8081", "[fd00::1]:8081"}, targets)
+	})
+
+	t.Run("with SRV records", func(t *testing.T) {
+		provider, err := NewDNS(resolver, "_http._tcp.backend", 0, RecordTypeSRV)
+		assert.Nil(t, err)
+
+		targets, err := provider.Targets(context.Background())
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"backend-1:8081", "backend-2:8082"}, targets)
+	})
+
+	t.Run("with unknown name", func(t *testing.T) {
+		provider, err := NewDNS(resolver, "unknown", 8081, RecordTypeA)
+		assert.Nil(t, err)
+
+		_, err = provider.Targets(context.Background())
+		assert.NotNil(t, err)
+	})
+
+	t.Run("with A records and no port", func(t *testing.T) {
+		_, err := NewDNS(resolver, "backend", 0, RecordTypeA)
+		assert.NotNil(t, err)
+	})
+}
+
+func TestFile(t *testing.T) {
+	dir := t.TempDir()
+
+	t.Run("with json file", func(t *testing.T) {
+		fileName := filepath.Join(dir, "targets.json")
+		err := os.WriteFile(fileName, []byte(`[
+			{"targets": ["10.0.0.1:8081", "10.0.0.2:8081"], "labels": {"dc": "a"}},
+			{"targets": ["10.0.1.1:8081"]}
+		]`), 0o644)
+		assert.Nil(t, err)
+
+		targets, err := NewFile(fileName).Targets(context.Background())
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"10.0.0.1:8081", "10.0.0.2:8081", "10.0.1.1:8081"}, targets)
+	})
+
+	t.Run("with yaml file", func(t *testing.T) {
+		fileName := filepath.Join(dir, "targets.yml")
+		err := os.WriteFile(fileName, []byte(`
+- targets:
+    - "10.0.0.1:8081"
+  labels:
+    dc: a
+`), 0o644)
+		assert.Nil(t, err)
+
+		targets, err := NewFile(fileName).Targets(context.Background())
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"10.0.0.1:8081"}, targets)
+	})
+
+	t.Run("with missing file", func(t *testing.T) {
+		_, err := NewFile(filepath.Join(dir, "missing.json")).Targets(context.Background())
+		assert.NotNil(t, err)
+	})
+}
+
+func TestHTTP(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/large" {
+				fmt.Fprint(w, `[{"targets": ["`+strings.Repeat("a", maxResponseBytes)+`"]}]`)
+				return
+			}
+
+			if r.URL.Path != "/targets" {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+
+			fmt.Fprint(w, `[{"targets": ["10.0.0.1:8081"]}]`)
+		},
+	))
+	defer server.Close()
+
+	t.Run("with ok response", func(t *testing.T) {
+		targets, err := NewHTTP(server.Client(), server.URL+"/targets").Targets(context.Background())
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"10.0.0.1:8081"}, targets)
+	})
+
+	t.Run("with not ok response", func(t *testing.T) {
+		_, err := NewHTTP(server.Client(), server.URL+"/other").Targets(context.Background())
+		assert.NotNil(t, err)
+	})
+
+	t.Run("with too large response", func(t *testing.T) {
+		_, err := NewHTTP(server.Client(), server.URL+"/large").Targets(context.Background())
+		assert.ErrorContains(t, err, "larger than")
+	})
+}
+
+type recordingSet struct {
+	name    string
+	journal *[]string
+}
+
+func (s *recordingSet) AddBackend(backend string) {
+	*s.journal = append(*s.journal, s.name+" add "+backend)
+}
+
+func (s *recordingSet) RemoveBackend(backend string) {
+	*s.journal = append(*s.journal, s.name+" remove "+backend)
+}
+
+func TestMembers(t *testing.T) {
+	journal := []string{}
+	first := &recordingSet{name: "first", journal: &journal}
+	second := &recordingSet{name: "second", journal: &journal}
+
+	members := NewMembers([]string{"A", "B"}, first, second)
+
+	members.UpdateBackends([]string{"B", "C"})
+
+	assert.Equal(t, []string{
+		"first add C",
+		"second add C",
+		"second remove A",
+		"first remove A",
+	}, journal)
+}
+
+func TestWatcher(t *testing.T) {
+	resolver := NewFakeResolver()
+	resolver.SetIPs("backend", "10.0.0.1")
+
+	provider, err := NewDNS(resolver, "backend", 80, RecordTypeA)
+	assert.Nil(t, err)
+
+	updates := make(chan []string, 10)
+	watcher := NewWatcher(
+		slog.Default(),
+		provider,
+		20*time.Millisecond,
+		updaterFunc(func(backends []string) { updates <- backends }),
+		[]string{"10.0.0.1:80"},
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go watcher.Run(ctx)
+
+	resolver.SetIPs("backend", "10.0.0.2", "10.0.0.1")
+
+	select {
+	case backends := <-updates:
+		assert.True(t, slices.Equal([]string{"10.0.0.1:80", "10.0.0.2:80"}, backends))
+	case <-time.After(time.Second):
+		t.Fatal("no update received")
+	}
+
+	resolver.SetIPs("backend", "10.0.0.2")
+
+	select {
+	case backends := <-updates:
+		assert.Equal(t, []string{"10.0.0.2:80"}, backends)
+	case <-time.After(time.Second):
+		t.Fatal("no update received")
+	}
+}
+
+type updaterFunc func(backends []string)
+
+func (f updaterFunc) UpdateBackends(backends []string) {
+	f(backends)
+}
@@ -0,0 +1,92 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// Resolver is a subset of net.Resolver used by DNS provider.
+type Resolver interface {
+	// LookupSRV tries to resolve an SRV query of the given service, protocol, and domain name.
+	LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error)
+	// LookupIPAddr looks up host. It returns a slice of that host's IPv4 and IPv6 addresses.
+	LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error)
+}
+
+// DNS record types supported by DNS provider.
+const (
+	// RecordTypeA means A and AAAA records. Port must be specified.
+	RecordTypeA = "A"
+	// RecordTypeSRV means SRV records. Ports are taken from records.
+	RecordTypeSRV = "SRV"
+)
+
+// DNS is a Provider, that resolves backends from DNS records.
+type DNS struct {
+	resolver   Resolver
+	name       string
+	port       uint16
+	recordType string
+}
+
+// NewDNS creates DNS provider. For RecordTypeSRV name must be full SRV name, like _http._tcp.example.com.
+func NewDNS(resolver Resolver, name string, port uint16, recordType string) (*DNS, error) {
+	switch recordType {
+	case RecordTypeA:
+		if port == 0 {
+			return nil, fmt.Errorf("port must be set for %s records", RecordTypeA)
+		}
+	case RecordTypeSRV:
+	default:
+		return nil, fmt.Errorf("unknown DNS record type: %s", recordType)
+	}
+
+	return &DNS{
+		resolver:   resolver,
+		name:       name,
+		port:       port,
+		recordType: recordType,
+	}, nil
+}
+
+// Targets returns backends resolved from DNS.
+func (d *DNS) Targets(ctx context.Context) ([]string, error) {
+	if d.recordType == RecordTypeSRV {
+		return d.lookupSRV(ctx)
+	}
+
+	return d.lookupA(ctx)
+}
+
+func (d *DNS) lookupSRV(ctx context.Context) ([]string, error) {
+	_, records, err := d.resolver.LookupSRV(ctx, "", "", d.name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lookup SRV %s: %w", d.name, err)
+	}
+
+	targets := make([]string, 0, len(records))
+	for _, record := range records {
+		host := strings.TrimSuffix(record.Target, ".")
+		targets = append(targets, net.JoinHostPort(host, strconv.Itoa(int(record.Port))))
+	}
+
+	return targets, nil
+}
+
+func (d *DNS) lookupA(ctx context.Context) ([]string, error) {
+	addrs, err := d.resolver.LookupIPAddr(ctx, d.name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lookup %s: %w", d.name, err)
+	}
+
+	port := strconv.Itoa(int(d.port))
+	targets := make([]string, 0, len(addrs))
+	for _, addr := range addrs {
+		targets = append(targets, net.JoinHostPort(addr.String(), port))
+	}
+
+	return targets, nil
+}
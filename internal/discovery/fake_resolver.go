@@ -0,0 +1,70 @@
+package discovery
+
+import (
+	"context"
+	"net"
+	"sync"
+)
+
+// FakeResolver is an in-memory Resolver to be used in tests.
+// Names without records are resolved with *net.DNSError.
+type FakeResolver struct {
+	lock sync.RWMutex
+	srv  map[string][]*net.SRV
+	ips  map[string][]net.IPAddr
+}
+
+// NewFakeResolver creates empty FakeResolver.
+func NewFakeResolver() *FakeResolver {
+	return &FakeResolver{
+		srv: make(map[string][]*net.SRV),
+		ips: make(map[string][]net.IPAddr),
+	}
+}
+
+// SetSRV replaces SRV records of the name.
+func (r *FakeResolver) SetSRV(name string, records ...*net.SRV) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.srv[name] = records
+}
+
+// SetIPs replaces A and AAAA records of the host.
+func (r *FakeResolver) SetIPs(host string, ips ...string) {
+	addrs := make([]net.IPAddr, 0, len(ips))
+	for _, ip := range ips {
+		addrs = append(addrs, net.IPAddr{IP: net.ParseIP(ip)})
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.ips[host] = addrs
+}
+
+// LookupSRV returns records set by SetSRV.
+func (r *FakeResolver) LookupSRV(_ context.Context, _, _, name string) (string, []*net.SRV, error) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	records, ok := r.srv[name]
+	if !ok {
+		return "", nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+	}
+
+	return name, records, nil
+}
+
+// LookupIPAddr returns addresses set by SetIPs.
+func (r *FakeResolver) LookupIPAddr(_ context.Context, host string) ([]net.IPAddr, error) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	addrs, ok := r.ips[host]
+	if !ok {
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+
+	return addrs, nil
+}
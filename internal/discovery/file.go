@@ -0,0 +1,34 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"os"
+)
+
+// File is a Provider, that reads backends from JSON or YAML file with list of target groups.
+type File struct {
+	fileName string
+}
+
+// NewFile creates File provider.
+func NewFile(fileName string) *File {
+	return &File{
+		fileName: fileName,
+	}
+}
+
+// Targets returns backends listed in the file.
+func (f *File) Targets(_ context.Context) ([]string, error) {
+	content, err := os.ReadFile(f.fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	targets, err := parseTargetGroups(content)
+	if err != nil {
+		return nil, fmt.Errorf("file %s: %w", f.fileName, err)
+	}
+
+	return targets, nil
+}
@@ -0,0 +1,59 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// maxResponseBytes is the maximum size of the response with target groups.
+const maxResponseBytes = 4 << 20
+
+// HTTP is a Provider, that polls endpoint returning JSON or YAML list of target groups.
+type HTTP struct {
+	client *http.Client
+	url    string
+}
+
+// NewHTTP creates HTTP provider.
+func NewHTTP(client *http.Client, url string) *HTTP {
+	return &HTTP{
+		client: client,
+		url:    url,
+	}
+}
+
+// Targets returns backends listed in the response of the endpoint.
+func (h *HTTP) Targets(ctx context.Context) ([]string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, h.url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	rsp, err := h.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer rsp.Body.Close()
+
+	content, err := io.ReadAll(io.LimitReader(rsp.Body, maxResponseBytes+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(content) > maxResponseBytes {
+		return nil, fmt.Errorf("response from %s is larger than %d bytes", h.url, maxResponseBytes)
+	}
+
+	if rsp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected response status from %s: %s", h.url, rsp.Status)
+	}
+
+	targets, err := parseTargetGroups(content)
+	if err != nil {
+		return nil, fmt.Errorf("response from %s: %w", h.url, err)
+	}
+
+	return targets, nil
+}
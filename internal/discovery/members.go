@@ -0,0 +1,64 @@
+package discovery
+
+import "sync"
+
+// BackendSet is a part of balancer that keeps per backend state.
+type BackendSet interface {
+	// AddBackend starts tracking given backend.
+	AddBackend(backend string)
+	// RemoveBackend stops tracking given backend.
+	RemoveBackend(backend string)
+}
+
+// Members is an Updater that propagates added and removed backends to the sets.
+// Backends are added to sets in given order and removed in reverse order,
+// so sets given first must be the ones others rely on.
+type Members struct {
+	lock    sync.Mutex
+	current map[string]struct{}
+	sets    []BackendSet
+}
+
+// NewMembers creates Members. Initial backends must be already known to all sets.
+func NewMembers(initial []string, sets ...BackendSet) *Members {
+	current := make(map[string]struct{}, len(initial))
+	for _, backend := range initial {
+		current[backend] = struct{}{}
+	}
+
+	return &Members{
+		current: current,
+		sets:    sets,
+	}
+}
+
+// UpdateBackends replaces current set of backends with given one.
+func (m *Members) UpdateBackends(backends []string) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	next := make(map[string]struct{}, len(backends))
+	for _, backend := range backends {
+		next[backend] = struct{}{}
+
+		if _, ok := m.current[backend]; ok {
+			continue
+		}
+
+		for _, set := range m.sets {
+			set.AddBackend(backend)
+		}
+	}
+
+	for backend := range m.current {
+		if _, ok := next[backend]; ok {
+			continue
+		}
+
+		for i := len(m.sets) - 1; i >= 0; i-- {
+			m.sets[i].RemoveBackend(backend)
+		}
+	}
+
+	m.current = next
+}
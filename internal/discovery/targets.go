@@ -0,0 +1,34 @@
+package discovery
+
+import (
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// TargetGroup is an element of target list in Prometheus file_sd and http_sd format:
+//
+//	[
+//	  {"targets": ["host1:8081", "host2:8081"], "labels": {"dc": "a"}}
+//	]
+//
+// Labels are accepted for compatibility but not used.
+type TargetGroup struct {
+	Targets []string          `json:"targets" yaml:"targets"`
+	Labels  map[string]string `json:"labels" yaml:"labels"`
+}
+
+// parseTargetGroups parses JSON or YAML list of target groups and returns all targets.
+func parseTargetGroups(content []byte) ([]string, error) {
+	var groups []TargetGroup
+	if err := yaml.Unmarshal(content, &groups); err != nil {
+		return nil, fmt.Errorf("failed to parse target groups: %w", err)
+	}
+
+	targets := []string{}
+	for _, group := range groups {
+		targets = append(targets, group.Targets...)
+	}
+
+	return targets, nil
+}
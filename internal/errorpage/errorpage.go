@@ -0,0 +1,191 @@
+// errorpage renders error responses in format requested by client.
+package errorpage
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	texttemplate "text/template"
+)
+
+// ErrCodeBackendError is used when 5xx response of the backend is replaced by error page.
+const ErrCodeBackendError = "backend_error"
+
+// ErrorResponse returned to client, when error occurred.
+type ErrorResponse struct {
+	// Msg includes occurred error.
+	Msg string `json:"msg"`
+	// Code is the returned http status code.
+	Code int `json:"status"`
+	// ErrCode is machine-readable error code.
+	ErrCode string `json:"error_code"`
+}
+
+// StatusText returns text for the status code. Can be used in templates.
+func (resp ErrorResponse) StatusText() string {
+	return http.StatusText(resp.Code)
+}
+
+// TemplateFiles contains paths to templates for each format. Empty path means built-in template.
+type TemplateFiles struct {
+	JSON string
+	HTML string
+	Text string
+}
+
+type executor interface {
+	Execute(w io.Writer, data any) error
+}
+
+var templateFuncs = map[string]any{
+	"json": func(v any) (string, error) {
+		bytes, err := json.Marshal(v)
+		return string(bytes), err
+	},
+}
+
+var (
+	defaultHTML = htmltemplate.Must(htmltemplate.New("html").Parse(`<!DOCTYPE html>
+<html>
+<head><title>{{.Code}} {{.StatusText}}</title></head>
+<body>
+<h1>{{.Code}} {{.StatusText}}</h1>
+<p>{{.Msg}}</p>
+</body>
+</html>
+`))
+	defaultText = texttemplate.Must(texttemplate.New("text").Parse("{{.Code}} {{.StatusText}}: {{.Msg}}\n"))
+)
+
+// Renderer writes error responses in format negotiated by Accept header.
+// Nil Renderer uses built-in templates.
+type Renderer struct {
+	templates              map[int]map[Format]executor
+	interceptBackendErrors bool
+}
+
+// NewRenderer creates Renderer with user-supplied templates per status code.
+// If interceptBackendErrors is true, Renderer replaces 5xx responses of backends with error pages.
+func NewRenderer(files map[int]TemplateFiles, interceptBackendErrors bool) (*Renderer, error) {
+	templates := make(map[int]map[Format]executor, len(files))
+
+	for statusCode, statusFiles := range files {
+		byFormat := make(map[Format]executor)
+
+		for format, fileName := range map[Format]string{
+			FormatJSON: statusFiles.JSON,
+			FormatHTML: statusFiles.HTML,
+			FormatText: statusFiles.Text,
+		} {
+			if fileName == "" {
+				continue
+			}
+
+			tmpl, err := parseTemplate(format, fileName)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse %s template for status %d: %w", format, statusCode, err)
+			}
+
+			byFormat[format] = tmpl
+		}
+
+		templates[statusCode] = byFormat
+	}
+
+	return &Renderer{
+		templates:              templates,
+		interceptBackendErrors: interceptBackendErrors,
+	}, nil
+}
+
+func parseTemplate(format Format, fileName string) (executor, error) {
+	content, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	if format == FormatHTML {
+		return htmltemplate.New(fileName).Funcs(templateFuncs).Parse(string(content))
+	}
+
+	return texttemplate.New(fileName).Funcs(templateFuncs).Parse(string(content))
+}
+
+// Render error response in the given format.
+func (r *Renderer) Render(format Format, resp ErrorResponse) []byte {
+	if r != nil {
+		if tmpl, ok := r.templates[resp.Code][format]; ok {
+			buf := bytes.Buffer{}
+			if err := tmpl.Execute(&buf, resp); err == nil {
+				return buf.Bytes()
+			}
+		}
+	}
+
+	buf := bytes.Buffer{}
+
+	switch format {
+	case FormatHTML:
+		_ = defaultHTML.Execute(&buf, resp)
+	case FormatText:
+		_ = defaultText.Execute(&buf, resp)
+	default:
+		_ = json.NewEncoder(&buf).Encode(resp)
+	}
+
+	return buf.Bytes()
+}
+
+// Write error response to client in format negotiated by Accept header of the request.
+func (r *Renderer) Write(w http.ResponseWriter, req *http.Request, statusCode int, errCode string, err error) {
+	format := Negotiate(req.Header.Get("Accept"))
+	body := r.Render(format, ErrorResponse{
+		Msg:     err.Error(),
+		Code:    statusCode,
+		ErrCode: errCode,
+	})
+
+	w.Header().Set("Content-Type", format.ContentType())
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.WriteHeader(statusCode)
+	_, _ = w.Write(body)
+}
+
+// InterceptsBackendErrors reports if 5xx responses of backends should be replaced by error pages.
+func (r *Renderer) InterceptsBackendErrors() bool {
+	return r != nil && r.interceptBackendErrors
+}
+
+// ReplaceBackendError replaces body of 5xx backend response with error page.
+// It has signature of httputil.ReverseProxy.ModifyResponse.
+func (r *Renderer) ReplaceBackendError(resp *http.Response) error {
+	if resp.StatusCode < http.StatusInternalServerError {
+		return nil
+	}
+
+	_ = resp.Body.Close()
+
+	format := Negotiate(resp.Request.Header.Get("Accept"))
+	body := r.Render(format, ErrorResponse{
+		Msg:     "error from backend",
+		Code:    resp.StatusCode,
+		ErrCode: ErrCodeBackendError,
+	})
+
+	for _, header := range []string{"Content-Encoding", "Content-Range", "ETag", "Last-Modified", "Transfer-Encoding"} {
+		resp.Header.Del(header)
+	}
+
+	resp.Header.Set("Content-Type", format.ContentType())
+	resp.Header.Set("Content-Length", strconv.Itoa(len(body)))
+	resp.ContentLength = int64(len(body))
+	resp.TransferEncoding = nil
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	return nil
+}
@@ -0,0 +1,99 @@
+package errorpage
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNegotiate(t *testing.T) {
+	cases := []struct {
+		accept   string
+		expected Format
+	}{
+		{accept: "", expected: FormatJSON},
+		{accept: "*/*", expected: FormatJSON},
+		{accept: "application/json", expected: FormatJSON},
+		{accept: "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", expected: FormatHTML},
+		{accept: "text/plain", expected: FormatText},
+		{accept: "text/*", expected: FormatHTML},
+		{accept: "text/html;q=0.5, text/plain", expected: FormatText},
+		{accept: "image/png", expected: FormatJSON},
+	}
+
+	for _, c := range cases {
+		assert.Equal(t, c.expected, Negotiate(c.accept), "accept: %q", c.accept)
+	}
+}
+
+func TestRenderer(t *testing.T) {
+	dir := t.TempDir()
+	htmlFile := filepath.Join(dir, "503.html")
+	err := os.WriteFile(htmlFile, []byte("<p>Sorry: {{.Msg}}</p>"), 0o644)
+	assert.Nil(t, err)
+
+	renderer, err := NewRenderer(map[int]TemplateFiles{
+		http.StatusServiceUnavailable: {HTML: htmlFile},
+	}, true)
+	assert.Nil(t, err)
+
+	t.Run("uses user template", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "http://test.url", nil)
+		req.Header.Set("Accept", "text/html")
+
+		renderer.Write(recorder, req, http.StatusServiceUnavailable, "some_code", errors.New("<no backends>"))
+
+		assert.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+		assert.Equal(t, "text/html; charset=utf-8", recorder.Header().Get("Content-Type"))
+		assert.Equal(t, "<p>Sorry: &lt;no backends&gt;</p>", recorder.Body.String())
+	})
+
+	t.Run("falls back to built-in template", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "http://test.url", nil)
+		req.Header.Set("Accept", "text/plain")
+
+		renderer.Write(recorder, req, http.StatusServiceUnavailable, "some_code", errors.New("no backends"))
+
+		assert.Equal(t, "503 Service Unavailable: no backends\n", recorder.Body.String())
+	})
+
+	t.Run("nil renderer writes json", func(t *testing.T) {
+		var nilRenderer *Renderer
+
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "http://test.url", nil)
+
+		nilRenderer.Write(recorder, req, http.StatusBadGateway, "some_code", errors.New("oops"))
+
+		assert.Equal(t, "application/json", recorder.Header().Get("Content-Type"))
+		assert.Equal(t, `{"msg":"oops","status":502,"error_code":"some_code"}`+"\n", recorder.Body.String())
+	})
+
+	t.Run("replaces backend error", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "http://test.url", nil)
+		req.Header.Set("Accept", "text/html")
+
+		resp := &http.Response{
+			StatusCode: http.StatusServiceUnavailable,
+			Header:     http.Header{"Content-Type": []string{"text/plain"}},
+			Body:       io.NopCloser(strings.NewReader("stack trace")),
+			Request:    req,
+		}
+
+		assert.Nil(t, renderer.ReplaceBackendError(resp))
+
+		body, err := io.ReadAll(resp.Body)
+		assert.Nil(t, err)
+		assert.Equal(t, "<p>Sorry: error from backend</p>", string(body))
+		assert.Equal(t, "text/html; charset=utf-8", resp.Header.Get("Content-Type"))
+	})
+}
@@ -0,0 +1,107 @@
+package errorpage
+
+import (
+	"mime"
+	"strconv"
+	"strings"
+)
+
+// Format of the error response body.
+type Format string
+
+const (
+	// FormatJSON is application/json.
+	FormatJSON Format = "json"
+	// FormatHTML is text/html.
+	FormatHTML Format = "html"
+	// FormatText is text/plain.
+	FormatText Format = "text"
+)
+
+// formats in order of preference, when client accepts several of them with equal quality.
+var formats = []Format{FormatJSON, FormatHTML, FormatText}
+
+// ContentType returns value for Content-Type header.
+func (f Format) ContentType() string {
+	switch f {
+	case FormatHTML:
+		return "text/html; charset=utf-8"
+	case FormatText:
+		return "text/plain; charset=utf-8"
+	default:
+		return "application/json"
+	}
+}
+
+func (f Format) mediaType() string {
+	switch f {
+	case FormatHTML:
+		return "text/html"
+	case FormatText:
+		return "text/plain"
+	default:
+		return "application/json"
+	}
+}
+
+// Negotiate chooses format of error response using value of Accept header.
+// If header is empty or nothing acceptable is supported, FormatJSON is returned.
+func Negotiate(accept string) Format {
+	if accept == "" {
+		return FormatJSON
+	}
+
+	best := FormatJSON
+	bestQuality := 0.0
+
+	for _, format := range formats {
+		quality := acceptQuality(accept, format.mediaType())
+		if quality > bestQuality {
+			best = format
+			bestQuality = quality
+		}
+	}
+
+	return best
+}
+
+// acceptQuality returns quality of the most specific media range in Accept header matching given media type.
+func acceptQuality(accept, mediaType string) float64 {
+	typ, _, _ := strings.Cut(mediaType, "/")
+
+	quality := 0.0
+	specificity := -1
+
+	for _, part := range strings.Split(accept, ",") {
+		rangeType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+
+		var rangeSpecificity int
+		switch {
+		case rangeType == mediaType:
+			rangeSpecificity = 2
+		case rangeType == typ+"/*":
+			rangeSpecificity = 1
+		case rangeType == "*/*":
+			rangeSpecificity = 0
+		default:
+			continue
+		}
+
+		if rangeSpecificity <= specificity {
+			continue
+		}
+
+		specificity = rangeSpecificity
+		quality = 1
+		if q, ok := params["q"]; ok {
+			if parsed, err := strconv.ParseFloat(q, 64); err == nil {
+				quality = parsed
+			}
+		}
+	}
+
+	return quality
+}
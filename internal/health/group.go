@@ -0,0 +1,56 @@
+package health
+
+import (
+	"context"
+	"sync"
+)
+
+// Group runs Checker for each backend in the set.
+type Group struct {
+	ctx        context.Context
+	newChecker func(backend string) *Checker
+	lock       sync.Mutex
+	cancels    map[string]context.CancelFunc
+}
+
+// NewGroup creates Group and starts checkers for given backends.
+// All checkers are stopped when ctx is done.
+func NewGroup(ctx context.Context, newChecker func(backend string) *Checker, backends []string) *Group {
+	g := &Group{
+		ctx:        ctx,
+		newChecker: newChecker,
+		cancels:    make(map[string]context.CancelFunc, len(backends)),
+	}
+
+	for _, backend := range backends {
+		g.AddBackend(backend)
+	}
+
+	return g
+}
+
+// AddBackend starts checker for the backend.
+func (g *Group) AddBackend(backend string) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	if _, ok := g.cancels[backend]; ok {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(g.ctx)
+	g.cancels[backend] = cancel
+
+	go g.newChecker(backend).Run(ctx)
+}
+
+// RemoveBackend stops checker of the backend.
+func (g *Group) RemoveBackend(backend string) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	if cancel, ok := g.cancels[backend]; ok {
+		cancel()
+		delete(g.cancels, backend)
+	}
+}
@@ -48,6 +48,7 @@ func NewChecker(
 // Run check loop. Should be started in separate goroutine.
 func (checker *Checker) Run(ctx context.Context) {
 	ticker := time.NewTicker(checker.checkTimeout)
+	defer ticker.Stop()
 
 	for {
 		select {
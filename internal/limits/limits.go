@@ -0,0 +1,145 @@
+// limits rejects requests exceeding size limits.
+package limits
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"github.com/AleksandrMatsko/cloudru-balancer/internal/balancer"
+	"github.com/AleksandrMatsko/cloudru-balancer/internal/errorpage"
+)
+
+// Machine-readable error codes returned in ErrorResponse.
+const (
+	// ErrCodeURITooLong means that request URL exceeds the limit.
+	ErrCodeURITooLong = "uri_too_long"
+	// ErrCodeHeaderTooLarge means that request headers exceed the limit.
+	ErrCodeHeaderTooLarge = "request_header_too_large"
+)
+
+// Limit names passed to violation callback.
+const (
+	LimitBody   = "body"
+	LimitHeader = "header"
+	LimitURL    = "url"
+)
+
+// headerOverhead is the size of ": " and "\r\n" around each header line.
+const headerOverhead = 4
+
+// Settings of limits. Zero value disables the limit.
+type Settings struct {
+	// MaxBodyBytes is the maximum size of request body.
+	MaxBodyBytes int64
+	// MaxHeaderBytes is the maximum total size of request header lines.
+	MaxHeaderBytes int
+	// MaxURLLength is the maximum length of request URI.
+	MaxURLLength int
+}
+
+// Limits checks requests before sending them to the next handler.
+// Body which turns out to be too large while proxying is reported by Balancer with 413 status code.
+type Limits struct {
+	logger      *slog.Logger
+	next        http.Handler
+	settings    Settings
+	errorPages  *errorpage.Renderer
+	onViolation func(limit string)
+}
+
+// New creates Limits in front of next handler. onViolation is called with the name of exceeded limit and may be nil.
+func New(
+	logger *slog.Logger,
+	next http.Handler,
+	settings Settings,
+	errorPages *errorpage.Renderer,
+	onViolation func(limit string),
+) *Limits {
+	return &Limits{
+		logger:      logger,
+		next:        next,
+		settings:    settings,
+		errorPages:  errorPages,
+		onViolation: onViolation,
+	}
+}
+
+// ServeHTTP rejects request exceeding limits or sends it to the next handler with limited body.
+func (l *Limits) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if l.settings.MaxURLLength > 0 && len(r.RequestURI) > l.settings.MaxURLLength {
+		l.reject(w, r, LimitURL, http.StatusRequestURITooLong, ErrCodeURITooLong,
+			fmt.Errorf("request URI is longer than %d", l.settings.MaxURLLength))
+		return
+	}
+
+	if l.settings.MaxHeaderBytes > 0 && headerSize(r) > l.settings.MaxHeaderBytes {
+		l.reject(w, r, LimitHeader, http.StatusRequestHeaderFieldsTooLarge, ErrCodeHeaderTooLarge,
+			fmt.Errorf("request headers are larger than %d bytes", l.settings.MaxHeaderBytes))
+		return
+	}
+
+	if l.settings.MaxBodyBytes > 0 && r.Body != nil && r.Body != http.NoBody {
+		if r.ContentLength > l.settings.MaxBodyBytes {
+			l.reject(w, r, LimitBody, http.StatusRequestEntityTooLarge, balancer.ErrCodeRequestBodyTooLarge,
+				fmt.Errorf("request body is larger than %d bytes", l.settings.MaxBodyBytes))
+			return
+		}
+
+		r.Body = &limitedBody{
+			ReadCloser: http.MaxBytesReader(w, r.Body, l.settings.MaxBodyBytes),
+			onExceeded: func() { l.violation(r, LimitBody) },
+		}
+	}
+
+	l.next.ServeHTTP(w, r)
+}
+
+func headerSize(r *http.Request) int {
+	size := len("Host") + len(r.Host) + headerOverhead
+	for name, values := range r.Header {
+		for _, value := range values {
+			size += len(name) + len(value) + headerOverhead
+		}
+	}
+
+	return size
+}
+
+func (l *Limits) reject(w http.ResponseWriter, r *http.Request, limit string, statusCode int, errCode string, err error) {
+	l.violation(r, limit)
+	l.errorPages.Write(w, r, statusCode, errCode, err)
+}
+
+func (l *Limits) violation(r *http.Request, limit string) {
+	l.logger.Warn("Request exceeds limit",
+		slog.String("limit", limit),
+		slog.String("method", r.Method),
+		slog.String("remote_addr", r.RemoteAddr),
+	)
+
+	if l.onViolation != nil {
+		l.onViolation(limit)
+	}
+}
+
+// limitedBody reports the first read exceeding the limit.
+type limitedBody struct {
+	io.ReadCloser
+	onExceeded func()
+	reported   bool
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+
+	var maxBytesErr *http.MaxBytesError
+	if !b.reported && errors.As(err, &maxBytesErr) {
+		b.reported = true
+		b.onExceeded()
+	}
+
+	return n, err
+}
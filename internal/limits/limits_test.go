@@ -0,0 +1,116 @@
+package limits
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/AleksandrMatsko/cloudru-balancer/internal/balancer"
+	"github.com/AleksandrMatsko/cloudru-balancer/internal/errorpage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLimits(t *testing.T) {
+	t.Parallel()
+
+	settings := Settings{
+		MaxBodyBytes:   10,
+		MaxHeaderBytes: 100,
+		MaxURLLength:   20,
+	}
+
+	echo := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			return
+		}
+
+		_, _ = w.Write(body)
+	})
+
+	tests := []struct {
+		name            string
+		request         func() *http.Request
+		expectedStatus  int
+		expectedErrCode string
+		expectedLimit   string
+	}{
+		{
+			name:           "within limits",
+			request:        func() *http.Request { return httptest.NewRequest(http.MethodPost, "/path", strings.NewReader("body")) },
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name: "too long url",
+			request: func() *http.Request {
+				return httptest.NewRequest(http.MethodGet, "/"+strings.Repeat("a", 20), nil)
+			},
+			expectedStatus:  http.StatusRequestURITooLong,
+			expectedErrCode: ErrCodeURITooLong,
+			expectedLimit:   LimitURL,
+		},
+		{
+			name: "too large headers",
+			request: func() *http.Request {
+				req := httptest.NewRequest(http.MethodGet, "/path", nil)
+				req.Header.Set("X-Large", strings.Repeat("a", 100))
+				return req
+			},
+			expectedStatus:  http.StatusRequestHeaderFieldsTooLarge,
+			expectedErrCode: ErrCodeHeaderTooLarge,
+			expectedLimit:   LimitHeader,
+		},
+		{
+			name: "too large content length",
+			request: func() *http.Request {
+				return httptest.NewRequest(http.MethodPost, "/path", strings.NewReader(strings.Repeat("a", 11)))
+			},
+			expectedStatus:  http.StatusRequestEntityTooLarge,
+			expectedErrCode: balancer.ErrCodeRequestBodyTooLarge,
+			expectedLimit:   LimitBody,
+		},
+		{
+			name: "too large streamed body",
+			request: func() *http.Request {
+				req := httptest.NewRequest(http.MethodPost, "/path", io.NopCloser(strings.NewReader(strings.Repeat("a", 11))))
+				req.ContentLength = -1
+				return req
+			},
+			expectedStatus: http.StatusRequestEntityTooLarge,
+			expectedLimit:  LimitBody,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			var violations []string
+			l := New(slog.Default(), echo, settings, nil, func(limit string) {
+				violations = append(violations, limit)
+			})
+
+			rec := httptest.NewRecorder()
+			l.ServeHTTP(rec, test.request())
+
+			assert.Equal(t, test.expectedStatus, rec.Code)
+
+			if test.expectedErrCode != "" {
+				var dto errorpage.ErrorResponse
+				assert.Nil(t, json.NewDecoder(rec.Body).Decode(&dto))
+				assert.Equal(t, test.expectedErrCode, dto.ErrCode)
+			}
+
+			if test.expectedLimit != "" {
+				assert.Equal(t, []string{test.expectedLimit}, violations)
+			} else {
+				assert.Empty(t, violations)
+			}
+		})
+	}
+}
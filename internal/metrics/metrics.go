@@ -0,0 +1,182 @@
+// metrics contains metrics of the balancer exposed in Prometheus format.
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "cloudru_balancer"
+
+// Metrics of the balancer.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	// PanicMode is 1 when balancer ignores backend health in the pool because too few backends are healthy.
+	PanicMode *prometheus.GaugeVec
+	// SplitRequests counts requests sent to each pool by traffic splitting.
+	SplitRequests *prometheus.CounterVec
+	// SplitWeight is the current weight of the split for each pool.
+	SplitWeight *prometheus.GaugeVec
+	// MirrorResponses counts mirrored requests by status codes of primary and shadow responses.
+	MirrorResponses *prometheus.CounterVec
+	// MirrorSkipped counts sampled requests which were not mirrored.
+	MirrorSkipped *prometheus.CounterVec
+	// CacheRequests counts requests by result of serving them from the cache.
+	CacheRequests *prometheus.CounterVec
+	// CacheSize is the total size of responses stored in the cache.
+	CacheSize *prometheus.GaugeVec
+	// CoalesceRequests counts requests by result of coalescing.
+	CoalesceRequests *prometheus.CounterVec
+	// LimitViolations counts requests exceeding size limits.
+	LimitViolations *prometheus.CounterVec
+	// AccessDenied counts requests denied by access rules.
+	AccessDenied *prometheus.CounterVec
+	// AuthRequests counts requests by method and result of authentication.
+	AuthRequests *prometheus.CounterVec
+	// ConcurrencyQueueDepth is the amount of requests waiting for the backend.
+	ConcurrencyQueueDepth *prometheus.GaugeVec
+	// ConcurrencyQueueWait is the time requests spent waiting for the backend.
+	ConcurrencyQueueWait *prometheus.HistogramVec
+	// ConcurrencyRejected counts requests rejected because backend has no free slot.
+	ConcurrencyRejected *prometheus.CounterVec
+	// ConcurrencyLimit is the current adaptive limit of concurrent requests to the backend.
+	ConcurrencyLimit *prometheus.GaugeVec
+	// ShedRequests counts requests rejected by load shedding.
+	ShedRequests *prometheus.CounterVec
+	// RateLimitRequests counts requests by result of rate limiting.
+	RateLimitRequests *prometheus.CounterVec
+	// RateLimitFallbacks counts requests limited by local buckets because shared store failed.
+	RateLimitFallbacks prometheus.Counter
+}
+
+// New creates Metrics with all collectors registered.
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		PanicMode: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "panic_mode",
+			Help:      "1 if balancer ignores backend health in the pool because too few backends are healthy.",
+		}, []string{"pool"}),
+		SplitRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "split_requests_total",
+			Help:      "Amount of requests sent to the pool by traffic splitting.",
+		}, []string{"pool"}),
+		SplitWeight: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "split_weight",
+			Help:      "Current weight of traffic split for the pool.",
+		}, []string{"pool"}),
+		MirrorResponses: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "mirror_responses_total",
+			Help:      "Amount of mirrored requests by status codes of primary and shadow responses.",
+		}, []string{"pool", "shadow_pool", "primary_code", "shadow_code"}),
+		MirrorSkipped: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "mirror_skipped_total",
+			Help:      "Amount of sampled requests which were not mirrored.",
+		}, []string{"pool", "shadow_pool", "reason"}),
+		CacheRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "cache_requests_total",
+			Help:      "Amount of requests by result of serving them from the cache: hit, miss, revalidated or bypass.",
+		}, []string{"pool", "result"}),
+		CacheSize: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "cache_size_bytes",
+			Help:      "Total size of responses stored in the cache.",
+		}, []string{"pool"}),
+		CoalesceRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "coalesce_requests_total",
+			Help:      "Amount of requests by result of coalescing: leader, shared, fallback or bypass.",
+		}, []string{"pool", "result"}),
+		LimitViolations: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "limit_violations_total",
+			Help:      "Amount of requests exceeding size limits: body, header or url.",
+		}, []string{"pool", "limit"}),
+		AccessDenied: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "access_denied_total",
+			Help:      "Amount of requests denied by access rules. Pool is empty for access rules of routing.",
+		}, []string{"pool"}),
+		AuthRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "auth_requests_total",
+			Help:      "Amount of requests by method and result of authentication: authenticated, invalid or missing.",
+		}, []string{"pool", "method", "result"}),
+		ConcurrencyQueueDepth: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "concurrency_queue_depth",
+			Help:      "Amount of requests waiting for free slot of the backend.",
+		}, []string{"pool", "backend"}),
+		ConcurrencyQueueWait: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "concurrency_queue_wait_seconds",
+			Help:      "Time requests spent waiting for free slot of the backend.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"pool", "backend"}),
+		ConcurrencyRejected: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "concurrency_rejected_total",
+			Help:      "Amount of requests rejected because backend has no free slot: queue_full, queue_timeout or limit_exceeded.",
+		}, []string{"pool", "backend", "reason"}),
+		ConcurrencyLimit: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "concurrency_limit",
+			Help:      "Current adaptive limit of concurrent requests to the backend.",
+		}, []string{"pool", "backend"}),
+		ShedRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "shed_requests_total",
+			Help:      "Amount of requests rejected by load shedding by priority and reason: in_flight or queued.",
+		}, []string{"priority", "reason"}),
+		RateLimitRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "rate_limit_requests_total",
+			Help:      "Amount of requests by result of rate limiting: allowed, limited or error.",
+		}, []string{"pool", "result"}),
+		RateLimitFallbacks: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "rate_limit_fallbacks_total",
+			Help:      "Amount of requests limited by local buckets because shared store failed.",
+		}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.PanicMode,
+		m.SplitRequests,
+		m.SplitWeight,
+		m.MirrorResponses,
+		m.MirrorSkipped,
+		m.CacheRequests,
+		m.CacheSize,
+		m.CoalesceRequests,
+		m.LimitViolations,
+		m.AccessDenied,
+		m.AuthRequests,
+		m.ConcurrencyQueueDepth,
+		m.ConcurrencyQueueWait,
+		m.ConcurrencyRejected,
+		m.ConcurrencyLimit,
+		m.ShedRequests,
+		m.RateLimitRequests,
+		m.RateLimitFallbacks,
+	)
+
+	return m
+}
+
+// Handler returns http.Handler exposing metrics.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
@@ -0,0 +1,218 @@
+// mirror sends copies of requests to the shadow pool discarding its responses.
+package mirror
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"time"
+)
+
+// ShadowHeader is set in mirrored requests, so backends may avoid side effects.
+const ShadowHeader = "X-Shadow-Request"
+
+// Reasons of skipping sampled request.
+const (
+	// SkipBodyTooLarge means request body exceeds Settings.MaxBodyBytes.
+	SkipBodyTooLarge = "body_too_large"
+	// SkipInFlightLimit means there are already Settings.MaxInFlight mirrored requests.
+	SkipInFlightLimit = "in_flight_limit"
+)
+
+// Settings of mirroring.
+type Settings struct {
+	// SampleRate in range [0, 1] is the share of requests to mirror.
+	SampleRate float64
+	// MaxBodyBytes is the maximum size of request body buffered for mirroring.
+	// Requests with larger bodies are not mirrored.
+	MaxBodyBytes int64
+	// Timeout of mirrored request.
+	Timeout time.Duration
+	// MaxInFlight is the maximum amount of mirrored requests at the same time.
+	MaxInFlight int
+}
+
+// Mirror sends requests to the primary handler and copies of sampled requests to the shadow one.
+// Shadow requests are sent in background, so they never affect client's latency.
+type Mirror struct {
+	logger    *slog.Logger
+	primary   http.Handler
+	shadow    http.Handler
+	settings  Settings
+	inFlight  chan struct{}
+	onCompare func(primaryStatus, shadowStatus int)
+	onSkip    func(reason string)
+}
+
+// New creates Mirror. onCompare is called with status codes of primary and shadow responses
+// after both are done unless primary handler panics, onSkip is called with the reason of not mirroring sampled request.
+func New(
+	logger *slog.Logger,
+	primary http.Handler,
+	shadow http.Handler,
+	settings Settings,
+	onCompare func(primaryStatus, shadowStatus int),
+	onSkip func(reason string),
+) *Mirror {
+	return &Mirror{
+		logger:    logger,
+		primary:   primary,
+		shadow:    shadow,
+		settings:  settings,
+		inFlight:  make(chan struct{}, max(settings.MaxInFlight, 1)),
+		onCompare: onCompare,
+		onSkip:    onSkip,
+	}
+}
+
+// ServeHTTP sends request to the primary handler and mirrors it if sampled.
+func (m *Mirror) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if m.settings.SampleRate <= 0 || rand.Float64() >= m.settings.SampleRate {
+		m.primary.ServeHTTP(w, r)
+		return
+	}
+
+	body, ok := m.bufferBody(r)
+	if !ok {
+		m.skip(SkipBodyTooLarge)
+		m.primary.ServeHTTP(w, r)
+		return
+	}
+
+	select {
+	case m.inFlight <- struct{}{}:
+	default:
+		m.skip(SkipInFlightLimit)
+		m.primary.ServeHTTP(w, r)
+		return
+	}
+
+	primaryStatus := make(chan int, 1)
+	// Channel is closed without status if primary handler panics, for example, with http.ErrAbortHandler,
+	// so shadow does not wait for it forever.
+	defer close(primaryStatus)
+
+	go m.serveShadow(m.shadowRequest(r, body), primaryStatus)
+
+	rec := &statusRecorder{ResponseWriter: w}
+	m.primary.ServeHTTP(rec, r)
+	primaryStatus <- rec.status()
+}
+
+// bufferBody reads body of the request up to MaxBodyBytes and replaces it, so primary receives the whole body.
+// It returns false if body is too large to mirror.
+func (m *Mirror) bufferBody(r *http.Request) ([]byte, bool) {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil, true
+	}
+
+	if r.ContentLength > m.settings.MaxBodyBytes {
+		return nil, false
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, m.settings.MaxBodyBytes+1))
+	r.Body = readCloser{
+		Reader: io.MultiReader(bytes.NewReader(body), errorReader{err: err}, r.Body),
+		Closer: r.Body,
+	}
+
+	if err != nil || int64(len(body)) > m.settings.MaxBodyBytes {
+		return nil, false
+	}
+
+	return body, true
+}
+
+func (m *Mirror) shadowRequest(r *http.Request, body []byte) *http.Request {
+	// Shadow request must not be cancelled when the client's one is done.
+	ctx := context.WithoutCancel(r.Context())
+
+	shadowReq := r.Clone(ctx)
+	shadowReq.Body = http.NoBody
+	if body != nil {
+		shadowReq.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	shadowReq.Header.Set(ShadowHeader, "true")
+
+	return shadowReq
+}
+
+func (m *Mirror) serveShadow(r *http.Request, primaryStatus <-chan int) {
+	defer func() { <-m.inFlight }()
+
+	shadowStatus := m.shadowStatus(r)
+	status, ok := <-primaryStatus
+	if !ok {
+		// Primary response is aborted, nothing to compare with.
+		return
+	}
+
+	if status != shadowStatus {
+		m.logger.Debug("Mirrored request status differs",
+			slog.String("method", r.Method),
+			slog.String("url", r.RequestURI),
+			slog.Int("primary_status", status),
+			slog.Int("shadow_status", shadowStatus),
+		)
+	}
+
+	if m.onCompare != nil {
+		m.onCompare(status, shadowStatus)
+	}
+}
+
+// shadowStatus sends request to the shadow handler and returns status code of its response.
+// Reverse proxy panics with http.ErrAbortHandler if response is aborted in the middle of the body,
+// for example, by timeout. It is not recovered by server in this goroutine, so it is reported as bad gateway.
+func (m *Mirror) shadowStatus(r *http.Request) (status int) {
+	ctx, cancel := context.WithTimeout(r.Context(), m.settings.Timeout)
+	defer cancel()
+
+	rec := &discardRecorder{header: make(http.Header)}
+
+	defer func() {
+		if v := recover(); v != nil {
+			if err, ok := v.(error); !ok || !errors.Is(err, http.ErrAbortHandler) {
+				panic(v)
+			}
+
+			m.logger.Debug("Mirrored request aborted",
+				slog.String("method", r.Method),
+				slog.String("url", r.RequestURI),
+			)
+			status = http.StatusBadGateway
+		}
+	}()
+
+	m.shadow.ServeHTTP(rec, r.WithContext(ctx))
+
+	return rec.status()
+}
+
+func (m *Mirror) skip(reason string) {
+	if m.onSkip != nil {
+		m.onSkip(reason)
+	}
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// errorReader returns error which occurred while buffering body, so primary receives it too.
+type errorReader struct {
+	err error
+}
+
+func (r errorReader) Read([]byte) (int, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+
+	return 0, io.EOF
+}
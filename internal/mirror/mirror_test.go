@@ -0,0 +1,235 @@
+package mirror
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type comparison struct {
+	primary int
+	shadow  int
+}
+
+type recorder struct {
+	lock        sync.Mutex
+	comparisons []comparison
+	skips       []string
+	done        chan struct{}
+}
+
+func newRecorder() *recorder {
+	return &recorder{done: make(chan struct{}, 100)}
+}
+
+func (rec *recorder) onCompare(primary, shadow int) {
+	rec.lock.Lock()
+	rec.comparisons = append(rec.comparisons, comparison{primary: primary, shadow: shadow})
+	rec.lock.Unlock()
+
+	rec.done <- struct{}{}
+}
+
+func (rec *recorder) onSkip(reason string) {
+	rec.lock.Lock()
+	defer rec.lock.Unlock()
+
+	rec.skips = append(rec.skips, reason)
+}
+
+func TestMirror(t *testing.T) {
+	t.Parallel()
+
+	settings := Settings{
+		SampleRate:   1,
+		MaxBodyBytes: 10,
+		Timeout:      time.Second,
+		MaxInFlight:  10,
+	}
+
+	t.Run("mirrors request with body", func(t *testing.T) {
+		t.Parallel()
+
+		shadowBodies := make(chan string, 1)
+		primary := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			_, _ = w.Write(body)
+		})
+		shadow := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			shadowBodies <- r.Header.Get(ShadowHeader) + " " + string(body)
+			w.WriteHeader(http.StatusInternalServerError)
+		})
+
+		rec := newRecorder()
+		m := New(slog.Default(), primary, shadow, settings, rec.onCompare, rec.onSkip)
+
+		w := httptest.NewRecorder()
+		m.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader("hello")))
+
+		assert.Equal(t, "hello", w.Body.String())
+		assert.Equal(t, "true hello", <-shadowBodies)
+
+		<-rec.done
+		assert.Equal(t, []comparison{{primary: http.StatusOK, shadow: http.StatusInternalServerError}}, rec.comparisons)
+	})
+
+	t.Run("does not mirror too large body", func(t *testing.T) {
+		t.Parallel()
+
+		primary := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			_, _ = w.Write(body)
+		})
+		shadow := http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+			t.Error("shadow must not be called")
+		})
+
+		rec := newRecorder()
+		m := New(slog.Default(), primary, shadow, settings, rec.onCompare, rec.onSkip)
+
+		body := "this body is longer than ten bytes"
+		req := httptest.NewRequest(http.MethodPost, "/", io.NopCloser(strings.NewReader(body)))
+		req.ContentLength = -1
+
+		w := httptest.NewRecorder()
+		m.ServeHTTP(w, req)
+
+		assert.Equal(t, body, w.Body.String())
+		assert.Equal(t, []string{SkipBodyTooLarge}, rec.skips)
+	})
+
+	t.Run("slow shadow does not delay client", func(t *testing.T) {
+		t.Parallel()
+
+		primary := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNoContent)
+		})
+		shadow := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			<-r.Context().Done()
+			w.WriteHeader(http.StatusGatewayTimeout)
+		})
+
+		rec := newRecorder()
+		m := New(slog.Default(), primary, shadow, Settings{
+			SampleRate:   1,
+			MaxBodyBytes: 10,
+			Timeout:      100 * time.Millisecond,
+			MaxInFlight:  1,
+		}, rec.onCompare, rec.onSkip)
+
+		start := time.Now()
+		m.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+		m.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+		assert.Less(t, time.Since(start), 50*time.Millisecond)
+
+		<-rec.done
+		assert.Equal(t, []comparison{{primary: http.StatusNoContent, shadow: http.StatusGatewayTimeout}}, rec.comparisons)
+		assert.Equal(t, []string{SkipInFlightLimit}, rec.skips)
+	})
+
+	t.Run("panic of primary releases shadow", func(t *testing.T) {
+		t.Parallel()
+
+		primary := http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+			panic(http.ErrAbortHandler)
+		})
+		shadowDone := make(chan struct{}, 2)
+		shadow := http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+			shadowDone <- struct{}{}
+		})
+
+		rec := newRecorder()
+		m := New(slog.Default(), primary, shadow, Settings{
+			SampleRate:   1,
+			MaxBodyBytes: 10,
+			Timeout:      time.Second,
+			MaxInFlight:  1,
+		}, rec.onCompare, rec.onSkip)
+
+		serve := func() {
+			defer func() {
+				assert.Equal(t, http.ErrAbortHandler, recover())
+			}()
+
+			m.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+		}
+
+		for range 2 {
+			serve()
+
+			select {
+			case <-shadowDone:
+			case <-time.After(time.Second):
+				t.Fatal("request is not mirrored")
+			}
+
+			// Slot of shadow request is released, so the next one is mirrored too.
+			assert.Eventually(t, func() bool { return len(m.inFlight) == 0 }, time.Second, time.Millisecond)
+		}
+
+		assert.Empty(t, rec.comparisons)
+		assert.Empty(t, rec.skips)
+	})
+
+	t.Run("aborted shadow response", func(t *testing.T) {
+		t.Parallel()
+
+		done := make(chan struct{})
+		backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			_, _ = w.Write([]byte("start"))
+			w.(http.Flusher).Flush()
+			<-done
+		}))
+		defer backend.Close()
+		defer close(done)
+
+		backendURL, err := url.Parse(backend.URL)
+		assert.Nil(t, err)
+
+		primary := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		})
+
+		rec := newRecorder()
+		m := New(slog.Default(), primary, httputil.NewSingleHostReverseProxy(backendURL), Settings{
+			SampleRate:   1,
+			MaxBodyBytes: 10,
+			Timeout:      50 * time.Millisecond,
+			MaxInFlight:  1,
+		}, rec.onCompare, rec.onSkip)
+
+		// Reverse proxy panics on aborted body copy only for requests of http.Server.
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req = req.WithContext(context.WithValue(req.Context(), http.ServerContextKey, &http.Server{}))
+
+		m.ServeHTTP(httptest.NewRecorder(), req)
+
+		<-rec.done
+		assert.Equal(t, []comparison{{primary: http.StatusOK, shadow: http.StatusBadGateway}}, rec.comparisons)
+	})
+
+	t.Run("zero sample rate", func(t *testing.T) {
+		t.Parallel()
+
+		shadow := http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+			t.Error("shadow must not be called")
+		})
+
+		m := New(slog.Default(), http.NotFoundHandler(), shadow, Settings{}, nil, nil)
+
+		w := httptest.NewRecorder()
+		m.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
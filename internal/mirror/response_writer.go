@@ -0,0 +1,73 @@
+package mirror
+
+import "net/http"
+
+// statusRecorder remembers status code written to the underlying ResponseWriter.
+type statusRecorder struct {
+	http.ResponseWriter
+	statusCode int
+}
+
+func (rec *statusRecorder) WriteHeader(statusCode int) {
+	if rec.statusCode == 0 {
+		rec.statusCode = statusCode
+	}
+
+	rec.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (rec *statusRecorder) Write(b []byte) (int, error) {
+	if rec.statusCode == 0 {
+		rec.statusCode = http.StatusOK
+	}
+
+	return rec.ResponseWriter.Write(b)
+}
+
+// Unwrap is used by http.ResponseController to reach the underlying ResponseWriter.
+func (rec *statusRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
+func (rec *statusRecorder) status() int {
+	if rec.statusCode == 0 {
+		return http.StatusOK
+	}
+
+	return rec.statusCode
+}
+
+// discardRecorder remembers status code of shadow response and discards its body.
+type discardRecorder struct {
+	header     http.Header
+	statusCode int
+}
+
+func (rec *discardRecorder) Header() http.Header {
+	return rec.header
+}
+
+func (rec *discardRecorder) WriteHeader(statusCode int) {
+	if rec.statusCode == 0 {
+		rec.statusCode = statusCode
+	}
+}
+
+func (rec *discardRecorder) Write(b []byte) (int, error) {
+	if rec.statusCode == 0 {
+		rec.statusCode = http.StatusOK
+	}
+
+	return len(b), nil
+}
+
+func (rec *discardRecorder) status() int {
+	if rec.statusCode == 0 {
+		return http.StatusOK
+	}
+
+	return rec.statusCode
+}
+
+// Flush does nothing, so streaming shadow responses are not interrupted.
+func (rec *discardRecorder) Flush() {}
@@ -0,0 +1,62 @@
+package ratelimit
+
+import (
+	"context"
+	"log/slog"
+	"sync/atomic"
+	"time"
+)
+
+// Fallback takes tokens from primary store and switches to fallback store when primary one fails.
+// After failure primary store is not used for retry interval, so requests are not delayed by unreachable store.
+type Fallback struct {
+	logger        *slog.Logger
+	primary       Store
+	fallback      Store
+	retryInterval time.Duration
+	// failedAt is the time of the last failure of primary store in nanoseconds, zero if it works.
+	failedAt   atomic.Int64
+	onFallback func()
+}
+
+// NewFallback creates Fallback. onFallback is called for every request handled by fallback store and may be nil.
+func NewFallback(logger *slog.Logger, primary, fallback Store, retryInterval time.Duration, onFallback func()) *Fallback {
+	return &Fallback{
+		logger:        logger,
+		primary:       primary,
+		fallback:      fallback,
+		retryInterval: retryInterval,
+		onFallback:    onFallback,
+	}
+}
+
+// Take takes token from primary store if it works, otherwise from fallback store.
+func (f *Fallback) Take(ctx context.Context, key string, bucket Bucket) (Decision, error) {
+	failedAt := f.failedAt.Load()
+	if failedAt == 0 || time.Since(time.Unix(0, failedAt)) >= f.retryInterval {
+		decision, err := f.primary.Take(ctx, key, bucket)
+		if err == nil {
+			if failedAt != 0 && f.failedAt.CompareAndSwap(failedAt, 0) {
+				f.logger.Info("Rate limit store restored")
+			}
+
+			return decision, nil
+		}
+
+		if ctx.Err() != nil {
+			return Decision{}, err
+		}
+
+		if f.failedAt.Swap(time.Now().UnixNano()) == 0 {
+			f.logger.Warn("Rate limit store failed, switch to fallback",
+				slog.String("error", err.Error()),
+			)
+		}
+	}
+
+	if f.onFallback != nil {
+		f.onFallback()
+	}
+
+	return f.fallback.Take(ctx, key, bucket)
+}
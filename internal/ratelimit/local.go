@@ -0,0 +1,92 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// localBucket is the state of the bucket in Local store.
+type localBucket struct {
+	tokens  float64
+	updated time.Time
+	bucket  Bucket
+}
+
+// refill adds tokens earned since the last update.
+func (b *localBucket) refill(now time.Time) {
+	if now.After(b.updated) {
+		b.tokens = math.Min(float64(b.bucket.Capacity), b.tokens+now.Sub(b.updated).Seconds()*b.bucket.RatePerSecond)
+		b.updated = now
+	}
+}
+
+// Local keeps token buckets in memory of the balancer. Tokens are added on access, so idle buckets cost nothing
+// but memory, which is freed by Run.
+type Local struct {
+	lock    sync.Mutex
+	buckets map[string]*localBucket
+	now     func() time.Time
+}
+
+// NewLocal creates Local store.
+func NewLocal() *Local {
+	return &Local{
+		buckets: make(map[string]*localBucket),
+		now:     time.Now,
+	}
+}
+
+// Take takes token from the bucket with given key creating full bucket if needed. It never fails.
+func (l *Local) Take(_ context.Context, key string, bucket Bucket) (Decision, error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	now := l.now()
+
+	state, ok := l.buckets[key]
+	if !ok {
+		state = &localBucket{tokens: float64(bucket.Capacity), updated: now}
+		l.buckets[key] = state
+	}
+
+	state.bucket = bucket
+	state.refill(now)
+
+	if state.tokens >= 1 {
+		state.tokens--
+		return Decision{Allowed: true}, nil
+	}
+
+	return Decision{RetryAfter: time.Duration((1 - state.tokens) / bucket.RatePerSecond * float64(time.Second))}, nil
+}
+
+// Run removes full buckets every interval, as they are the same as new ones. Should be started in separate goroutine.
+func (l *Local) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		l.cleanup()
+	}
+}
+
+func (l *Local) cleanup() {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	now := l.now()
+	for key, state := range l.buckets {
+		state.refill(now)
+		if state.tokens >= float64(state.bucket.Capacity) {
+			delete(l.buckets, key)
+		}
+	}
+}
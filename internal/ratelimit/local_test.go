@@ -0,0 +1,85 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLocal_Take(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	l := NewLocal()
+	l.now = func() time.Time { return now }
+
+	bucket := Bucket{Capacity: 2, RatePerSecond: 4}
+
+	for range 2 {
+		decision, err := l.Take(context.Background(), "A", bucket)
+		assert.Nil(t, err)
+		assert.True(t, decision.Allowed)
+	}
+
+	decision, err := l.Take(context.Background(), "A", bucket)
+	assert.Nil(t, err)
+	assert.Equal(t, Decision{RetryAfter: 250 * time.Millisecond}, decision)
+
+	// Buckets of other keys are independent.
+	decision, err = l.Take(context.Background(), "B", bucket)
+	assert.Nil(t, err)
+	assert.True(t, decision.Allowed)
+
+	now = now.Add(100 * time.Millisecond)
+
+	decision, err = l.Take(context.Background(), "A", bucket)
+	assert.Nil(t, err)
+	assert.False(t, decision.Allowed)
+	assert.InDelta(t, 150*time.Millisecond, decision.RetryAfter, float64(time.Millisecond))
+
+	now = now.Add(150 * time.Millisecond)
+
+	decision, err = l.Take(context.Background(), "A", bucket)
+	assert.Nil(t, err)
+	assert.True(t, decision.Allowed)
+
+	// Tokens do not exceed capacity.
+	now = now.Add(time.Hour)
+
+	for range 2 {
+		decision, err = l.Take(context.Background(), "A", bucket)
+		assert.Nil(t, err)
+		assert.True(t, decision.Allowed)
+	}
+
+	decision, err = l.Take(context.Background(), "A", bucket)
+	assert.Nil(t, err)
+	assert.False(t, decision.Allowed)
+}
+
+func TestLocal_cleanup(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	l := NewLocal()
+	l.now = func() time.Time { return now }
+
+	bucket := Bucket{Capacity: 2, RatePerSecond: 1}
+
+	_, _ = l.Take(context.Background(), "A", bucket)
+	_, _ = l.Take(context.Background(), "A", bucket)
+	_, _ = l.Take(context.Background(), "B", bucket)
+
+	now = now.Add(time.Second)
+	l.cleanup()
+
+	assert.Len(t, l.buckets, 1)
+	assert.Contains(t, l.buckets, "A")
+
+	now = now.Add(time.Second)
+	l.cleanup()
+
+	assert.Empty(t, l.buckets)
+}
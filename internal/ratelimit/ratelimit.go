@@ -0,0 +1,154 @@
+// ratelimit limits rate of requests from each client with token buckets kept in shared or local store.
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/AleksandrMatsko/cloudru-balancer/internal/acl"
+	"github.com/AleksandrMatsko/cloudru-balancer/internal/auth"
+	"github.com/AleksandrMatsko/cloudru-balancer/internal/errorpage"
+)
+
+// ErrCodeRateLimited means that client exceeded its rate limit.
+const ErrCodeRateLimited = "rate_limit_exceeded"
+
+// Results of rate limiting passed to callback.
+const (
+	ResultAllowed = "allowed"
+	ResultLimited = "limited"
+	// ResultError means that store failed, request is allowed.
+	ResultError = "error"
+)
+
+var errRateLimited = errors.New("rate limit exceeded")
+
+// Bucket is the token bucket of the client. Each request takes one token.
+type Bucket struct {
+	// Capacity is the maximum amount of tokens, so it is the allowed burst of requests.
+	Capacity int
+	// RatePerSecond is the amount of tokens added to the bucket every second.
+	RatePerSecond float64
+}
+
+// Decision of the store about request.
+type Decision struct {
+	// Allowed reports if token was taken from the bucket.
+	Allowed bool
+	// RetryAfter is the time until the next token is available if request is not allowed.
+	RetryAfter time.Duration
+}
+
+// Store keeps token buckets. Take must be atomic, so buckets are not overdrawn by concurrent requests.
+type Store interface {
+	// Take takes token from the bucket with given key creating full bucket if needed.
+	Take(ctx context.Context, key string, bucket Bucket) (Decision, error)
+}
+
+// Settings of Limiter.
+type Settings struct {
+	// Default bucket of clients.
+	Default Bucket
+	// Clients are buckets of particular clients by subject of authenticated client or IP address.
+	Clients map[string]Bucket
+	// KeyPrefix is added to keys of buckets in store, so limiters do not share buckets.
+	KeyPrefix string
+}
+
+// Limiter rejects requests of clients exceeding their rate limit with 429 status code.
+// Client is identified by subject if it is authenticated, otherwise by IP address.
+type Limiter struct {
+	logger     *slog.Logger
+	next       http.Handler
+	store      Store
+	settings   Settings
+	clientIP   *acl.ClientIP
+	errorPages *errorpage.Renderer
+	onResult   func(result string)
+}
+
+// New creates Limiter in front of next handler. onResult is called with result of every request and may be nil.
+func New(
+	logger *slog.Logger,
+	next http.Handler,
+	store Store,
+	settings Settings,
+	clientIP *acl.ClientIP,
+	errorPages *errorpage.Renderer,
+	onResult func(result string),
+) *Limiter {
+	return &Limiter{
+		logger:     logger,
+		next:       next,
+		store:      store,
+		settings:   settings,
+		clientIP:   clientIP,
+		errorPages: errorPages,
+		onResult:   onResult,
+	}
+}
+
+// ServeHTTP takes token from the bucket of the client and sends request to the next handler if it is allowed.
+// If store fails, request is allowed.
+func (l *Limiter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	client, key := l.client(r)
+
+	bucket, ok := l.settings.Clients[client]
+	if !ok {
+		bucket = l.settings.Default
+	}
+
+	decision, err := l.store.Take(r.Context(), l.settings.KeyPrefix+key, bucket)
+	if err != nil {
+		l.logger.Warn("Take rate limit token",
+			slog.String("client", client),
+			slog.String("error", err.Error()),
+		)
+		l.report(ResultError)
+		l.next.ServeHTTP(w, r)
+
+		return
+	}
+
+	if decision.Allowed {
+		l.report(ResultAllowed)
+		l.next.ServeHTTP(w, r)
+
+		return
+	}
+
+	l.logger.Info("Request rate limited",
+		slog.String("method", r.Method),
+		slog.String("url", r.RequestURI),
+		slog.String("client", client),
+	)
+	l.report(ResultLimited)
+
+	w.Header().Set("Retry-After", strconv.Itoa(int(max(1, math.Ceil(decision.RetryAfter.Seconds())))))
+	l.errorPages.Write(w, r, http.StatusTooManyRequests, ErrCodeRateLimited, errRateLimited)
+}
+
+// client returns client of the request and key of its bucket.
+func (l *Limiter) client(r *http.Request) (string, string) {
+	if identity, ok := auth.IdentityFromContext(r.Context()); ok && identity.Subject != "" {
+		return identity.Subject, "subject:" + identity.Subject
+	}
+
+	addr := l.clientIP.Resolve(r)
+	if !addr.IsValid() {
+		return "", "ip:unknown"
+	}
+
+	return addr.String(), "ip:" + addr.String()
+}
+
+func (l *Limiter) report(result string) {
+	if l.onResult != nil {
+		l.onResult(result)
+	}
+}
@@ -0,0 +1,112 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/AleksandrMatsko/cloudru-balancer/internal/acl"
+	"github.com/AleksandrMatsko/cloudru-balancer/internal/auth"
+	"github.com/stretchr/testify/assert"
+)
+
+type failingStore struct{}
+
+func (failingStore) Take(context.Context, string, Bucket) (Decision, error) {
+	return Decision{}, errors.New("store is unreachable")
+}
+
+func TestLimiter_ServeHTTP(t *testing.T) {
+	t.Parallel()
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	settings := Settings{
+		Default:   Bucket{Capacity: 1, RatePerSecond: 0.5},
+		Clients:   map[string]Bucket{"alice": {Capacity: 2, RatePerSecond: 0.5}},
+		KeyPrefix: "pool:",
+	}
+
+	request := func(remoteAddr, subject string) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = remoteAddr
+		if subject != "" {
+			req = req.WithContext(auth.WithIdentity(req.Context(), auth.Identity{Subject: subject, Method: auth.MethodAPIKey}))
+		}
+
+		return req
+	}
+
+	t.Run("limits clients separately", func(t *testing.T) {
+		t.Parallel()
+
+		var results []string
+		l := New(slog.Default(), ok, NewLocal(), settings, acl.NewClientIP(nil), nil, func(result string) {
+			results = append(results, result)
+		})
+
+		tests := []struct {
+			remoteAddr     string
+			subject        string
+			expectedStatus int
+		}{
+			{remoteAddr: "10.0.0.1:1234", expectedStatus: http.StatusOK},
+			{remoteAddr: "10.0.0.1:4321", expectedStatus: http.StatusTooManyRequests},
+			{remoteAddr: "10.0.0.2:1234", expectedStatus: http.StatusOK},
+			// Authenticated clients are limited by subject with their own bucket.
+			{remoteAddr: "10.0.0.1:1234", subject: "alice", expectedStatus: http.StatusOK},
+			{remoteAddr: "10.0.0.2:1234", subject: "alice", expectedStatus: http.StatusOK},
+			{remoteAddr: "10.0.0.3:1234", subject: "alice", expectedStatus: http.StatusTooManyRequests},
+			{remoteAddr: "10.0.0.3:1234", subject: "bob", expectedStatus: http.StatusOK},
+		}
+
+		for _, test := range tests {
+			rec := httptest.NewRecorder()
+			l.ServeHTTP(rec, request(test.remoteAddr, test.subject))
+
+			assert.Equal(t, test.expectedStatus, rec.Code, "%s %s", test.remoteAddr, test.subject)
+			if test.expectedStatus == http.StatusTooManyRequests {
+				assert.Equal(t, "2", rec.Header().Get("Retry-After"))
+			}
+		}
+
+		assert.Equal(t, []string{
+			ResultAllowed, ResultLimited, ResultAllowed, ResultAllowed, ResultAllowed, ResultLimited, ResultAllowed,
+		}, results)
+	})
+
+	t.Run("allows requests when store fails", func(t *testing.T) {
+		t.Parallel()
+
+		var results []string
+		l := New(slog.Default(), ok, failingStore{}, settings, acl.NewClientIP(nil), nil, func(result string) {
+			results = append(results, result)
+		})
+
+		rec := httptest.NewRecorder()
+		l.ServeHTTP(rec, request("10.0.0.1:1234", ""))
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, []string{ResultError}, results)
+	})
+
+	t.Run("uses prefix in keys", func(t *testing.T) {
+		t.Parallel()
+
+		store := NewLocal()
+		store.now = func() time.Time { return time.Unix(0, 0) }
+
+		l := New(slog.Default(), ok, store, settings, acl.NewClientIP(nil), nil, nil)
+		l.ServeHTTP(httptest.NewRecorder(), request("10.0.0.1:1234", ""))
+		l.ServeHTTP(httptest.NewRecorder(), request("10.0.0.1:1234", "alice"))
+
+		assert.Contains(t, store.buckets, "pool:ip:10.0.0.1")
+		assert.Contains(t, store.buckets, "pool:subject:alice")
+	})
+}
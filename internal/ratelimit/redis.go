@@ -0,0 +1,88 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// takeScript takes token from the bucket stored in hash with tokens and time of the last update in microseconds.
+// Time of Redis server is used, so clocks of balancers do not matter. Bucket expires when it would be full again.
+//
+// KEYS[1] is the key of the bucket, ARGV[1] is the capacity and ARGV[2] is the rate per second.
+// Result is 1 if token is taken or 0 and microseconds until the next token.
+var takeScript = redis.NewScript(`
+local capacity = tonumber(ARGV[1])
+local rate = tonumber(ARGV[2])
+
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000000 + tonumber(time[2])
+
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'updated')
+local tokens = tonumber(state[1])
+local updated = tonumber(state[2])
+if tokens == nil or updated == nil then
+	tokens = capacity
+	updated = now
+end
+
+if now > updated then
+	tokens = math.min(capacity, tokens + (now - updated) * rate / 1000000)
+	updated = now
+end
+
+local allowed = 0
+local retry = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+else
+	retry = math.ceil((1 - tokens) * 1000000 / rate)
+end
+
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'updated', tostring(updated))
+redis.call('PEXPIRE', KEYS[1], math.ceil((capacity - tokens) * 1000 / rate) + 1000)
+
+return {allowed, retry}
+`)
+
+// Redis keeps token buckets in Redis or compatible server, so balancers share them.
+// Tokens are taken atomically by Lua script.
+type Redis struct {
+	client    redis.Scripter
+	keyPrefix string
+}
+
+// NewRedis creates Redis store using given client. Key prefix is added to keys of buckets.
+func NewRedis(client redis.Scripter, keyPrefix string) *Redis {
+	return &Redis{
+		client:    client,
+		keyPrefix: keyPrefix,
+	}
+}
+
+// Take takes token from the bucket with given key creating full bucket if needed.
+func (s *Redis) Take(ctx context.Context, key string, bucket Bucket) (Decision, error) {
+	result, err := takeScript.Run(
+		ctx,
+		s.client,
+		[]string{s.keyPrefix + key},
+		bucket.Capacity,
+		strconv.FormatFloat(bucket.RatePerSecond, 'f', -1, 64),
+	).Int64Slice()
+	if err != nil {
+		return Decision{}, fmt.Errorf("run take script: %w", err)
+	}
+
+	if len(result) != 2 {
+		return Decision{}, fmt.Errorf("unexpected result of take script: %v", result)
+	}
+
+	return Decision{
+		Allowed:    result[0] == 1,
+		RetryAfter: time.Duration(result[1]) * time.Microsecond,
+	}, nil
+}
@@ -0,0 +1,109 @@
+package ratelimit
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+func newRedisClient(t *testing.T, server *miniredis.Miniredis) *redis.Client {
+	t.Helper()
+
+	client := redis.NewClient(&redis.Options{
+		Addr:          server.Addr(),
+		MaxRetries:    -1,
+		DialerRetries: 1,
+	})
+	t.Cleanup(func() {
+		_ = client.Close()
+	})
+
+	return client
+}
+
+func TestRedis_Take(t *testing.T) {
+	t.Parallel()
+
+	server := miniredis.RunT(t)
+	now := time.Now()
+	server.SetTime(now)
+
+	// Stores of two balancers share buckets.
+	first := NewRedis(newRedisClient(t, server), "prefix:")
+	second := NewRedis(newRedisClient(t, server), "prefix:")
+
+	bucket := Bucket{Capacity: 2, RatePerSecond: 4}
+
+	decision, err := first.Take(context.Background(), "A", bucket)
+	assert.Nil(t, err)
+	assert.True(t, decision.Allowed)
+
+	decision, err = second.Take(context.Background(), "A", bucket)
+	assert.Nil(t, err)
+	assert.True(t, decision.Allowed)
+
+	decision, err = first.Take(context.Background(), "A", bucket)
+	assert.Nil(t, err)
+	assert.Equal(t, Decision{RetryAfter: 250 * time.Millisecond}, decision)
+
+	decision, err = second.Take(context.Background(), "B", bucket)
+	assert.Nil(t, err)
+	assert.True(t, decision.Allowed)
+
+	server.SetTime(now.Add(100 * time.Millisecond))
+
+	decision, err = second.Take(context.Background(), "A", bucket)
+	assert.Nil(t, err)
+	assert.Equal(t, Decision{RetryAfter: 150 * time.Millisecond}, decision)
+
+	server.SetTime(now.Add(250 * time.Millisecond))
+
+	decision, err = first.Take(context.Background(), "A", bucket)
+	assert.Nil(t, err)
+	assert.True(t, decision.Allowed)
+
+	assert.Greater(t, server.TTL("prefix:A"), time.Duration(0))
+}
+
+func TestFallback_Take(t *testing.T) {
+	t.Parallel()
+
+	server := miniredis.RunT(t)
+
+	fallbacks := 0
+	store := NewFallback(slog.Default(), NewRedis(newRedisClient(t, server), "prefix:"), NewLocal(), 0, func() {
+		fallbacks++
+	})
+
+	bucket := Bucket{Capacity: 1, RatePerSecond: 0.001}
+
+	decision, err := store.Take(context.Background(), "A", bucket)
+	assert.Nil(t, err)
+	assert.True(t, decision.Allowed)
+	assert.Equal(t, 0, fallbacks)
+
+	server.Close()
+
+	// Local bucket is full, as it was not used before.
+	decision, err = store.Take(context.Background(), "A", bucket)
+	assert.Nil(t, err)
+	assert.True(t, decision.Allowed)
+
+	decision, err = store.Take(context.Background(), "A", bucket)
+	assert.Nil(t, err)
+	assert.False(t, decision.Allowed)
+	assert.Equal(t, 2, fallbacks)
+
+	assert.Nil(t, server.Restart())
+
+	// Bucket in Redis is kept after restart.
+	decision, err = store.Take(context.Background(), "A", bucket)
+	assert.Nil(t, err)
+	assert.False(t, decision.Allowed)
+	assert.Equal(t, 2, fallbacks)
+}
@@ -0,0 +1,194 @@
+// router routes requests between pools of backends.
+package router
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math/rand/v2"
+	"net/http"
+	"slices"
+	"sync/atomic"
+
+	"github.com/AleksandrMatsko/cloudru-balancer/internal/balancer"
+)
+
+// Routing contains everything Router uses to choose the pool. It may be replaced at runtime.
+type Routing struct {
+	// Rules are checked in order before splits. Request is sent to the pool of the first matching rule.
+	Rules []Rule
+	// Splits of traffic between pools. If empty, requests are sent to the default pool.
+	Splits []Split
+	// HashOn pins the client to one side of the splits.
+	HashOn HashOn
+	// DebugBackend allows to send request to the specific backend.
+	DebugBackend DebugBackend
+}
+
+// Split is the share of traffic sent to the pool.
+type Split struct {
+	// Pool name.
+	Pool string
+	// Weight of the split. Share of traffic is the weight divided by the sum of all weights.
+	Weight uint32
+}
+
+// HashOn is the key of request pinning the client to one side of the splits.
+// If both are empty or the key is absent in request, the split is chosen randomly.
+type HashOn struct {
+	// Header name to take the key from.
+	Header string
+	// Cookie name to take the key from. Used if header is not set.
+	Cookie string
+}
+
+// backendOwner is implemented by pools which can tell if backend belongs to them.
+type backendOwner interface {
+	HasBackend(backend string) bool
+}
+
+// table is immutable, so it is replaced as a whole on reload.
+type table struct {
+	rules  []Rule
+	splits []Split
+	// bounds[i] is the sum of weights of splits[0..i].
+	bounds []uint64
+	hashOn HashOn
+	debug  DebugBackend
+}
+
+// Router sends requests to pools according to rules and splits. Routing may be changed at runtime.
+type Router struct {
+	pools       map[string]http.Handler
+	poolNames   []string
+	defaultPool string
+	table       atomic.Pointer[table]
+	onRoute     func(pool string)
+}
+
+// NewRouter creates Router sending all requests to default pool until routing is set.
+// onRoute is called with the pool chosen for every request and may be nil.
+func NewRouter(pools map[string]http.Handler, defaultPool string, onRoute func(pool string)) (*Router, error) {
+	if _, ok := pools[defaultPool]; !ok {
+		return nil, fmt.Errorf("unknown default pool: %s", defaultPool)
+	}
+
+	poolNames := make([]string, 0, len(pools))
+	for name := range pools {
+		poolNames = append(poolNames, name)
+	}
+	slices.Sort(poolNames)
+
+	r := &Router{
+		pools:       pools,
+		poolNames:   poolNames,
+		defaultPool: defaultPool,
+		onRoute:     onRoute,
+	}
+	r.table.Store(&table{})
+
+	return r, nil
+}
+
+// SetRouting replaces routing. If routing is invalid, previous one remains.
+func (r *Router) SetRouting(routing Routing) error {
+	t := &table{
+		rules:  slices.Clone(routing.Rules),
+		splits: make([]Split, 0, len(routing.Splits)),
+		bounds: make([]uint64, 0, len(routing.Splits)),
+		hashOn: routing.HashOn,
+		debug:  routing.DebugBackend,
+	}
+
+	for _, rule := range t.rules {
+		if err := r.validateRule(rule); err != nil {
+			return err
+		}
+	}
+
+	var total uint64
+	for _, split := range routing.Splits {
+		if _, ok := r.pools[split.Pool]; !ok {
+			return fmt.Errorf("unknown pool in split: %s", split.Pool)
+		}
+
+		total += uint64(split.Weight)
+		t.splits = append(t.splits, split)
+		t.bounds = append(t.bounds, total)
+	}
+
+	if len(routing.Splits) != 0 && total == 0 {
+		return errors.New("sum of split weights is zero")
+	}
+
+	r.table.Store(t)
+
+	return nil
+}
+
+// ServeHTTP sends request to the chosen pool.
+func (r *Router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	t := r.table.Load()
+
+	pool, backend := r.debugBackend(t, req)
+	if backend != "" {
+		req = req.WithContext(balancer.WithBackend(req.Context(), backend))
+	} else {
+		pool = r.choosePool(t, req)
+	}
+
+	if r.onRoute != nil {
+		r.onRoute(pool)
+	}
+
+	r.pools[pool].ServeHTTP(w, req)
+}
+
+func (r *Router) choosePool(t *table, req *http.Request) string {
+	for _, rule := range t.rules {
+		if rule.matches(req) {
+			return rule.Pool
+		}
+	}
+
+	if len(t.splits) == 0 {
+		return r.defaultPool
+	}
+
+	total := t.bounds[len(t.bounds)-1]
+
+	var point uint64
+	if key, ok := t.hashOn.key(req); ok {
+		hash := fnv.New64a()
+		_, _ = hash.Write([]byte(key))
+		point = hash.Sum64() % total
+	} else {
+		point = rand.Uint64N(total)
+	}
+
+	for i, bound := range t.bounds {
+		if point < bound {
+			return t.splits[i].Pool
+		}
+	}
+
+	return r.defaultPool
+}
+
+func (h HashOn) key(req *http.Request) (string, bool) {
+	if h.Header != "" {
+		key := req.Header.Get(h.Header)
+		return key, key != ""
+	}
+
+	if h.Cookie != "" {
+		cookie, err := req.Cookie(h.Cookie)
+		if err != nil || cookie.Value == "" {
+			return "", false
+		}
+
+		return cookie.Value, true
+	}
+
+	return "", false
+}
@@ -0,0 +1,162 @@
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func poolHandler(name string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(name))
+	})
+}
+
+func newTestRouter(t *testing.T, onRoute func(pool string)) *Router {
+	t.Helper()
+
+	router, err := NewRouter(
+		map[string]http.Handler{
+			"stable": poolHandler("stable"),
+			"canary": poolHandler("canary"),
+		},
+		"stable",
+		onRoute,
+	)
+	assert.Nil(t, err)
+
+	return router
+}
+
+func serve(router *Router, req *http.Request) string {
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	return rec.Body.String()
+}
+
+func TestRouter_Splits(t *testing.T) {
+	t.Parallel()
+
+	t.Run("without splits sends to default pool", func(t *testing.T) {
+		t.Parallel()
+
+		router := newTestRouter(t, nil)
+		assert.Equal(t, "stable", serve(router, httptest.NewRequest(http.MethodGet, "/", nil)))
+	})
+
+	t.Run("splits by weight", func(t *testing.T) {
+		t.Parallel()
+
+		counts := map[string]int{}
+		router := newTestRouter(t, func(pool string) { counts[pool]++ })
+		assert.Nil(t, router.SetRouting(Routing{Splits: []Split{{Pool: "stable", Weight: 90}, {Pool: "canary", Weight: 10}}}))
+
+		const requests = 10000
+		for range requests {
+			serve(router, httptest.NewRequest(http.MethodGet, "/", nil))
+		}
+
+		assert.InDelta(t, requests*9/10, counts["stable"], requests/20)
+		assert.InDelta(t, requests/10, counts["canary"], requests/20)
+	})
+
+	t.Run("zero weight split receives nothing", func(t *testing.T) {
+		t.Parallel()
+
+		router := newTestRouter(t, nil)
+		assert.Nil(t, router.SetRouting(Routing{Splits: []Split{{Pool: "stable", Weight: 0}, {Pool: "canary", Weight: 1}}}))
+
+		for range 100 {
+			assert.Equal(t, "canary", serve(router, httptest.NewRequest(http.MethodGet, "/", nil)))
+		}
+	})
+
+	t.Run("invalid splits keep previous ones", func(t *testing.T) {
+		t.Parallel()
+
+		router := newTestRouter(t, nil)
+		assert.Nil(t, router.SetRouting(Routing{Splits: []Split{{Pool: "canary", Weight: 1}}}))
+
+		assert.NotNil(t, router.SetRouting(Routing{Splits: []Split{{Pool: "unknown", Weight: 1}}}))
+		assert.NotNil(t, router.SetRouting(Routing{Splits: []Split{{Pool: "stable", Weight: 0}}}))
+		assert.Equal(t, "canary", serve(router, httptest.NewRequest(http.MethodGet, "/", nil)))
+	})
+
+	t.Run("unknown default pool", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := NewRouter(map[string]http.Handler{}, "stable", nil)
+		assert.NotNil(t, err)
+	})
+}
+
+func TestRouter_HashOn(t *testing.T) {
+	t.Parallel()
+
+	splits := []Split{{Pool: "stable", Weight: 50}, {Pool: "canary", Weight: 50}}
+
+	t.Run("header pins user to one pool", func(t *testing.T) {
+		t.Parallel()
+
+		router := newTestRouter(t, nil)
+		assert.Nil(t, router.SetRouting(Routing{Splits: splits, HashOn: HashOn{Header: "X-User-ID"}}))
+
+		seen := map[string]bool{}
+		for user := range 100 {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("X-User-ID", strconv.Itoa(user))
+
+			pool := serve(router, req)
+			seen[pool] = true
+
+			for range 10 {
+				assert.Equal(t, pool, serve(router, req))
+			}
+		}
+
+		assert.Equal(t, map[string]bool{"stable": true, "canary": true}, seen)
+	})
+
+	t.Run("cookie pins user to one pool", func(t *testing.T) {
+		t.Parallel()
+
+		router := newTestRouter(t, nil)
+		assert.Nil(t, router.SetRouting(Routing{Splits: splits, HashOn: HashOn{Cookie: "user"}}))
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.AddCookie(&http.Cookie{Name: "user", Value: "42"})
+
+		pool := serve(router, req)
+		for range 10 {
+			assert.Equal(t, pool, serve(router, req))
+		}
+	})
+
+	t.Run("growing split moves users only to it", func(t *testing.T) {
+		t.Parallel()
+
+		router := newTestRouter(t, nil)
+		assert.Nil(t, router.SetRouting(Routing{Splits: []Split{{Pool: "canary", Weight: 5}, {Pool: "stable", Weight: 95}}, HashOn: HashOn{Header: "X-User-ID"}}))
+
+		before := map[int]string{}
+		for user := range 1000 {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("X-User-ID", strconv.Itoa(user))
+			before[user] = serve(router, req)
+		}
+
+		assert.Nil(t, router.SetRouting(Routing{Splits: []Split{{Pool: "canary", Weight: 20}, {Pool: "stable", Weight: 80}}, HashOn: HashOn{Header: "X-User-ID"}}))
+
+		for user := range 1000 {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("X-User-ID", strconv.Itoa(user))
+			if before[user] == "canary" {
+				assert.Equal(t, "canary", serve(router, req))
+			}
+		}
+	})
+}
@@ -0,0 +1,141 @@
+package router
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"slices"
+)
+
+// Source is the part of request to match.
+type Source string
+
+const (
+	// SourceHeader matches request header.
+	SourceHeader Source = "header"
+	// SourceQuery matches query parameter.
+	SourceQuery Source = "query"
+	// SourceCookie matches cookie.
+	SourceCookie Source = "cookie"
+)
+
+// Match checks single header, query parameter or cookie of request.
+type Match struct {
+	// Source of the value.
+	Source Source
+	// Name of header, query parameter or cookie.
+	Name string
+	// Value to compare with. If empty, any non-empty value matches.
+	Value string
+}
+
+// Rule sends request to the pool if all matches succeed.
+type Rule struct {
+	// Pool name.
+	Pool string
+	// Matches of the rule. Rule without matches matches every request.
+	Matches []Match
+}
+
+func (r *Router) validateRule(rule Rule) error {
+	if _, ok := r.pools[rule.Pool]; !ok {
+		return fmt.Errorf("unknown pool in rule: %s", rule.Pool)
+	}
+
+	for _, match := range rule.Matches {
+		switch match.Source {
+		case SourceHeader, SourceQuery, SourceCookie:
+		default:
+			return fmt.Errorf("unknown match source in rule for pool %s: %s", rule.Pool, match.Source)
+		}
+
+		if match.Name == "" {
+			return fmt.Errorf("match name in rule for pool %s is not set", rule.Pool)
+		}
+	}
+
+	return nil
+}
+
+func (rule Rule) matches(req *http.Request) bool {
+	for _, match := range rule.Matches {
+		if !match.matches(req) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (m Match) matches(req *http.Request) bool {
+	var value string
+
+	switch m.Source {
+	case SourceHeader:
+		value = req.Header.Get(m.Name)
+	case SourceQuery:
+		value = req.URL.Query().Get(m.Name)
+	case SourceCookie:
+		if cookie, err := req.Cookie(m.Name); err == nil {
+			value = cookie.Value
+		}
+	}
+
+	if m.Value == "" {
+		return value != ""
+	}
+
+	return value == m.Value
+}
+
+// DebugBackend allows to send request to the backend from header, like X-Debug-Backend: host:port.
+// Backend must be in allow-list or request must contain the secret.
+// Otherwise header is ignored and request is routed as usual.
+type DebugBackend struct {
+	// Header with backend address. Empty header disables debug routing.
+	Header string
+	// AllowedBackends may be requested without secret.
+	AllowedBackends []string
+	// SecretHeader contains the secret.
+	SecretHeader string
+	// Secret allowing to request any backend. Empty secret is never accepted.
+	Secret string
+}
+
+// debugBackend returns requested backend and its pool if request is allowed to choose it.
+// Debug headers are removed from request, so they do not reach backends.
+func (r *Router) debugBackend(t *table, req *http.Request) (string, string) {
+	debug := t.debug
+	if debug.Header == "" {
+		return "", ""
+	}
+
+	backend := req.Header.Get(debug.Header)
+	req.Header.Del(debug.Header)
+
+	secret := ""
+	if debug.SecretHeader != "" {
+		secret = req.Header.Get(debug.SecretHeader)
+		req.Header.Del(debug.SecretHeader)
+	}
+
+	if backend == "" {
+		return "", ""
+	}
+
+	if !slices.Contains(debug.AllowedBackends, backend) && !debug.validSecret(secret) {
+		return "", ""
+	}
+
+	for _, name := range r.poolNames {
+		if owner, ok := r.pools[name].(backendOwner); ok && owner.HasBackend(backend) {
+			return name, backend
+		}
+	}
+
+	return "", ""
+}
+
+func (d DebugBackend) validSecret(secret string) bool {
+	return d.Secret != "" && subtle.ConstantTimeCompare([]byte(secret), []byte(d.Secret)) == 1
+}
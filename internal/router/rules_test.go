@@ -0,0 +1,147 @@
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRouter_Rules(t *testing.T) {
+	t.Parallel()
+
+	router := newTestRouter(t, nil)
+	err := router.SetRouting(Routing{
+		Rules: []Rule{
+			{Pool: "canary", Matches: []Match{{Source: SourceHeader, Name: "X-Backend", Value: "canary"}}},
+			{Pool: "canary", Matches: []Match{{Source: SourceQuery, Name: "pool", Value: "canary"}}},
+			{Pool: "canary", Matches: []Match{
+				{Source: SourceCookie, Name: "beta"},
+				{Source: SourceHeader, Name: "X-Internal", Value: "true"},
+			}},
+		},
+	})
+	assert.Nil(t, err)
+
+	tests := []struct {
+		name     string
+		prepare  func(req *http.Request)
+		expected string
+	}{
+		{
+			name:     "header matches",
+			prepare:  func(req *http.Request) { req.Header.Set("X-Backend", "canary") },
+			expected: "canary",
+		},
+		{
+			name:     "header with other value",
+			prepare:  func(req *http.Request) { req.Header.Set("X-Backend", "stable") },
+			expected: "stable",
+		},
+		{
+			name:     "query matches",
+			prepare:  func(req *http.Request) { req.URL.RawQuery = "pool=canary" },
+			expected: "canary",
+		},
+		{
+			name: "all matches of rule succeed",
+			prepare: func(req *http.Request) {
+				req.AddCookie(&http.Cookie{Name: "beta", Value: "1"})
+				req.Header.Set("X-Internal", "true")
+			},
+			expected: "canary",
+		},
+		{
+			name:     "one match of rule fails",
+			prepare:  func(req *http.Request) { req.AddCookie(&http.Cookie{Name: "beta", Value: "1"}) },
+			expected: "stable",
+		},
+		{
+			name:     "no rules match",
+			prepare:  func(*http.Request) {},
+			expected: "stable",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			test.prepare(req)
+			assert.Equal(t, test.expected, serve(router, req))
+		})
+	}
+}
+
+func TestRouter_InvalidRules(t *testing.T) {
+	t.Parallel()
+
+	router := newTestRouter(t, nil)
+
+	assert.NotNil(t, router.SetRouting(Routing{Rules: []Rule{{Pool: "unknown"}}}))
+	assert.NotNil(t, router.SetRouting(Routing{Rules: []Rule{{Pool: "canary", Matches: []Match{{Source: "body", Name: "a"}}}}}))
+	assert.NotNil(t, router.SetRouting(Routing{Rules: []Rule{{Pool: "canary", Matches: []Match{{Source: SourceHeader}}}}}))
+}
+
+type ownerHandler struct {
+	backends map[string]bool
+}
+
+func (h ownerHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	_, _ = w.Write([]byte(req.Header.Get("X-Debug-Backend") + req.Header.Get("X-Debug-Secret")))
+}
+
+func (h ownerHandler) HasBackend(backend string) bool {
+	return h.backends[backend]
+}
+
+func TestRouter_DebugBackend(t *testing.T) {
+	t.Parallel()
+
+	pinned := ""
+	router, err := NewRouter(
+		map[string]http.Handler{
+			"stable": http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				_, _ = w.Write([]byte("stable"))
+			}),
+			"canary": ownerHandler{backends: map[string]bool{"canary-1:8081": true, "canary-2:8081": true}},
+		},
+		"stable",
+		func(pool string) { pinned = pool },
+	)
+	assert.Nil(t, err)
+
+	err = router.SetRouting(Routing{
+		DebugBackend: DebugBackend{
+			Header:          "X-Debug-Backend",
+			AllowedBackends: []string{"canary-1:8081"},
+			SecretHeader:    "X-Debug-Secret",
+			Secret:          "s3cret",
+		},
+	})
+	assert.Nil(t, err)
+
+	request := func(backend, secret string) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("X-Debug-Backend", backend)
+		if secret != "" {
+			req.Header.Set("X-Debug-Secret", secret)
+		}
+
+		return req
+	}
+
+	// Debug headers are removed before request reaches the pool.
+	assert.Equal(t, "", serve(router, request("canary-1:8081", "")))
+	assert.Equal(t, "canary", pinned)
+
+	assert.Equal(t, "stable", serve(router, request("canary-2:8081", "")))
+	assert.Equal(t, "stable", serve(router, request("canary-2:8081", "wrong")))
+
+	assert.Equal(t, "", serve(router, request("canary-2:8081", "s3cret")))
+	assert.Equal(t, "canary", pinned)
+
+	assert.Equal(t, "stable", serve(router, request("unknown:8081", "s3cret")))
+}
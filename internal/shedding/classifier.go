@@ -0,0 +1,95 @@
+package shedding
+
+import (
+	"net/http"
+	"net/netip"
+	"strings"
+
+	"github.com/AleksandrMatsko/cloudru-balancer/internal/acl"
+)
+
+// Rule assigns priority to matching requests. Rule matches if all of its non-empty conditions succeed.
+type Rule struct {
+	// Priority of matching requests. 0 is the highest one.
+	Priority int
+	// PathPrefix of request URL path.
+	PathPrefix string
+	// Header name.
+	Header string
+	// Value of the header. If empty, any non-empty value matches.
+	Value string
+	// Networks of clients.
+	Networks []netip.Prefix
+}
+
+func (rule Rule) matches(r *http.Request, client func() netip.Addr) bool {
+	if rule.PathPrefix != "" && !strings.HasPrefix(r.URL.Path, rule.PathPrefix) {
+		return false
+	}
+
+	if rule.Header != "" {
+		value := r.Header.Get(rule.Header)
+		if value == "" || (rule.Value != "" && value != rule.Value) {
+			return false
+		}
+	}
+
+	if len(rule.Networks) != 0 {
+		addr := client()
+		if !addr.IsValid() || !acl.ContainsAddr(rule.Networks, addr) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Classifier assigns priority to requests by rules checked in order.
+type Classifier struct {
+	rules           []Rule
+	defaultPriority int
+	clientIP        *acl.ClientIP
+}
+
+// NewClassifier creates Classifier. Requests not matching any rule get default priority.
+func NewClassifier(rules []Rule, defaultPriority int, clientIP *acl.ClientIP) *Classifier {
+	return &Classifier{
+		rules:           rules,
+		defaultPriority: defaultPriority,
+		clientIP:        clientIP,
+	}
+}
+
+// Classify returns priority of the request.
+func (c *Classifier) Classify(r *http.Request) int {
+	var (
+		addr     netip.Addr
+		resolved bool
+	)
+
+	client := func() netip.Addr {
+		if !resolved {
+			addr, resolved = c.clientIP.Resolve(r), true
+		}
+
+		return addr
+	}
+
+	for _, rule := range c.rules {
+		if rule.matches(r, client) {
+			return rule.Priority
+		}
+	}
+
+	return c.defaultPriority
+}
+
+// lowest returns the lowest priority which may be assigned.
+func (c *Classifier) lowest() int {
+	lowest := c.defaultPriority
+	for _, rule := range c.rules {
+		lowest = max(lowest, rule.Priority)
+	}
+
+	return lowest
+}
@@ -0,0 +1,122 @@
+// shedding rejects requests of lower priority first when balancer is overloaded.
+package shedding
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/AleksandrMatsko/cloudru-balancer/internal/balancer"
+	"github.com/AleksandrMatsko/cloudru-balancer/internal/errorpage"
+)
+
+// ErrCodeOverloaded means that request is shed because balancer is overloaded.
+const ErrCodeOverloaded = "overloaded"
+
+// Reasons of shedding passed to callback.
+const (
+	ReasonInFlight = "in_flight"
+	ReasonQueued   = "queued"
+)
+
+var errOverloaded = errors.New("balancer is overloaded")
+
+// Settings of Shedder.
+type Settings struct {
+	// MaxInFlight is the amount of requests in flight at which requests of the highest priority are shed.
+	// Zero disables the threshold.
+	MaxInFlight int
+	// MaxQueued is the amount of requests waiting for backends at which requests of the highest priority are shed.
+	// Zero disables the threshold.
+	MaxQueued int
+	// LowestThreshold in range (0, 1] is the share of thresholds at which requests of the lowest priority are shed.
+	// Thresholds of priorities in between are interpolated linearly.
+	LowestThreshold float64
+}
+
+// Shedder rejects requests when amount of requests in flight or waiting for backends exceeds the threshold
+// of their priority. Requests of lower priority have lower thresholds, so they are shed first.
+type Shedder struct {
+	logger     *slog.Logger
+	next       http.Handler
+	classifier *Classifier
+	settings   Settings
+	queued     func() int
+	inFlight   atomic.Int64
+	errorPages *errorpage.Renderer
+	onShed     func(priority int, reason string)
+}
+
+// New creates Shedder in front of next handler. queued returns amount of requests waiting for backends and may be nil.
+// onShed is called with priority and reason for every shed request and may be nil.
+func New(
+	logger *slog.Logger,
+	next http.Handler,
+	classifier *Classifier,
+	settings Settings,
+	queued func() int,
+	errorPages *errorpage.Renderer,
+	onShed func(priority int, reason string),
+) *Shedder {
+	return &Shedder{
+		logger:     logger,
+		next:       next,
+		classifier: classifier,
+		settings:   settings,
+		queued:     queued,
+		errorPages: errorPages,
+		onShed:     onShed,
+	}
+}
+
+// ServeHTTP rejects request with 503 status code if it is shed, otherwise sends it to the next handler.
+func (s *Shedder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	priority := s.classifier.Classify(r)
+	share := s.threshold(priority)
+
+	// Both loads do not include this request, so it is shed if load has already reached the threshold.
+	inFlight := s.inFlight.Add(1) - 1
+	defer s.inFlight.Add(-1)
+
+	reason := ""
+	if reached(int(inFlight), s.settings.MaxInFlight, share) {
+		reason = ReasonInFlight
+	} else if s.queued != nil && reached(s.queued(), s.settings.MaxQueued, share) {
+		reason = ReasonQueued
+	}
+
+	if reason == "" {
+		s.next.ServeHTTP(w, r)
+		return
+	}
+
+	s.logger.Warn("Request shed",
+		slog.String("method", r.Method),
+		slog.String("url", r.RequestURI),
+		slog.Int("priority", priority),
+		slog.String("reason", reason),
+	)
+
+	if s.onShed != nil {
+		s.onShed(priority, reason)
+	}
+
+	balancer.SetRetryAfter(w)
+	s.errorPages.Write(w, r, http.StatusServiceUnavailable, ErrCodeOverloaded, errOverloaded)
+}
+
+// threshold returns share of thresholds at which requests of given priority are shed.
+func (s *Shedder) threshold(priority int) float64 {
+	lowest := s.classifier.lowest()
+	if lowest <= 0 || priority <= 0 {
+		return 1
+	}
+
+	return 1 - (1-s.settings.LowestThreshold)*float64(min(priority, lowest))/float64(lowest)
+}
+
+// reached reports if load has reached given share of the limit. Zero limit is never reached.
+func reached(load, limit int, share float64) bool {
+	return limit > 0 && float64(load) >= share*float64(limit)
+}
@@ -0,0 +1,269 @@
+package shedding
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"sync"
+	"testing"
+
+	"github.com/AleksandrMatsko/cloudru-balancer/internal/acl"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClassifier_Classify(t *testing.T) {
+	t.Parallel()
+
+	classifier := NewClassifier([]Rule{
+		{Priority: 0, PathPrefix: "/checkout"},
+		{Priority: 0, Header: "X-Priority", Value: "critical"},
+		{Priority: 1, Networks: []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}},
+		{Priority: 3, PathPrefix: "/analytics", Header: "X-Beacon"},
+	}, 2, acl.NewClientIP(nil))
+
+	tests := []struct {
+		name             string
+		path             string
+		header           http.Header
+		remoteAddr       string
+		expectedPriority int
+	}{
+		{
+			name:             "by path",
+			path:             "/checkout/pay",
+			remoteAddr:       "203.0.113.1:1234",
+			expectedPriority: 0,
+		},
+		{
+			name:             "by header value",
+			path:             "/",
+			header:           http.Header{"X-Priority": {"critical"}},
+			remoteAddr:       "203.0.113.1:1234",
+			expectedPriority: 0,
+		},
+		{
+			name:             "header with other value",
+			path:             "/",
+			header:           http.Header{"X-Priority": {"low"}},
+			remoteAddr:       "203.0.113.1:1234",
+			expectedPriority: 2,
+		},
+		{
+			name:             "by client",
+			path:             "/",
+			remoteAddr:       "10.1.2.3:1234",
+			expectedPriority: 1,
+		},
+		{
+			name:             "all conditions match",
+			path:             "/analytics/event",
+			header:           http.Header{"X-Beacon": {"1"}},
+			remoteAddr:       "203.0.113.1:1234",
+			expectedPriority: 3,
+		},
+		{
+			name:             "not all conditions match",
+			path:             "/analytics/event",
+			remoteAddr:       "203.0.113.1:1234",
+			expectedPriority: 2,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequest(http.MethodGet, test.path, nil)
+			req.RemoteAddr = test.remoteAddr
+			for name, values := range test.header {
+				req.Header[name] = values
+			}
+
+			assert.Equal(t, test.expectedPriority, classifier.Classify(req))
+		})
+	}
+
+	assert.Equal(t, 3, classifier.lowest())
+}
+
+func TestShedder_ServeHTTP(t *testing.T) {
+	t.Parallel()
+
+	classifier := NewClassifier([]Rule{
+		{Priority: 0, PathPrefix: "/checkout"},
+		{Priority: 2, PathPrefix: "/analytics"},
+	}, 1, acl.NewClientIP(nil))
+
+	t.Run("sheds lower priority first by requests in flight", func(t *testing.T) {
+		t.Parallel()
+
+		var (
+			entered sync.WaitGroup
+			done    = make(chan struct{})
+			shed    []int
+		)
+
+		next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("X-Slow") != "" {
+				entered.Done()
+				<-done
+			}
+
+			w.WriteHeader(http.StatusOK)
+		})
+
+		s := New(slog.Default(), next, classifier, Settings{MaxInFlight: 8, LowestThreshold: 0.5}, nil, nil,
+			func(priority int, reason string) {
+				assert.Equal(t, ReasonInFlight, reason)
+				shed = append(shed, priority)
+			},
+		)
+
+		// Thresholds of priorities 0, 1 and 2 are 8, 6 and 4 requests in flight.
+		var served sync.WaitGroup
+		for range 5 {
+			entered.Add(1)
+			served.Add(1)
+
+			go func() {
+				defer served.Done()
+
+				req := httptest.NewRequest(http.MethodGet, "/checkout", nil)
+				req.Header.Set("X-Slow", "1")
+				s.ServeHTTP(httptest.NewRecorder(), req)
+			}()
+		}
+
+		entered.Wait()
+
+		statuses := make(map[string]int)
+		for _, path := range []string{"/analytics", "/", "/checkout"} {
+			rec := httptest.NewRecorder()
+			s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+			statuses[path] = rec.Code
+
+			if rec.Code == http.StatusServiceUnavailable {
+				assert.Equal(t, "1", rec.Header().Get("Retry-After"))
+			}
+		}
+
+		close(done)
+		served.Wait()
+
+		assert.Equal(t, map[string]int{
+			"/analytics": http.StatusServiceUnavailable,
+			"/":          http.StatusOK,
+			"/checkout":  http.StatusOK,
+		}, statuses)
+		assert.Equal(t, []int{2}, shed)
+
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/analytics", nil))
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+
+	t.Run("sheds when requests in flight reach threshold", func(t *testing.T) {
+		t.Parallel()
+
+		// Thresholds of priorities 0, 1 and 2 are 8, 6 and 4 requests in flight.
+		tests := []struct {
+			inFlight       int
+			path           string
+			expectedStatus int
+		}{
+			{inFlight: 3, path: "/analytics", expectedStatus: http.StatusOK},
+			{inFlight: 4, path: "/analytics", expectedStatus: http.StatusServiceUnavailable},
+			{inFlight: 5, path: "/", expectedStatus: http.StatusOK},
+			{inFlight: 6, path: "/", expectedStatus: http.StatusServiceUnavailable},
+			{inFlight: 7, path: "/checkout", expectedStatus: http.StatusOK},
+			{inFlight: 8, path: "/checkout", expectedStatus: http.StatusServiceUnavailable},
+		}
+
+		for _, test := range tests {
+			var (
+				entered sync.WaitGroup
+				served  sync.WaitGroup
+				done    = make(chan struct{})
+			)
+
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get("X-Slow") != "" {
+					entered.Done()
+					<-done
+				}
+
+				w.WriteHeader(http.StatusOK)
+			})
+
+			s := New(slog.Default(), next, classifier, Settings{MaxInFlight: 8, LowestThreshold: 0.5}, nil, nil, nil)
+
+			for range test.inFlight {
+				entered.Add(1)
+				served.Add(1)
+
+				go func() {
+					defer served.Done()
+
+					req := httptest.NewRequest(http.MethodGet, "/checkout", nil)
+					req.Header.Set("X-Slow", "1")
+					s.ServeHTTP(httptest.NewRecorder(), req)
+				}()
+			}
+
+			entered.Wait()
+
+			rec := httptest.NewRecorder()
+			s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, test.path, nil))
+
+			close(done)
+			served.Wait()
+
+			assert.Equal(t, test.expectedStatus, rec.Code, "%s with %d in flight", test.path, test.inFlight)
+		}
+	})
+
+	t.Run("sheds lower priority first by queued requests", func(t *testing.T) {
+		t.Parallel()
+
+		tests := []struct {
+			queued           int
+			expectedStatuses []int
+		}{
+			{queued: 4, expectedStatuses: []int{http.StatusOK, http.StatusOK, http.StatusOK}},
+			{queued: 5, expectedStatuses: []int{http.StatusOK, http.StatusOK, http.StatusServiceUnavailable}},
+			{queued: 8, expectedStatuses: []int{http.StatusOK, http.StatusServiceUnavailable, http.StatusServiceUnavailable}},
+			{queued: 9, expectedStatuses: []int{http.StatusOK, http.StatusServiceUnavailable, http.StatusServiceUnavailable}},
+			{queued: 10, expectedStatuses: []int{
+				http.StatusServiceUnavailable, http.StatusServiceUnavailable, http.StatusServiceUnavailable,
+			}},
+		}
+
+		next := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		})
+
+		for _, test := range tests {
+			var reasons []string
+			s := New(slog.Default(), next, classifier, Settings{MaxQueued: 10, LowestThreshold: 0.5},
+				func() int { return test.queued },
+				nil,
+				func(_ int, reason string) {
+					reasons = append(reasons, reason)
+				},
+			)
+
+			statuses := make([]int, 0, len(test.expectedStatuses))
+			for _, path := range []string{"/checkout", "/", "/analytics"} {
+				rec := httptest.NewRecorder()
+				s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+				statuses = append(statuses, rec.Code)
+			}
+
+			assert.Equal(t, test.expectedStatuses, statuses, "queued %d", test.queued)
+			for _, reason := range reasons {
+				assert.Equal(t, ReasonQueued, reason)
+			}
+		}
+	})
+}
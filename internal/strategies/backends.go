@@ -0,0 +1,287 @@
+package strategies
+
+import (
+	"math/rand/v2"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// randomAttempts is the amount of random picks of backend before looking through all of them.
+const randomAttempts = 4
+
+// snapshot is an immutable view of backends and their availability.
+// It is never modified after creation, so strategies read it without locks.
+type snapshot struct {
+	// all backends in order of addition.
+	all []string
+	// index of each backend in all.
+	index map[string]int
+	// available[i] reports availability of all[i].
+	available []bool
+	// next[i] is the index in all of the first available backend at or after i cyclically.
+	// It is -1 if there is no available backends.
+	next []int
+	// healthy contains available backends in order of addition.
+	healthy []string
+	// healthySince[i] is the time when all[i] became available.
+	healthySince []time.Time
+}
+
+func newSnapshot(all []string, health map[string]bool, since map[string]time.Time) *snapshot {
+	n := len(all)
+	s := &snapshot{
+		all:       all,
+		index:     make(map[string]int, n),
+		available: make([]bool, n),
+		next:      make([]int, n),
+		healthy:   make([]string, 0, n),
+
+		healthySince: make([]time.Time, n),
+	}
+
+	for i, backend := range all {
+		s.index[backend] = i
+		s.available[i] = health[backend]
+		s.healthySince[i] = since[backend]
+
+		if s.available[i] {
+			s.healthy = append(s.healthy, backend)
+		}
+	}
+
+	nextAvailable := -1
+	// Two passes over the ring to fill next for positions after the last available backend.
+	for i := 2*n - 1; i >= 0; i-- {
+		if s.available[i%n] {
+			nextAvailable = i % n
+		}
+
+		if i < n {
+			s.next[i] = nextAvailable
+		}
+	}
+
+	return s
+}
+
+// backendList is the set of backends with their availability shared by strategies.
+// Writers are serialized with the lock and publish new snapshot for readers.
+type backendList struct {
+	now      func() time.Time
+	lock     sync.Mutex
+	order    []string
+	health   map[string]bool
+	since    map[string]time.Time
+	snapshot atomic.Pointer[snapshot]
+}
+
+func newBackendList(backends []string) *backendList {
+	l := &backendList{
+		now:    time.Now,
+		order:  make([]string, 0, len(backends)),
+		health: make(map[string]bool, len(backends)),
+		since:  make(map[string]time.Time, len(backends)),
+	}
+
+	for _, backend := range backends {
+		if _, ok := l.health[backend]; ok {
+			continue
+		}
+
+		l.health[backend] = false
+		l.order = append(l.order, backend)
+	}
+
+	l.snapshot.Store(newSnapshot(l.order, l.health, l.since))
+
+	return l
+}
+
+// add backend as unavailable one. Does nothing if backend is already in the list.
+func (l *backendList) add(backend string) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if _, ok := l.health[backend]; ok {
+		return
+	}
+
+	l.health[backend] = false
+	l.order = append(slices.Clip(l.order), backend)
+	l.snapshot.Store(newSnapshot(l.order, l.health, l.since))
+}
+
+func (l *backendList) remove(backend string) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if _, ok := l.health[backend]; !ok {
+		return
+	}
+
+	delete(l.health, backend)
+	delete(l.since, backend)
+	l.order = slices.DeleteFunc(slices.Clone(l.order), func(b string) bool {
+		return b == backend
+	})
+	l.snapshot.Store(newSnapshot(l.order, l.health, l.since))
+}
+
+func (l *backendList) updateHealth(backend string, healthy bool) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if current, ok := l.health[backend]; !ok || current == healthy {
+		return
+	}
+
+	l.health[backend] = healthy
+	if healthy {
+		l.since[backend] = l.now()
+	} else {
+		delete(l.since, backend)
+	}
+	l.snapshot.Store(newSnapshot(l.order, l.health, l.since))
+}
+
+// chooseFrom returns the first available backend allowed by options
+// looking cyclically from the given position among all backends.
+func (l *backendList) chooseFrom(opts options, start uint64) string {
+	s := l.snapshot.Load()
+	if len(s.healthy) == 0 {
+		return ""
+	}
+
+	n := len(s.all)
+	first := s.next[start%uint64(n)]
+	now := l.now()
+
+	i := first
+	for range s.healthy {
+		backend := s.all[i]
+		if admit(opts.weight(now.Sub(s.healthySince[i]))) && opts.allow(backend) {
+			return backend
+		}
+
+		i = s.next[(i+1)%n]
+	}
+
+	if opts.slowStart.Window <= 0 {
+		return ""
+	}
+
+	// All backends are skipped because of slow start, so ignore it.
+	i = first
+	for range s.healthy {
+		backend := s.all[i]
+		if opts.allow(backend) {
+			return backend
+		}
+
+		i = s.next[(i+1)%n]
+	}
+
+	return ""
+}
+
+// chooseRandom returns random available backend allowed by options. Each allowed backend is chosen
+// with equal probability, so backends next to filtered ones do not receive their share.
+func (l *backendList) chooseRandom(opts options) string {
+	s := l.snapshot.Load()
+	if len(s.healthy) == 0 {
+		return ""
+	}
+
+	if opts.filter == nil && opts.slowStart.Window <= 0 {
+		return s.healthy[rand.IntN(len(s.healthy))]
+	}
+
+	condition := opts.allow
+	if opts.slowStart.Window > 0 {
+		now := l.now()
+		condition = func(backend string) bool {
+			return admit(opts.weight(now.Sub(s.healthySince[s.index[backend]]))) && opts.allow(backend)
+		}
+	}
+
+	// Usually most of backends are allowed, so random pick is retried before looking through all of them.
+	// Rejected picks do not change distribution of accepted ones.
+	for range randomAttempts {
+		if backend := s.healthy[rand.IntN(len(s.healthy))]; condition(backend) {
+			return backend
+		}
+	}
+
+	if backend := sample(s.healthy, condition); backend != "" || opts.slowStart.Window <= 0 {
+		return backend
+	}
+
+	// All backends are skipped because of slow start, so ignore it.
+	return sample(s.healthy, opts.allow)
+}
+
+// sample returns backend chosen uniformly among backends satisfying the condition with reservoir sampling.
+func sample(backends []string, condition func(backend string) bool) string {
+	chosen := ""
+	matched := 0
+
+	for _, backend := range backends {
+		if !condition(backend) {
+			continue
+		}
+
+		matched++
+		if rand.IntN(matched) == 0 {
+			chosen = backend
+		}
+	}
+
+	return chosen
+}
+
+// weight returns effective weight of the backend according to slow start options.
+func (l *backendList) weight(opts options, backend string) float64 {
+	if opts.slowStart.Window <= 0 {
+		return 1
+	}
+
+	s := l.snapshot.Load()
+	i, ok := s.index[backend]
+	if !ok || !s.available[i] {
+		return 1
+	}
+
+	return opts.weight(l.now().Sub(s.healthySince[i]))
+}
+
+// pickTwo returns two distinct random available backends.
+// Options are not applied, so caller must check chosen backend with options.allow.
+// If there is only one available backend, second is empty. If there is none, both are empty.
+func (l *backendList) pickTwo() (string, string) {
+	healthy := l.snapshot.Load().healthy
+
+	switch n := len(healthy); n {
+	case 0:
+		return "", ""
+	case 1:
+		return healthy[0], ""
+	default:
+		first := rand.IntN(n)
+		second := rand.IntN(n - 1)
+		if second >= first {
+			second += 1
+		}
+
+		return healthy[first], healthy[second]
+	}
+}
+
+// isAvailable reports if backend is available and allowed by options.
+func (l *backendList) isAvailable(opts options, backend string) bool {
+	s := l.snapshot.Load()
+
+	i, ok := s.index[backend]
+	return ok && s.available[i] && opts.allow(backend)
+}
@@ -0,0 +1,104 @@
+package strategies
+
+import (
+	"math"
+	"math/rand/v2"
+	"time"
+)
+
+// Filter decides if available backend may receive request right now.
+type Filter interface {
+	// Allow reports if request may be sent to the backend.
+	Allow(backend string) bool
+}
+
+// Load reports load of backends unknown to strategy, like requests waiting for the backend in queue.
+type Load interface {
+	// Load returns amount of requests waiting for the backend.
+	Load(backend string) int
+}
+
+// Option configures strategy.
+type Option func(*options)
+
+type options struct {
+	filter    Filter
+	load      Load
+	slowStart SlowStart
+}
+
+// SlowStart configures ramp of effective weight of backends which became healthy recently.
+// Newly added backends are unavailable until the first health check, so they are ramped too.
+type SlowStart struct {
+	// Window is the duration of the ramp. Zero disables slow start.
+	Window time.Duration
+	// MinWeight in range (0, 1] is the effective weight at the beginning of the ramp.
+	MinWeight float64
+	// Exponential ramp grows weight slowly at first. Otherwise ramp is linear.
+	Exponential bool
+}
+
+// WithSlowStart makes strategy send less requests to backends which became healthy recently.
+func WithSlowStart(slowStart SlowStart) Option {
+	return func(o *options) {
+		o.slowStart = slowStart
+	}
+}
+
+// WithFilter makes strategy skip available backends not allowed by the filter.
+func WithFilter(filter Filter) Option {
+	return func(o *options) {
+		o.filter = filter
+	}
+}
+
+// WithLoad makes load-aware strategy (P2C) account requests waiting for backends.
+func WithLoad(load Load) Option {
+	return func(o *options) {
+		o.load = load
+	}
+}
+
+func applyOptions(opts []Option) options {
+	o := options{}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return o
+}
+
+func (o options) allow(backend string) bool {
+	return o.filter == nil || o.filter.Allow(backend)
+}
+
+// waiting returns amount of requests waiting for the backend.
+func (o options) waiting(backend string) int {
+	if o.load == nil {
+		return 0
+	}
+
+	return o.load.Load(backend)
+}
+
+// weight returns effective weight in range (0, 1] of the backend healthy for the given time.
+func (o options) weight(healthyFor time.Duration) float64 {
+	window := o.slowStart.Window
+	if window <= 0 || healthyFor >= window {
+		return 1
+	}
+
+	minWeight := max(o.slowStart.MinWeight, math.SmallestNonzeroFloat64)
+	progress := max(float64(healthyFor), 0) / float64(window)
+
+	if o.slowStart.Exponential {
+		return math.Min(1, minWeight*math.Pow(1/minWeight, progress))
+	}
+
+	return math.Min(1, minWeight+(1-minWeight)*progress)
+}
+
+// admit randomly decides if the backend with given effective weight should receive request.
+func admit(weight float64) bool {
+	return weight >= 1 || rand.Float64() < weight
+}
@@ -0,0 +1,220 @@
+package strategies
+
+import (
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ewmaDecay is the time after which weight of observed latency in EWMA decreases e times.
+const ewmaDecay = 10 * time.Second
+
+// latencyStats is the load of the backend.
+type latencyStats struct {
+	inFlight atomic.Int64
+
+	lock        sync.Mutex
+	ewma        float64
+	lastObserve time.Time
+}
+
+// latency returns EWMA of response latency. It returns false if no response is observed yet.
+func (s *latencyStats) latency() (float64, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.ewma, !s.lastObserve.IsZero()
+}
+
+// score of the backend with given amount of waiting requests and latency, the less the better.
+func (s *latencyStats) score(waiting int, latency float64) float64 {
+	return float64(s.inFlight.Load()+int64(waiting)+1) * latency
+}
+
+// observe updates EWMA with latency of the response and returns EWMA before and after the update.
+// It also reports if this is the first observed response.
+func (s *latencyStats) observe(now time.Time, latency time.Duration) (before, after float64, first bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	before, first = s.ewma, s.lastObserve.IsZero()
+	if first {
+		s.ewma = float64(latency)
+	} else {
+		weight := math.Exp(-float64(now.Sub(s.lastObserve)) / float64(ewmaDecay))
+		s.ewma = s.ewma*weight + float64(latency)*(1-weight)
+	}
+
+	s.lastObserve = now
+
+	return before, s.ewma, first
+}
+
+// P2C is a power of two choices strategy. It samples two available backends
+// and chooses the one with lower score: (in-flight requests + waiting requests + 1) × EWMA of response latency.
+// Waiting requests are reported with WithLoad option. Backends without responses yet, like new ones,
+// are scored with mean latency of other backends, so their in-flight requests are accounted too.
+// P2C must be notified about proxied requests as balancer.ResponseObserver.
+type P2C struct {
+	backends  *backendList
+	statsLock sync.RWMutex
+	stats     map[string]*latencyStats
+	opts      options
+
+	latencyLock sync.Mutex
+	// latencySum is the sum of latency EWMA of backends with observed responses.
+	latencySum float64
+	// observed is the amount of backends with observed responses.
+	observed int
+}
+
+// NewP2C creates P2C strategy.
+func NewP2C(backends []string, opts ...Option) *P2C {
+	stats := make(map[string]*latencyStats, len(backends))
+	for _, backend := range backends {
+		stats[backend] = &latencyStats{}
+	}
+
+	return &P2C{
+		backends: newBackendList(backends),
+		stats:    stats,
+		opts:     applyOptions(opts),
+	}
+}
+
+// ChooseBackend returns backend host which is ready to receive request.
+func (p *P2C) ChooseBackend() string {
+	first, second := p.backends.pickTwo()
+	if first == "" {
+		return ""
+	}
+
+	if second != "" && p.score(second) < p.score(first) {
+		first, second = second, first
+	}
+
+	// Backend in slow start has no latency history and wins by score, so it is admitted according to its weight.
+	if second != "" && !admit(p.backends.weight(p.opts, first)) {
+		first, second = second, first
+	}
+
+	if p.opts.allow(first) {
+		return first
+	}
+
+	if second != "" && p.opts.allow(second) {
+		return second
+	}
+
+	// Both sampled backends are filtered out, so look through all of them.
+	return p.backends.chooseRandom(p.opts)
+}
+
+// IsAvailable reports if given backend is ready to receive request.
+func (p *P2C) IsAvailable(backend string) bool {
+	return p.backends.isAvailable(p.opts, backend)
+}
+
+// UpdateBackendHealth marks given backend health.
+func (p *P2C) UpdateBackendHealth(backend string, healthy bool) {
+	p.backends.updateHealth(backend, healthy)
+}
+
+// AddBackend adds unavailable backend to the strategy.
+func (p *P2C) AddBackend(backend string) {
+	p.statsLock.Lock()
+	if _, ok := p.stats[backend]; !ok {
+		p.stats[backend] = &latencyStats{}
+	}
+	p.statsLock.Unlock()
+
+	p.backends.add(backend)
+}
+
+// RemoveBackend removes backend from the strategy.
+func (p *P2C) RemoveBackend(backend string) {
+	p.backends.remove(backend)
+
+	p.statsLock.Lock()
+	defer p.statsLock.Unlock()
+
+	stats, ok := p.stats[backend]
+	if !ok {
+		return
+	}
+
+	delete(p.stats, backend)
+
+	if latency, observed := stats.latency(); observed {
+		p.latencyLock.Lock()
+		p.latencySum -= latency
+		p.observed--
+		p.latencyLock.Unlock()
+	}
+}
+
+// ObserveRequest increments amount of in-flight requests to the backend.
+func (p *P2C) ObserveRequest(backend string) {
+	if stats, ok := p.getStats(backend); ok {
+		stats.inFlight.Add(1)
+	}
+}
+
+// ObserveResponse decrements amount of in-flight requests to the backend and updates its latency.
+func (p *P2C) ObserveResponse(backend string, _ int, latency time.Duration) {
+	// Lock is held, so backend is not removed before mean latency is updated.
+	p.statsLock.RLock()
+	defer p.statsLock.RUnlock()
+
+	stats, ok := p.stats[backend]
+	if !ok {
+		return
+	}
+
+	stats.inFlight.Add(-1)
+	before, after, first := stats.observe(time.Now(), latency)
+
+	p.latencyLock.Lock()
+	defer p.latencyLock.Unlock()
+
+	if first {
+		p.observed++
+	}
+	p.latencySum += after - before
+}
+
+func (p *P2C) score(backend string) float64 {
+	stats, ok := p.getStats(backend)
+	if !ok {
+		return 0
+	}
+
+	latency, observed := stats.latency()
+	if !observed {
+		latency = p.meanLatency()
+	}
+
+	return stats.score(p.opts.waiting(backend), latency)
+}
+
+// meanLatency returns mean latency EWMA of backends with observed responses.
+// If there are no such backends, all of them have equal latency, so 1 is returned.
+func (p *P2C) meanLatency() float64 {
+	p.latencyLock.Lock()
+	defer p.latencyLock.Unlock()
+
+	if p.observed == 0 {
+		return 1
+	}
+
+	return p.latencySum / float64(p.observed)
+}
+
+func (p *P2C) getStats(backend string) (*latencyStats, bool) {
+	p.statsLock.RLock()
+	defer p.statsLock.RUnlock()
+
+	stats, ok := p.stats[backend]
+	return stats, ok
+}
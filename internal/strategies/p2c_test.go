@@ -0,0 +1,127 @@
+package strategies
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestP2C(t *testing.T) {
+	t.Run("with no available backends", func(t *testing.T) {
+		t.Parallel()
+
+		p := NewP2C([]string{"A", "B"})
+
+		assert.Equal(t, "", p.ChooseBackend())
+	})
+
+	t.Run("with 1 available backend", func(t *testing.T) {
+		t.Parallel()
+
+		p := NewP2C([]string{"A", "B", "C"})
+		p.UpdateBackendHealth("B", true)
+
+		for range 10 {
+			assert.Equal(t, "B", p.ChooseBackend())
+		}
+	})
+
+	t.Run("prefers backend with lower latency", func(t *testing.T) {
+		t.Parallel()
+
+		p := NewP2C([]string{"A", "B"})
+		p.UpdateBackendHealth("A", true)
+		p.UpdateBackendHealth("B", true)
+
+		p.ObserveRequest("A")
+		p.ObserveResponse("A", http.StatusOK, 100*time.Millisecond)
+		p.ObserveRequest("B")
+		p.ObserveResponse("B", http.StatusOK, 10*time.Millisecond)
+
+		for range 10 {
+			assert.Equal(t, "B", p.ChooseBackend())
+		}
+	})
+
+	t.Run("prefers backend with less waiting requests", func(t *testing.T) {
+		t.Parallel()
+
+		p := NewP2C([]string{"A", "B"}, WithLoad(staticLoad{"A": 3}))
+		p.UpdateBackendHealth("A", true)
+		p.UpdateBackendHealth("B", true)
+
+		p.ObserveRequest("A")
+		p.ObserveResponse("A", http.StatusOK, 10*time.Millisecond)
+		p.ObserveRequest("B")
+		p.ObserveResponse("B", http.StatusOK, 20*time.Millisecond)
+
+		for range 10 {
+			assert.Equal(t, "B", p.ChooseBackend())
+		}
+	})
+
+	t.Run("prefers backend with less in-flight requests", func(t *testing.T) {
+		t.Parallel()
+
+		p := NewP2C([]string{"A", "B"})
+		p.UpdateBackendHealth("A", true)
+		p.UpdateBackendHealth("B", true)
+
+		p.ObserveRequest("A")
+		p.ObserveResponse("A", http.StatusOK, 10*time.Millisecond)
+		p.ObserveRequest("B")
+		p.ObserveResponse("B", http.StatusOK, 10*time.Millisecond)
+
+		for range 5 {
+			p.ObserveRequest("B")
+		}
+
+		for range 10 {
+			assert.Equal(t, "A", p.ChooseBackend())
+		}
+	})
+
+	t.Run("accounts in-flight requests of backend without responses", func(t *testing.T) {
+		t.Parallel()
+
+		p := NewP2C([]string{"A", "B"})
+		p.UpdateBackendHealth("A", true)
+		p.UpdateBackendHealth("B", true)
+
+		p.ObserveRequest("A")
+		p.ObserveResponse("A", http.StatusOK, 10*time.Millisecond)
+
+		// B is new, so it has no latency history yet.
+		for range 5 {
+			p.ObserveRequest("B")
+		}
+
+		for range 10 {
+			assert.Equal(t, "A", p.ChooseBackend())
+		}
+
+		p.RemoveBackend("A")
+		assert.Equal(t, float64(1), p.meanLatency())
+	})
+
+	t.Run("skips filtered backend", func(t *testing.T) {
+		t.Parallel()
+
+		p := NewP2C([]string{"A", "B", "C"}, WithFilter(denyFilter{"A": true, "B": true}))
+		p.UpdateBackendHealth("A", true)
+		p.UpdateBackendHealth("B", true)
+		p.UpdateBackendHealth("C", true)
+
+		for range 10 {
+			assert.Equal(t, "C", p.ChooseBackend())
+		}
+	})
+}
+
+type staticLoad map[string]int
+
+func (l staticLoad) Load(backend string) int {
+	return l[backend]
+}
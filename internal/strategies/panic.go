@@ -0,0 +1,137 @@
+package strategies
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Panic is a strategy wrapper that ignores backend health when too few backends are healthy.
+// When the fraction of healthy backends drops below threshold, requests are spread across all backends.
+type Panic struct {
+	threshold float64
+	onChange  func(inPanic bool, healthy, total int)
+
+	// lock serializes updates, so onChange is called in order.
+	lock     sync.Mutex
+	backends *backendList
+	normal   Strategy
+	all      Strategy
+	inPanic  atomic.Bool
+	// checked is true after the first health update. Before it health of backends is unknown, so panic mode is off.
+	checked bool
+}
+
+// NewPanic creates Panic with inner strategies for given backends. Strategy normal is used with real health
+// of backends. Strategy all is used in panic mode with all backends marked healthy, so it must not prefer
+// any of them, like priority groups do. Function onChange, if not nil, is called when panic mode changes.
+func NewPanic(
+	backends []string,
+	threshold float64,
+	normal Strategy,
+	all Strategy,
+	onChange func(inPanic bool, healthy, total int),
+) *Panic {
+	for _, backend := range backends {
+		all.UpdateBackendHealth(backend, true)
+	}
+
+	return &Panic{
+		threshold: threshold,
+		onChange:  onChange,
+		backends:  newBackendList(backends),
+		normal:    normal,
+		all:       all,
+	}
+}
+
+// InPanic reports if the strategy ignores health of backends now.
+func (p *Panic) InPanic() bool {
+	return p.inPanic.Load()
+}
+
+// ChooseBackend returns backend host which is ready to receive request.
+// In panic mode backend is chosen from all backends.
+func (p *Panic) ChooseBackend() string {
+	if p.inPanic.Load() {
+		return p.all.ChooseBackend()
+	}
+
+	return p.normal.ChooseBackend()
+}
+
+// IsAvailable reports if given backend is ready to receive request.
+func (p *Panic) IsAvailable(backend string) bool {
+	if p.inPanic.Load() {
+		return p.all.IsAvailable(backend)
+	}
+
+	return p.normal.IsAvailable(backend)
+}
+
+// UpdateBackendHealth marks given backend health.
+func (p *Panic) UpdateBackendHealth(backend string, healthy bool) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.backends.updateHealth(backend, healthy)
+	p.normal.UpdateBackendHealth(backend, healthy)
+	p.checked = true
+	p.refresh()
+}
+
+// AddBackend adds unavailable backend to the strategy.
+func (p *Panic) AddBackend(backend string) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.backends.add(backend)
+	p.normal.AddBackend(backend)
+	p.all.AddBackend(backend)
+	p.all.UpdateBackendHealth(backend, true)
+	p.refresh()
+}
+
+// RemoveBackend removes backend from the strategy.
+func (p *Panic) RemoveBackend(backend string) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.normal.RemoveBackend(backend)
+	p.all.RemoveBackend(backend)
+	p.backends.remove(backend)
+	p.refresh()
+}
+
+// ObserveRequest passes request to inner strategies if they are observers.
+func (p *Panic) ObserveRequest(backend string) {
+	for _, strategy := range []Strategy{p.normal, p.all} {
+		if observer, ok := strategy.(responseObserver); ok {
+			observer.ObserveRequest(backend)
+		}
+	}
+}
+
+// ObserveResponse passes response to inner strategies if they are observers.
+func (p *Panic) ObserveResponse(backend string, statusCode int, latency time.Duration) {
+	for _, strategy := range []Strategy{p.normal, p.all} {
+		if observer, ok := strategy.(responseObserver); ok {
+			observer.ObserveResponse(backend, statusCode, latency)
+		}
+	}
+}
+
+// refresh switches panic mode according to the fraction of healthy backends. Must be called under lock.
+func (p *Panic) refresh() {
+	if !p.checked {
+		return
+	}
+
+	s := p.backends.snapshot.Load()
+	healthy, total := len(s.healthy), len(s.all)
+
+	inPanic := total > 0 && float64(healthy)/float64(total) < p.threshold
+	if p.inPanic.Swap(inPanic) != inPanic && p.onChange != nil {
+		p.onChange(inPanic, healthy, total)
+	}
+}
@@ -0,0 +1,74 @@
+package strategies
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPanic(t *testing.T) {
+	changes := []bool{}
+
+	backends := []string{"A", "B", "C", "D"}
+	p := NewPanic(
+		backends,
+		0.5,
+		NewRoundRobin(backends),
+		NewRoundRobin(backends),
+		func(inPanic bool, _, _ int) { changes = append(changes, inPanic) },
+	)
+
+	// Health of backends is unknown until the first health check.
+	assert.False(t, p.InPanic())
+	assert.Equal(t, "", p.ChooseBackend())
+
+	p.UpdateBackendHealth("A", true)
+
+	assert.True(t, p.InPanic())
+	assert.Equal(t, "A", p.ChooseBackend())
+	assert.Equal(t, "B", p.ChooseBackend())
+
+	p.UpdateBackendHealth("B", true)
+
+	assert.False(t, p.InPanic())
+	for range 4 {
+		assert.Contains(t, []string{"A", "B"}, p.ChooseBackend())
+	}
+	assert.False(t, p.IsAvailable("C"))
+
+	p.UpdateBackendHealth("B", false)
+
+	assert.True(t, p.InPanic())
+	assert.True(t, p.IsAvailable("C"))
+	assert.ElementsMatch(t, []string{"A", "B", "C", "D"}, []string{
+		p.ChooseBackend(), p.ChooseBackend(), p.ChooseBackend(), p.ChooseBackend(),
+	})
+
+	p.RemoveBackend("D")
+	p.RemoveBackend("C")
+
+	assert.False(t, p.InPanic())
+	assert.Equal(t, []bool{true, false, true, false}, changes)
+}
+
+func TestPanic_withPriorities(t *testing.T) {
+	backends := []string{"A", "B", "C", "D"}
+	priorities := map[string]uint32{"A": 0, "B": 0, "C": 1, "D": 1}
+	newStrategy := func(backends []string) Strategy { return NewRoundRobin(backends) }
+
+	p := NewPanic(
+		backends,
+		0.5,
+		NewPriority(backends, priorities, 1, newStrategy),
+		newStrategy(backends),
+		nil,
+	)
+
+	p.UpdateBackendHealth("A", true)
+	assert.True(t, p.InPanic())
+
+	// Requests are spread across all backends, not only across the first priority group.
+	assert.ElementsMatch(t, backends, []string{
+		p.ChooseBackend(), p.ChooseBackend(), p.ChooseBackend(), p.ChooseBackend(),
+	})
+}
@@ -0,0 +1,211 @@
+package strategies
+
+import (
+	"cmp"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Strategy is implemented by all strategies of the package.
+type Strategy interface {
+	// ChooseBackend returns backend host which is ready to receive request.
+	ChooseBackend() string
+	// IsAvailable reports if given backend is ready to receive request.
+	IsAvailable(backend string) bool
+	// UpdateBackendHealth marks given backend health.
+	UpdateBackendHealth(backend string, healthy bool)
+	// AddBackend adds unavailable backend to the strategy.
+	AddBackend(backend string)
+	// RemoveBackend removes backend from the strategy.
+	RemoveBackend(backend string)
+}
+
+// responseObserver is the same as balancer.ResponseObserver.
+type responseObserver interface {
+	ObserveRequest(backend string)
+	ObserveResponse(backend string, statusCode int, latency time.Duration)
+}
+
+type priorityTier struct {
+	priority uint32
+	backends *backendList
+	strategy Strategy
+}
+
+func (tier *priorityTier) healthy() int {
+	return len(tier.backends.snapshot.Load().healthy)
+}
+
+// Priority is a strategy wrapper for failover between groups of backends with different priorities.
+// Inner strategy is applied to the group with the highest priority having at least minHealthy healthy backends.
+// If there is no such group, the group with the highest priority having any healthy backend is used.
+// Priority 0 is the highest one. Backends added with AddBackend get priority 0.
+type Priority struct {
+	newStrategy func(backends []string) Strategy
+	minHealthy  int
+
+	lock       sync.RWMutex
+	tiers      []*priorityTier
+	priorities map[string]uint32
+}
+
+// NewPriority creates Priority. Function newStrategy creates inner strategy for each priority group.
+func NewPriority(
+	backends []string,
+	priorities map[string]uint32,
+	minHealthy uint32,
+	newStrategy func(backends []string) Strategy,
+) *Priority {
+	grouped := make(map[uint32][]string)
+	for _, backend := range backends {
+		grouped[priorities[backend]] = append(grouped[priorities[backend]], backend)
+	}
+
+	p := &Priority{
+		newStrategy: newStrategy,
+		minHealthy:  int(minHealthy),
+		priorities:  make(map[string]uint32, len(backends)),
+	}
+
+	for priority, tierBackends := range grouped {
+		p.tiers = append(p.tiers, p.newTier(priority, tierBackends))
+		for _, backend := range tierBackends {
+			p.priorities[backend] = priority
+		}
+	}
+
+	slices.SortFunc(p.tiers, func(a, b *priorityTier) int {
+		return cmp.Compare(a.priority, b.priority)
+	})
+
+	return p
+}
+
+func (p *Priority) newTier(priority uint32, backends []string) *priorityTier {
+	return &priorityTier{
+		priority: priority,
+		backends: newBackendList(backends),
+		strategy: p.newStrategy(backends),
+	}
+}
+
+// ChooseBackend returns backend host which is ready to receive request.
+func (p *Priority) ChooseBackend() string {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	for _, tier := range p.tiers {
+		if tier.healthy() < p.minHealthy {
+			continue
+		}
+
+		if backend := tier.strategy.ChooseBackend(); backend != "" {
+			return backend
+		}
+	}
+
+	// No group has enough healthy backends, so use any of them.
+	for _, tier := range p.tiers {
+		if tier.healthy() >= p.minHealthy {
+			continue
+		}
+
+		if backend := tier.strategy.ChooseBackend(); backend != "" {
+			return backend
+		}
+	}
+
+	return ""
+}
+
+// IsAvailable reports if given backend is ready to receive request.
+func (p *Priority) IsAvailable(backend string) bool {
+	tier, ok := p.tierOf(backend)
+	return ok && tier.strategy.IsAvailable(backend)
+}
+
+// UpdateBackendHealth marks given backend health.
+func (p *Priority) UpdateBackendHealth(backend string, healthy bool) {
+	if tier, ok := p.tierOf(backend); ok {
+		tier.backends.updateHealth(backend, healthy)
+		tier.strategy.UpdateBackendHealth(backend, healthy)
+	}
+}
+
+// AddBackend adds unavailable backend with priority 0 to the strategy.
+func (p *Priority) AddBackend(backend string) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if _, ok := p.priorities[backend]; ok {
+		return
+	}
+
+	p.priorities[backend] = 0
+
+	if len(p.tiers) > 0 && p.tiers[0].priority == 0 {
+		p.tiers[0].backends.add(backend)
+		p.tiers[0].strategy.AddBackend(backend)
+		return
+	}
+
+	p.tiers = slices.Insert(p.tiers, 0, p.newTier(0, []string{backend}))
+}
+
+// RemoveBackend removes backend from the strategy.
+func (p *Priority) RemoveBackend(backend string) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	priority, ok := p.priorities[backend]
+	if !ok {
+		return
+	}
+
+	delete(p.priorities, backend)
+
+	for _, tier := range p.tiers {
+		if tier.priority == priority {
+			tier.strategy.RemoveBackend(backend)
+			tier.backends.remove(backend)
+			return
+		}
+	}
+}
+
+// ObserveRequest passes request to inner strategy if it is observer.
+func (p *Priority) ObserveRequest(backend string) {
+	if tier, ok := p.tierOf(backend); ok {
+		if observer, ok := tier.strategy.(responseObserver); ok {
+			observer.ObserveRequest(backend)
+		}
+	}
+}
+
+// ObserveResponse passes response to inner strategy if it is observer.
+func (p *Priority) ObserveResponse(backend string, statusCode int, latency time.Duration) {
+	if tier, ok := p.tierOf(backend); ok {
+		if observer, ok := tier.strategy.(responseObserver); ok {
+			observer.ObserveResponse(backend, statusCode, latency)
+		}
+	}
+}
+
+func (p *Priority) tierOf(backend string) (*priorityTier, bool) {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	priority, ok := p.priorities[backend]
+	if !ok {
+		return nil, false
+	}
+
+	for _, tier := range p.tiers {
+		if tier.priority == priority {
+			return tier, true
+		}
+	}
+
+	return nil, false
+}
@@ -0,0 +1,91 @@
+package strategies
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestPriority(minHealthy uint32) *Priority {
+	return NewPriority(
+		[]string{"A1", "A2", "B1", "B2", "C1"},
+		map[string]uint32{"A1": 0, "A2": 0, "B1": 1, "B2": 1, "C1": 2},
+		minHealthy,
+		func(backends []string) Strategy { return NewRoundRobin(backends) },
+	)
+}
+
+func TestPriority(t *testing.T) {
+	t.Run("uses the highest priority group", func(t *testing.T) {
+		t.Parallel()
+
+		p := newTestPriority(1)
+		for _, backend := range []string{"A1", "A2", "B1", "B2", "C1"} {
+			p.UpdateBackendHealth(backend, true)
+		}
+
+		assert.Equal(t, "A1", p.ChooseBackend())
+		assert.Equal(t, "A2", p.ChooseBackend())
+		assert.Equal(t, "A1", p.ChooseBackend())
+	})
+
+	t.Run("spills over when not enough healthy backends", func(t *testing.T) {
+		t.Parallel()
+
+		p := newTestPriority(2)
+		for _, backend := range []string{"A1", "B1", "B2", "C1"} {
+			p.UpdateBackendHealth(backend, true)
+		}
+
+		assert.Equal(t, "B1", p.ChooseBackend())
+		assert.Equal(t, "B2", p.ChooseBackend())
+
+		p.UpdateBackendHealth("A2", true)
+
+		assert.Equal(t, "A1", p.ChooseBackend())
+		assert.Equal(t, "A2", p.ChooseBackend())
+	})
+
+	t.Run("uses any healthy backend when no group has enough", func(t *testing.T) {
+		t.Parallel()
+
+		p := newTestPriority(2)
+		p.UpdateBackendHealth("B1", true)
+		p.UpdateBackendHealth("C1", true)
+
+		assert.Equal(t, "B1", p.ChooseBackend())
+		assert.True(t, p.IsAvailable("C1"))
+		assert.False(t, p.IsAvailable("A1"))
+	})
+
+	t.Run("with no healthy backends", func(t *testing.T) {
+		t.Parallel()
+
+		p := newTestPriority(1)
+
+		assert.Equal(t, "", p.ChooseBackend())
+	})
+
+	t.Run("adds backends with the highest priority", func(t *testing.T) {
+		t.Parallel()
+
+		p := NewPriority(
+			[]string{"B1"},
+			map[string]uint32{"B1": 1},
+			1,
+			func(backends []string) Strategy { return NewRoundRobin(backends) },
+		)
+		p.UpdateBackendHealth("B1", true)
+
+		assert.Equal(t, "B1", p.ChooseBackend())
+
+		p.AddBackend("A1")
+		p.UpdateBackendHealth("A1", true)
+
+		assert.Equal(t, "A1", p.ChooseBackend())
+
+		p.RemoveBackend("A1")
+
+		assert.Equal(t, "B1", p.ChooseBackend())
+	})
+}
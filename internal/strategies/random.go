@@ -1,53 +1,40 @@
 package strategies
 
-import (
-	"math/rand"
-	"sync"
-)
-
 // Random strategy for balancing requests to backends.
 type Random struct {
-	backendAvailable map[string]*backendState
-	backends         []string
+	backends *backendList
+	opts     options
 }
 
 // NewRandom creates new Random strategy.
-func NewRandom(backends []string) *Random {
-	backendAvailable := make(map[string]*backendState, len(backends))
-	for _, backend := range backends {
-		backendAvailable[backend] = &backendState{
-			rwLock:    &sync.RWMutex{},
-			available: false,
-		}
-	}
-
+func NewRandom(backends []string, opts ...Option) *Random {
 	return &Random{
-		backendAvailable: backendAvailable,
-		backends:         backends,
+		backends: newBackendList(backends),
+		opts:     applyOptions(opts),
 	}
 }
 
 // ChooseBackend returns backend host which is ready to receive request.
 func (r *Random) ChooseBackend() string {
-	order := rand.Perm(len(r.backends))
-
-	for _, i := range order {
-		candidate := r.backends[i]
-		state := r.backendAvailable[candidate]
-
-		state.rwLock.RLock()
-		available := state.available
-		state.rwLock.RUnlock()
-
-		if available {
-			return candidate
-		}
-	}
+	return r.backends.chooseRandom(r.opts)
+}
 
-	return ""
+// IsAvailable reports if given backend is ready to receive request.
+func (r *Random) IsAvailable(backend string) bool {
+	return r.backends.isAvailable(r.opts, backend)
 }
 
 // UpdateBackendHealth marks given backend health.
 func (r *Random) UpdateBackendHealth(backend string, healthy bool) {
-	updateBackendHeath(r.backendAvailable, backend, healthy)
+	r.backends.updateHealth(backend, healthy)
+}
+
+// AddBackend adds unavailable backend to the strategy.
+func (r *Random) AddBackend(backend string) {
+	r.backends.add(backend)
+}
+
+// RemoveBackend removes backend from the strategy.
+func (r *Random) RemoveBackend(backend string) {
+	r.backends.remove(backend)
 }
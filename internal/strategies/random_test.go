@@ -0,0 +1,32 @@
+package strategies
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRandom_WithFilter(t *testing.T) {
+	t.Parallel()
+
+	const picks = 30000
+
+	backends := []string{"A", "B", "C", "D"}
+	r := NewRandom(backends, WithFilter(denyFilter{"B": true}))
+
+	for _, backend := range backends {
+		r.UpdateBackendHealth(backend, true)
+	}
+
+	counts := make(map[string]int, len(backends))
+	for range picks {
+		counts[r.ChooseBackend()]++
+	}
+
+	assert.Zero(t, counts["B"])
+
+	// Backend next to the filtered one does not receive its share.
+	for _, backend := range []string{"A", "C", "D"} {
+		assert.InDelta(t, picks/3, counts[backend], picks*0.03, backend)
+	}
+}
@@ -1,80 +1,47 @@
 package strategies
 
 import (
-	"sync"
+	"sync/atomic"
 )
 
-type backendState struct {
-	rwLock    *sync.RWMutex
-	available bool
-}
-
 // RoundRobin is a cyclic balancer strategy.
 type RoundRobin struct {
-	backendAvailable map[string]*backendState
-	allBackends      []string
-	indexLock        sync.Locker
-	startIndex       int
+	backends   *backendList
+	startIndex atomic.Uint64
+	opts       options
 }
 
 // NewRoundRobin creates RoundRobin.
-func NewRoundRobin(backends []string) *RoundRobin {
-	availabilityMap := make(map[string]*backendState)
-	for i := range backends {
-		availabilityMap[backends[i]] = &backendState{
-			rwLock:    &sync.RWMutex{},
-			available: false,
-		}
-	}
-
+func NewRoundRobin(backends []string, opts ...Option) *RoundRobin {
 	return &RoundRobin{
-		backendAvailable: availabilityMap,
-		allBackends:      backends,
-		indexLock:        &sync.Mutex{},
-		startIndex:       0,
+		backends: newBackendList(backends),
+		opts:     applyOptions(opts),
 	}
 }
 
 // ChooseBackend returns backend host which is ready to receive request.
 func (rr *RoundRobin) ChooseBackend() string {
-	rr.indexLock.Lock()
-	startIndex := rr.startIndex
-	rr.startIndex += 1
-	rr.indexLock.Unlock()
-
-	for i := range rr.allBackends {
-		candidate := rr.allBackends[(startIndex+i)%len(rr.allBackends)]
-		state := rr.backendAvailable[candidate]
-
-		state.rwLock.RLock()
-		available := state.available
-		state.rwLock.RUnlock()
+	startIndex := rr.startIndex.Add(1) - 1
 
-		if available {
-			return candidate
-		}
-	}
+	return rr.backends.chooseFrom(rr.opts, startIndex)
+}
 
-	return ""
+// IsAvailable reports if given backend is ready to receive request.
+func (rr *RoundRobin) IsAvailable(backend string) bool {
+	return rr.backends.isAvailable(rr.opts, backend)
 }
 
 // UpdateBackendHealth marks given backend health.
 func (rr *RoundRobin) UpdateBackendHealth(backend string, healthy bool) {
-	updateBackendHeath(rr.backendAvailable, backend, healthy)
+	rr.backends.updateHealth(backend, healthy)
 }
 
-func updateBackendHeath(backendAvailable map[string]*backendState, backend string, healthy bool) {
-	if state, ok := backendAvailable[backend]; ok {
-		state.rwLock.RLock()
-		same := state.available == healthy
-		state.rwLock.RUnlock()
-
-		if same {
-			return
-		}
+// AddBackend adds unavailable backend to the strategy.
+func (rr *RoundRobin) AddBackend(backend string) {
+	rr.backends.add(backend)
+}
 
-		state.rwLock.Lock()
-		state.available = healthy
-		state.rwLock.Unlock()
-	}
+// RemoveBackend removes backend from the strategy.
+func (rr *RoundRobin) RemoveBackend(backend string) {
+	rr.backends.remove(backend)
 }
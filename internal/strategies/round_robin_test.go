@@ -68,3 +68,65 @@ func TestRoundRobin(t *testing.T) {
 		assert.Equal(t, "B", rr.ChooseBackend())
 	})
 }
+
+type denyFilter map[string]bool
+
+func (f denyFilter) Allow(backend string) bool {
+	return !f[backend]
+}
+
+func TestRoundRobin_WithFilter(t *testing.T) {
+	backends := []string{"A", "B", "C"}
+
+	rr := NewRoundRobin(backends, WithFilter(denyFilter{"B": true}))
+
+	rr.UpdateBackendHealth("A", true)
+	rr.UpdateBackendHealth("B", true)
+	rr.UpdateBackendHealth("C", true)
+
+	assert.Equal(t, "A", rr.ChooseBackend())
+	assert.Equal(t, "C", rr.ChooseBackend())
+	assert.Equal(t, "C", rr.ChooseBackend())
+	assert.Equal(t, "A", rr.ChooseBackend())
+}
+
+func TestRoundRobin_AddRemoveBackend(t *testing.T) {
+	rr := NewRoundRobin([]string{"A"})
+
+	rr.UpdateBackendHealth("A", true)
+	rr.AddBackend("B")
+
+	assert.Equal(t, "A", rr.ChooseBackend())
+	assert.Equal(t, "A", rr.ChooseBackend())
+
+	rr.UpdateBackendHealth("B", true)
+	rr.RemoveBackend("A")
+
+	assert.Equal(t, "B", rr.ChooseBackend())
+	assert.Equal(t, "B", rr.ChooseBackend())
+
+	rr.RemoveBackend("B")
+
+	assert.Equal(t, "", rr.ChooseBackend())
+}
+
+func TestRoundRobin_Concurrent(t *testing.T) {
+	backends := []string{"A", "B", "C"}
+	rr := NewRoundRobin(backends)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		for i := range 1000 {
+			rr.UpdateBackendHealth(backends[i%len(backends)], i%2 == 0)
+		}
+	}()
+
+	for range 1000 {
+		backend := rr.ChooseBackend()
+		assert.Contains(t, []string{"", "A", "B", "C"}, backend)
+	}
+
+	<-done
+}
@@ -0,0 +1,118 @@
+package strategies
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSlowStart_Weight(t *testing.T) {
+	t.Parallel()
+
+	linear := options{slowStart: SlowStart{Window: 10 * time.Second, MinWeight: 0.1}}
+	exponential := options{slowStart: SlowStart{Window: 10 * time.Second, MinWeight: 0.01, Exponential: true}}
+
+	t.Run("linear", func(t *testing.T) {
+		t.Parallel()
+
+		assert.InDelta(t, 0.1, linear.weight(0), 1e-9)
+		assert.InDelta(t, 0.55, linear.weight(5*time.Second), 1e-9)
+		assert.InDelta(t, 1.0, linear.weight(10*time.Second), 1e-9)
+		assert.InDelta(t, 1.0, linear.weight(time.Minute), 1e-9)
+	})
+
+	t.Run("exponential", func(t *testing.T) {
+		t.Parallel()
+
+		assert.InDelta(t, 0.01, exponential.weight(0), 1e-9)
+		assert.InDelta(t, 0.1, exponential.weight(5*time.Second), 1e-9)
+		assert.InDelta(t, 1.0, exponential.weight(10*time.Second), 1e-9)
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		t.Parallel()
+
+		assert.InDelta(t, 1.0, options{}.weight(0), 1e-9)
+	})
+}
+
+func TestSlowStart_RoundRobin(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	rr := NewRoundRobin([]string{"A", "B"}, WithSlowStart(SlowStart{Window: time.Minute, MinWeight: 0.1}))
+	rr.backends.now = func() time.Time { return now }
+
+	rr.UpdateBackendHealth("A", true)
+	now = now.Add(time.Minute)
+	rr.UpdateBackendHealth("B", true)
+
+	const requests = 1000
+
+	counts := map[string]int{}
+	for range requests {
+		counts[rr.ChooseBackend()]++
+	}
+
+	assert.Greater(t, counts["A"], requests*3/4)
+	assert.Positive(t, counts["B"])
+
+	now = now.Add(time.Minute)
+
+	counts = map[string]int{}
+	for range requests {
+		counts[rr.ChooseBackend()]++
+	}
+
+	assert.Equal(t, map[string]int{"A": requests / 2, "B": requests / 2}, counts)
+}
+
+func TestSlowStart_AllRamping(t *testing.T) {
+	t.Parallel()
+
+	slowStart := WithSlowStart(SlowStart{Window: time.Hour, MinWeight: 0.001})
+	strategies := map[string]Strategy{
+		"RoundRobin": NewRoundRobin([]string{"A"}, slowStart),
+		"Random":     NewRandom([]string{"A"}, slowStart),
+		"P2C":        NewP2C([]string{"A"}, slowStart),
+	}
+
+	for name, strategy := range strategies {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			strategy.UpdateBackendHealth("A", true)
+
+			for range 100 {
+				assert.Equal(t, "A", strategy.ChooseBackend())
+			}
+		})
+	}
+}
+
+func TestSlowStart_P2C(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	p := NewP2C([]string{"A", "B"}, WithSlowStart(SlowStart{Window: time.Minute, MinWeight: 0.1}))
+	p.backends.now = func() time.Time { return now }
+
+	p.UpdateBackendHealth("A", true)
+	p.ObserveRequest("A")
+	p.ObserveResponse("A", 200, 100*time.Millisecond)
+
+	now = now.Add(time.Minute)
+	p.UpdateBackendHealth("B", true)
+
+	const requests = 1000
+
+	counts := map[string]int{}
+	for range requests {
+		counts[p.ChooseBackend()]++
+	}
+
+	// B has no latency yet and wins by score, but receives only about min weight share of traffic.
+	assert.Greater(t, counts["A"], requests*3/4)
+	assert.Positive(t, counts["B"])
+}
@@ -0,0 +1,182 @@
+package strategies
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/AleksandrMatsko/cloudru-balancer/internal/balancer"
+)
+
+type benchStrategy interface {
+	balancer.Strategy
+	UpdateBackendHealth(backend string, healthy bool)
+}
+
+func benchmarkBackends(n int) []string {
+	backends := make([]string, 0, n)
+	for i := range n {
+		backends = append(backends, fmt.Sprintf("backend-%d:8081", i))
+	}
+
+	return backends
+}
+
+func benchmarkStrategies(b *testing.B, create func(backends []string) benchStrategy) {
+	for _, n := range []int{10, 100, 1000} {
+		b.Run(fmt.Sprintf("backends=%d", n), func(b *testing.B) {
+			backends := benchmarkBackends(n)
+			strategy := create(backends)
+
+			// Every second backend is unavailable.
+			for i, backend := range backends {
+				strategy.UpdateBackendHealth(backend, i%2 == 0)
+			}
+
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					_ = strategy.ChooseBackend()
+				}
+			})
+		})
+	}
+}
+
+// benchmarkStrategiesContended runs strategy with many goroutines per CPU
+// while health of backends is constantly updated.
+func benchmarkStrategiesContended(b *testing.B, create func(backends []string) benchStrategy) {
+	const parallelism = 64
+
+	backends := benchmarkBackends(100)
+	strategy := create(backends)
+
+	for i, backend := range backends {
+		strategy.UpdateBackendHealth(backend, i%2 == 0)
+	}
+
+	stop := make(chan struct{})
+	defer close(stop)
+
+	go func() {
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+
+			backend := backends[i%len(backends)]
+			strategy.UpdateBackendHealth(backend, false)
+			strategy.UpdateBackendHealth(backend, true)
+			time.Sleep(time.Millisecond)
+		}
+	}()
+
+	b.SetParallelism(parallelism)
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			_ = strategy.ChooseBackend()
+		}
+	})
+}
+
+func BenchmarkRoundRobin(b *testing.B) {
+	benchmarkStrategies(b, func(backends []string) benchStrategy {
+		return NewRoundRobin(backends)
+	})
+}
+
+func BenchmarkRandom(b *testing.B) {
+	benchmarkStrategies(b, func(backends []string) benchStrategy {
+		return NewRandom(backends)
+	})
+}
+
+// BenchmarkRandom_Locked is the baseline for BenchmarkRandom: implementation before snapshots,
+// which locked the list of backends and shuffled it on every choice.
+func BenchmarkRandom_Locked(b *testing.B) {
+	benchmarkStrategies(b, func(backends []string) benchStrategy {
+		return newLockedRandom(backends)
+	})
+}
+
+func BenchmarkRandom_WithFilter(b *testing.B) {
+	benchmarkStrategies(b, func(backends []string) benchStrategy {
+		return NewRandom(backends, WithFilter(denyFilter{backends[0]: true}))
+	})
+}
+
+func BenchmarkP2C(b *testing.B) {
+	benchmarkStrategies(b, func(backends []string) benchStrategy {
+		return NewP2C(backends)
+	})
+}
+
+func BenchmarkRoundRobin_Contended(b *testing.B) {
+	benchmarkStrategiesContended(b, func(backends []string) benchStrategy {
+		return NewRoundRobin(backends)
+	})
+}
+
+func BenchmarkRandom_Contended(b *testing.B) {
+	benchmarkStrategiesContended(b, func(backends []string) benchStrategy {
+		return NewRandom(backends)
+	})
+}
+
+func BenchmarkRandom_LockedContended(b *testing.B) {
+	benchmarkStrategiesContended(b, func(backends []string) benchStrategy {
+		return newLockedRandom(backends)
+	})
+}
+
+func BenchmarkP2C_Contended(b *testing.B) {
+	benchmarkStrategiesContended(b, func(backends []string) benchStrategy {
+		return NewP2C(backends)
+	})
+}
+
+// lockedRandom is Random strategy before snapshots were introduced.
+type lockedRandom struct {
+	lock      sync.RWMutex
+	available map[string]bool
+	order     []string
+}
+
+func newLockedRandom(backends []string) *lockedRandom {
+	return &lockedRandom{
+		available: make(map[string]bool, len(backends)),
+		order:     backends,
+	}
+}
+
+func (r *lockedRandom) ChooseBackend() string {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	for _, i := range rand.Perm(len(r.order)) {
+		if backend := r.order[i]; r.available[backend] {
+			return backend
+		}
+	}
+
+	return ""
+}
+
+func (r *lockedRandom) IsAvailable(backend string) bool {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	return r.available[backend]
+}
+
+func (r *lockedRandom) UpdateBackendHealth(backend string, healthy bool) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.available[backend] = healthy
+}